@@ -0,0 +1,36 @@
+package aerodrome
+
+import (
+	"abchain_scan/log"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"strings"
+)
+
+const (
+	FactoryAbiJson       = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"token0","type":"address"},{"indexed":true,"internalType":"address","name":"token1","type":"address"},{"indexed":true,"internalType":"bool","name":"stable","type":"bool"},{"indexed":false,"internalType":"address","name":"pool","type":"address"},{"indexed":false,"internalType":"uint256","name":"","type":"uint256"}],"name":"PoolCreated","type":"event"},{"inputs":[{"internalType":"address","name":"tokenA","type":"address"},{"internalType":"address","name":"tokenB","type":"address"},{"internalType":"bool","name":"stable","type":"bool"}],"name":"getPool","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"pool","type":"address"}],"name":"isPool","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"pool","type":"address"},{"internalType":"bool","name":"_stable","type":"bool"}],"name":"getFee","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"allPoolsLength","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+	FactoryAddressHex    = "0x420DD381b31aEf6683db6B902084cB0FFECe40Da"
+	PoolCreatedTopic0Hex = "0x2128d88d14c80cb081c1252a5acff7a264671bf199ce226b53788fb26065005e" // cast keccak "PoolCreated(address,address,bool,address,uint256)"
+)
+
+var (
+	FactoryAbi        *abi.ABI
+	FactoryAddress    = common.HexToAddress(FactoryAddressHex)
+	PoolCreatedTopic0 = common.HexToHash(PoolCreatedTopic0Hex)
+	PoolCreatedEvent  *abi.Event
+)
+
+func init() {
+	factoryAbi, err := abi.JSON(strings.NewReader(FactoryAbiJson))
+	if err != nil {
+		log.Logger.Fatal("Failed to parse factory ABI", zap.Error(err))
+	}
+	FactoryAbi = &factoryAbi
+
+	poolCreatedEvent, err := factoryAbi.EventByID(PoolCreatedTopic0)
+	if err != nil {
+		log.Logger.Fatal("Failed to find PoolCreatedTopic0", zap.Error(err))
+	}
+	PoolCreatedEvent = poolCreatedEvent
+}
@@ -0,0 +1,61 @@
+package aerodrome
+
+import (
+	"abchain_scan/log"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"strings"
+)
+
+const (
+	PoolAbiJson   = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"sender","type":"address"},{"indexed":true,"internalType":"address","name":"to","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount0","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"amount1","type":"uint256"}],"name":"Burn","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"sender","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount0","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"amount1","type":"uint256"}],"name":"Mint","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"sender","type":"address"},{"indexed":true,"internalType":"address","name":"to","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount0In","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"amount1In","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"amount0Out","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"amount1Out","type":"uint256"}],"name":"Swap","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"uint256","name":"reserve0","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"reserve1","type":"uint256"}],"name":"Sync","type":"event"},{"inputs":[],"name":"token0","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"token1","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"stable","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"factory","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"getReserves","outputs":[{"internalType":"uint256","name":"_reserve0","type":"uint256"},{"internalType":"uint256","name":"_reserve1","type":"uint256"},{"internalType":"uint256","name":"_blockTimestampLast","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+	SwapTopic0Hex = "0xb3e2773606abfd36b5bd91394b3a54d1398336c65005baf7bf7a05efeffaf75b" // cast keccak "Swap(address,address,uint256,uint256,uint256,uint256)"
+	SyncTopic0Hex = "0xcf2aa50876cdfbb541206f89af0ee78d44a2abf8d328e37fa4917f982149848a" // cast keccak "Sync(uint256,uint256)"
+	BurnTopic0Hex = "0x5d624aa9c148153ab3446c1b154f660ee7701e549fe9b62dab7171b1c80e6fa2" // cast keccak "Burn(address,address,uint256,uint256)"
+	MintTopic0Hex = "0x4c209b5fc8ad50758f13e2e1088ba56a560dff690a1c6fef26394f4c03821c4f" // cast keccak "Mint(address,uint256,uint256)", same as uniswap v2
+)
+
+var (
+	PoolAbi    *abi.ABI
+	SwapTopic0 = common.HexToHash(SwapTopic0Hex)
+	SwapEvent  *abi.Event
+	SyncTopic0 = common.HexToHash(SyncTopic0Hex)
+	SyncEvent  *abi.Event
+	BurnTopic0 = common.HexToHash(BurnTopic0Hex)
+	BurnEvent  *abi.Event
+	MintTopic0 = common.HexToHash(MintTopic0Hex)
+	MintEvent  *abi.Event
+)
+
+func init() {
+	poolAbi, err := abi.JSON(strings.NewReader(PoolAbiJson))
+	if err != nil {
+		log.Logger.Fatal("Failed to parse pool ABI", zap.Error(err))
+	}
+	PoolAbi = &poolAbi
+
+	swapEvent, err := poolAbi.EventByID(SwapTopic0)
+	if err != nil {
+		log.Logger.Fatal("Failed to find SwapTopic0", zap.Error(err))
+	}
+	SwapEvent = swapEvent
+
+	syncEvent, err := poolAbi.EventByID(SyncTopic0)
+	if err != nil {
+		log.Logger.Fatal("Failed to find SyncTopic0", zap.Error(err))
+	}
+	SyncEvent = syncEvent
+
+	burnEvent, err := poolAbi.EventByID(BurnTopic0)
+	if err != nil {
+		log.Logger.Fatal("Failed to find BurnTopic0", zap.Error(err))
+	}
+	BurnEvent = burnEvent
+
+	mintEvent, err := poolAbi.EventByID(MintTopic0)
+	if err != nil {
+		log.Logger.Fatal("Failed to find MintTopic0", zap.Error(err))
+	}
+	MintEvent = mintEvent
+}
@@ -1,12 +1,12 @@
 package abi
 
 import (
-	uniswapv2 "abchain_scan/abi/uniswap/v2"
-	uniswapv3 "abchain_scan/abi/uniswap/v3"
-	"abchain_scan/types"
+	"abchain_scan/log"
 	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
 )
 
+// built by RegisterDexes, read only once the scanner starts
 var Topic2ProtocolIds = map[common.Hash][]int{}
 var FactoryAddress2ProtocolId = map[common.Address]int{}
 var Topic2FactoryAddresses = map[common.Hash]map[common.Address]struct{}{}
@@ -30,20 +30,7 @@ func mapTopicToFactoryAddress(topic common.Hash, factoryAddress common.Address)
 }
 
 func init() {
-	mapTopicToProtocolId(uniswapv2.PairCreatedTopic0, types.ProtocolIdNewSwap)
-	mapTopicToProtocolId(uniswapv2.SwapTopic0, types.ProtocolIdNewSwap)
-	mapTopicToProtocolId(uniswapv2.SyncTopic0, types.ProtocolIdNewSwap)
-	mapTopicToProtocolId(uniswapv2.BurnTopic0, types.ProtocolIdNewSwap)
-	mapTopicToProtocolId(uniswapv2.MintTopic0, types.ProtocolIdNewSwap)
-
-	mapTopicToProtocolId(uniswapv3.PoolCreatedTopic0, types.ProtocolIdUniswapV3)
-	mapTopicToProtocolId(uniswapv3.SwapTopic0, types.ProtocolIdUniswapV3)
-	mapTopicToProtocolId(uniswapv3.MintTopic0, types.ProtocolIdUniswapV3)
-	mapTopicToProtocolId(uniswapv3.BurnTopic0, types.ProtocolIdUniswapV3)
-
-	FactoryAddress2ProtocolId[uniswapv2.FactoryAddress] = types.ProtocolIdNewSwap
-	FactoryAddress2ProtocolId[uniswapv3.FactoryAddress] = types.ProtocolIdUniswapV3
-
-	mapTopicToFactoryAddress(uniswapv2.PairCreatedTopic0, uniswapv2.FactoryAddress)
-	mapTopicToFactoryAddress(uniswapv3.PoolCreatedTopic0, uniswapv3.FactoryAddress)
+	if err := RegisterDexes(builtinDexes()); err != nil {
+		log.Logger.Fatal("Failed to register built-in dexes", zap.Error(err))
+	}
 }
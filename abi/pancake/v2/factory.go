@@ -0,0 +1,36 @@
+package v2
+
+import (
+	"abchain_scan/log"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"strings"
+)
+
+const (
+	FactoryAbiJson       = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"token0","type":"address"},{"indexed":true,"internalType":"address","name":"token1","type":"address"},{"indexed":false,"internalType":"address","name":"pair","type":"address"},{"indexed":false,"internalType":"uint256","name":"","type":"uint256"}],"name":"PairCreated","type":"event"},{"constant":true,"inputs":[{"internalType":"address","name":"","type":"address"},{"internalType":"address","name":"","type":"address"}],"name":"getPair","outputs":[{"internalType":"address","name":"","type":"address"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"allPairsLength","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"INIT_CODE_PAIR_HASH","outputs":[{"internalType":"bytes32","name":"","type":"bytes32"}],"payable":false,"stateMutability":"view","type":"function"}]`
+	FactoryAddressHex    = "0x02a84c1b3BBD7401a5f7fa98a384EBC70bB5749E"
+	PairCreatedTopic0Hex = "0x0d3648bd0f6ba80134a33ba9275ac585d9d315f0ad8355cddefde31afa28d0e9" // same as uniswap v2
+)
+
+var (
+	FactoryAbi        *abi.ABI
+	FactoryAddress    = common.HexToAddress(FactoryAddressHex)
+	PairCreatedTopic0 = common.HexToHash(PairCreatedTopic0Hex)
+	PairCreatedEvent  *abi.Event
+)
+
+func init() {
+	factoryAbi, err := abi.JSON(strings.NewReader(FactoryAbiJson))
+	if err != nil {
+		log.Logger.Fatal("Failed to parse factory ABI", zap.Error(err))
+	}
+	FactoryAbi = &factoryAbi
+
+	pairCreatedEvent, err := factoryAbi.EventByID(PairCreatedTopic0)
+	if err != nil {
+		log.Logger.Fatal("Failed to find PairCreatedTopic0", zap.Error(err))
+	}
+	PairCreatedEvent = pairCreatedEvent
+}
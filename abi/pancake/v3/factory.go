@@ -0,0 +1,38 @@
+package v3
+
+import (
+	"abchain_scan/log"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"strings"
+)
+
+const (
+	FactoryAbiJson       = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"token0","type":"address"},{"indexed":true,"internalType":"address","name":"token1","type":"address"},{"indexed":true,"internalType":"uint24","name":"fee","type":"uint24"},{"indexed":false,"internalType":"int24","name":"tickSpacing","type":"int24"},{"indexed":false,"internalType":"address","name":"pool","type":"address"}],"name":"PoolCreated","type":"event"},{"inputs":[{"internalType":"address","name":"","type":"address"},{"internalType":"address","name":"","type":"address"},{"internalType":"uint24","name":"","type":"uint24"}],"name":"getPool","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint24","name":"","type":"uint24"}],"name":"feeAmountTickSpacing","outputs":[{"internalType":"int24","name":"","type":"int24"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"poolDeployer","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"}]`
+	FactoryAddressHex    = "0x0BFbCF9fa4f9C56B0F40a671Ad40E0805A091865"
+	PoolDeployerHex      = "0x41ff9AA7e16B8B1a8a8dc4f0eFacd93D02d071c9"                         // pools are created by the deployer, not the factory
+	PoolCreatedTopic0Hex = "0x783cca1c0412dd0d695e784568c96da2e9c22ff989357a2e8b1d9b2b4e6b7118" // same as uniswap v3
+)
+
+var (
+	FactoryAbi        *abi.ABI
+	FactoryAddress    = common.HexToAddress(FactoryAddressHex)
+	PoolDeployer      = common.HexToAddress(PoolDeployerHex)
+	PoolCreatedTopic0 = common.HexToHash(PoolCreatedTopic0Hex)
+	PoolCreatedEvent  *abi.Event
+)
+
+func init() {
+	factoryAbi, err := abi.JSON(strings.NewReader(FactoryAbiJson))
+	if err != nil {
+		log.Logger.Fatal("create abi(PancakeV3Factory) err", zap.Error(err))
+	}
+	FactoryAbi = &factoryAbi
+
+	poolCreatedEvent, err := factoryAbi.EventByID(PoolCreatedTopic0)
+	if err != nil {
+		log.Logger.Fatal("Failed to find PoolCreatedTopic0", zap.Error(err))
+	}
+	PoolCreatedEvent = poolCreatedEvent
+}
@@ -0,0 +1,38 @@
+package v3
+
+import (
+	"abchain_scan/log"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"strings"
+)
+
+const (
+	PoolAbiJson   = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"sender","type":"address"},{"indexed":true,"internalType":"address","name":"recipient","type":"address"},{"indexed":false,"internalType":"int256","name":"amount0","type":"int256"},{"indexed":false,"internalType":"int256","name":"amount1","type":"int256"},{"indexed":false,"internalType":"uint160","name":"sqrtPriceX96","type":"uint160"},{"indexed":false,"internalType":"uint128","name":"liquidity","type":"uint128"},{"indexed":false,"internalType":"int24","name":"tick","type":"int24"},{"indexed":false,"internalType":"uint128","name":"protocolFeesToken0","type":"uint128"},{"indexed":false,"internalType":"uint128","name":"protocolFeesToken1","type":"uint128"}],"name":"Swap","type":"event"},{"inputs":[],"name":"token0","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"token1","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"fee","outputs":[{"internalType":"uint24","name":"","type":"uint24"}],"stateMutability":"view","type":"function"}]`
+	SwapTopic0Hex = "0x19b47279256b2a23a1665c810c8d55a1758940ee09377d4f8d26497a3577dc83" // cast keccak "Swap(address,address,int256,int256,uint160,uint128,int24,uint128,uint128)"
+)
+
+/*
+Mint and Burn are the same as uniswap v3, only Swap carries the extra protocol fee fields
+*/
+var (
+	PoolAbi *abi.ABI
+
+	SwapTopic0 = common.HexToHash(SwapTopic0Hex)
+	SwapEvent  *abi.Event
+)
+
+func init() {
+	poolAbi, err := abi.JSON(strings.NewReader(PoolAbiJson))
+	if err != nil {
+		log.Logger.Fatal("load abi[PancakeV3Pool] err", zap.Error(err))
+	}
+	PoolAbi = &poolAbi
+
+	swapEvent, err := poolAbi.EventByID(SwapTopic0)
+	if err != nil {
+		log.Logger.Fatal("load abi[PancakeV3Pool] event[swap] err", zap.Error(err))
+	}
+	SwapEvent = swapEvent
+}
@@ -0,0 +1,331 @@
+package abi
+
+import (
+	"abchain_scan/abi/aerodrome"
+	pancakev2 "abchain_scan/abi/pancake/v2"
+	pancakev3 "abchain_scan/abi/pancake/v3"
+	uniswapv2 "abchain_scan/abi/uniswap/v2"
+	uniswapv3 "abchain_scan/abi/uniswap/v3"
+	uniswapv4 "abchain_scan/abi/uniswap/v4"
+	"abchain_scan/config"
+	"abchain_scan/types"
+	"fmt"
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+)
+
+const (
+	FamilyUniswapV2 = "uniswap_v2"
+	FamilyUniswapV3 = "uniswap_v3"
+	FamilySolidly   = "solidly"
+	FamilyUniswapV4 = "uniswap_v4"
+
+	EventPoolCreated     = "pool_created"
+	EventSwap            = "swap"
+	EventSync            = "sync"
+	EventMint            = "mint"
+	EventBurn            = "burn"
+	EventModifyLiquidity = "modify_liquidity"
+	EventInitialize      = "initialize"
+	EventCollect         = "collect"
+	EventFlash           = "flash"
+)
+
+// FamilyEvents the events every dex of a family must have, pool_created is emitted by the factories
+var FamilyEvents = map[string][]string{
+	FamilyUniswapV2: {EventPoolCreated, EventSwap, EventSync, EventMint, EventBurn},
+	FamilyUniswapV3: {EventPoolCreated, EventSwap, EventMint, EventBurn, EventInitialize, EventCollect, EventFlash},
+	FamilySolidly:   {EventPoolCreated, EventSwap, EventSync, EventMint, EventBurn},
+	FamilyUniswapV4: {EventPoolCreated, EventSwap, EventModifyLiquidity},
+}
+
+/*
+Dex
+a protocol the scanner indexes, for uniswap v4 the factory is the PoolManager,
+InitCodeHash and PoolDeployer are zero when the pools are not created by CREATE2 of the factory
+*/
+type Dex struct {
+	Name         string
+	ProtocolId   int
+	Family       string
+	MetricLabel  string
+	Factories    []common.Address
+	InitCodeHash common.Hash
+	PoolDeployer common.Address
+	Events       map[string]*ethabi.Event
+}
+
+func (d *Dex) HasInitCodeHash() bool {
+	return d.InitCodeHash != (common.Hash{})
+}
+
+// Deployer the address the pools are created from, the factory unless the dex has a separate pool deployer
+func (d *Dex) Deployer(factory common.Address) common.Address {
+	if d.PoolDeployer != (common.Address{}) {
+		return d.PoolDeployer
+	}
+	return factory
+}
+
+var (
+	Dexes          []*Dex
+	ProtocolId2Dex = map[int]*Dex{}
+)
+
+func GetDexByName(name string) (*Dex, bool) {
+	for _, dex := range Dexes {
+		if dex.Name == name {
+			return dex, true
+		}
+	}
+	return nil, false
+}
+
+func builtinDexes() []*Dex {
+	return []*Dex{
+		{
+			Name:        types.ProtocolNameNewSwap,
+			ProtocolId:  types.ProtocolIdNewSwap,
+			Family:      FamilyUniswapV2,
+			MetricLabel: "uniswap_v2",
+			Factories:   []common.Address{uniswapv2.FactoryAddress},
+			// the init code hash of this factory is not known, its pairs are verified with getPair
+			Events: map[string]*ethabi.Event{
+				EventPoolCreated: uniswapv2.PairCreatedEvent,
+				EventSwap:        uniswapv2.SwapEvent,
+				EventSync:        uniswapv2.SyncEvent,
+				EventMint:        uniswapv2.MintEvent,
+				EventBurn:        uniswapv2.BurnEvent,
+			},
+		},
+		{
+			Name:         types.ProtocolNameUniswapV3,
+			ProtocolId:   types.ProtocolIdUniswapV3,
+			Family:       FamilyUniswapV3,
+			MetricLabel:  "uniswap_v3",
+			Factories:    []common.Address{uniswapv3.FactoryAddress},
+			InitCodeHash: common.HexToHash("0xe34f199b19b2b4f47f68442619d555527d244f78a3297ea89325f843f87b8b54"),
+			Events: map[string]*ethabi.Event{
+				EventPoolCreated: uniswapv3.PoolCreatedEvent,
+				EventSwap:        uniswapv3.SwapEvent,
+				EventMint:        uniswapv3.MintEvent,
+				EventBurn:        uniswapv3.BurnEvent,
+				EventInitialize:  uniswapv3.InitializeEvent,
+				EventCollect:     uniswapv3.CollectEvent,
+				EventFlash:       uniswapv3.FlashEvent,
+			},
+		},
+		{
+			Name:         types.ProtocolNamePancakeV2,
+			ProtocolId:   types.ProtocolIdPancakeV2,
+			Family:       FamilyUniswapV2,
+			MetricLabel:  "pancake_v2",
+			Factories:    []common.Address{pancakev2.FactoryAddress},
+			InitCodeHash: common.HexToHash("0x57224589c67f3f30a6b0d7a1b54cf3153ab84563bc609ef41dfb34f8b2974d2d"),
+			Events: map[string]*ethabi.Event{
+				EventPoolCreated: pancakev2.PairCreatedEvent,
+				EventSwap:        uniswapv2.SwapEvent,
+				EventSync:        uniswapv2.SyncEvent,
+				EventMint:        uniswapv2.MintEvent,
+				EventBurn:        uniswapv2.BurnEvent,
+			},
+		},
+		{
+			Name:         types.ProtocolNamePancakeV3,
+			ProtocolId:   types.ProtocolIdPancakeV3,
+			Family:       FamilyUniswapV3,
+			MetricLabel:  "pancake_v3",
+			Factories:    []common.Address{pancakev3.FactoryAddress},
+			InitCodeHash: common.HexToHash("0x6ce8eb472fa82df5469c6ab6d485f17c3ad13c8cd7af59b3d4a8026c5ce0f7e2"),
+			PoolDeployer: pancakev3.PoolDeployer,
+			Events: map[string]*ethabi.Event{
+				EventPoolCreated: pancakev3.PoolCreatedEvent,
+				EventSwap:        pancakev3.SwapEvent,
+				EventMint:        uniswapv3.MintEvent,
+				EventBurn:        uniswapv3.BurnEvent,
+				EventInitialize:  uniswapv3.InitializeEvent,
+				EventCollect:     uniswapv3.CollectEvent,
+				EventFlash:       uniswapv3.FlashEvent,
+			},
+		},
+		{
+			Name:        types.ProtocolNameAerodrome,
+			ProtocolId:  types.ProtocolIdAerodrome,
+			Family:      FamilySolidly,
+			MetricLabel: "aerodrome",
+			Factories:   []common.Address{aerodrome.FactoryAddress},
+			Events: map[string]*ethabi.Event{
+				EventPoolCreated: aerodrome.PoolCreatedEvent,
+				EventSwap:        aerodrome.SwapEvent,
+				EventSync:        aerodrome.SyncEvent,
+				EventMint:        aerodrome.MintEvent,
+				EventBurn:        aerodrome.BurnEvent,
+			},
+		},
+		{
+			Name:        types.ProtocolNameUniswapV4,
+			ProtocolId:  types.ProtocolIdUniswapV4,
+			Family:      FamilyUniswapV4,
+			MetricLabel: "uniswap_v4",
+			Factories:   []common.Address{uniswapv4.PoolManagerAddress},
+			Events: map[string]*ethabi.Event{
+				EventPoolCreated:     uniswapv4.InitializeEvent,
+				EventSwap:            uniswapv4.SwapEvent,
+				EventModifyLiquidity: uniswapv4.ModifyLiquidityEvent,
+			},
+		},
+	}
+}
+
+// familyDefaultEvents the events of the first built-in dex of the family, used for events a config leaves out
+func familyDefaultEvents(family string) map[string]*ethabi.Event {
+	for _, dex := range builtinDexes() {
+		if dex.Family == family {
+			return dex.Events
+		}
+	}
+	return nil
+}
+
+func validateDex(dex *Dex) error {
+	eventNames, ok := FamilyEvents[dex.Family]
+	if !ok {
+		return fmt.Errorf("dex %s: unknown family %q", dex.Name, dex.Family)
+	}
+	if len(dex.Factories) == 0 {
+		return fmt.Errorf("dex %s: no factory", dex.Name)
+	}
+	for _, eventName := range eventNames {
+		if dex.Events[eventName] == nil {
+			return fmt.Errorf("dex %s: missing %s event", dex.Name, eventName)
+		}
+	}
+	return nil
+}
+
+/*
+RegisterDexes
+rebuilds Topic2ProtocolIds, FactoryAddress2ProtocolId and Topic2FactoryAddresses from dexes,
+only called at startup before any block is parsed
+*/
+func RegisterDexes(dexes []*Dex) error {
+	protocolId2Dex := make(map[int]*Dex, len(dexes))
+	names := make(map[string]struct{}, len(dexes))
+	factory2ProtocolId := make(map[common.Address]int)
+	for _, dex := range dexes {
+		if err := validateDex(dex); err != nil {
+			return err
+		}
+		if _, ok := protocolId2Dex[dex.ProtocolId]; ok {
+			return fmt.Errorf("dex %s: duplicated protocol id %d", dex.Name, dex.ProtocolId)
+		}
+		if _, ok := names[dex.Name]; ok {
+			return fmt.Errorf("dex %s: duplicated name", dex.Name)
+		}
+		for _, factory := range dex.Factories {
+			if protocolId, ok := factory2ProtocolId[factory]; ok {
+				return fmt.Errorf("dex %s: factory %s already used by protocol %d", dex.Name, factory, protocolId)
+			}
+			factory2ProtocolId[factory] = dex.ProtocolId
+		}
+		protocolId2Dex[dex.ProtocolId] = dex
+		names[dex.Name] = struct{}{}
+	}
+
+	Topic2ProtocolIds = map[common.Hash][]int{}
+	FactoryAddress2ProtocolId = factory2ProtocolId
+	Topic2FactoryAddresses = map[common.Hash]map[common.Address]struct{}{}
+	for _, dex := range dexes {
+		for _, eventName := range FamilyEvents[dex.Family] {
+			mapTopicToProtocolId(dex.Events[eventName].ID, dex.ProtocolId)
+		}
+		poolCreatedTopic := dex.Events[EventPoolCreated].ID
+		for _, factory := range dex.Factories {
+			mapTopicToFactoryAddress(poolCreatedTopic, factory)
+		}
+		types.RegisterProtocol(dex.ProtocolId, dex.Name)
+	}
+
+	Dexes = dexes
+	ProtocolId2Dex = protocolId2Dex
+	return nil
+}
+
+// parseEventAbi accepts the json of a single event, or an abi array holding exactly one event
+func parseEventAbi(eventAbiJson string) (*ethabi.Event, error) {
+	eventAbiJson = strings.TrimSpace(eventAbiJson)
+	if !strings.HasPrefix(eventAbiJson, "[") {
+		eventAbiJson = "[" + eventAbiJson + "]"
+	}
+	parsed, err := ethabi.JSON(strings.NewReader(eventAbiJson))
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.Events) == 1 {
+		for _, event := range parsed.Events {
+			return &event, nil
+		}
+	}
+	return nil, fmt.Errorf("want exactly 1 event, got %d", len(parsed.Events))
+}
+
+func newDexFromConf(conf *config.DexConf) (*Dex, error) {
+	if conf.Name == "" {
+		return nil, fmt.Errorf("dex without name")
+	}
+	dex := &Dex{
+		Name:         conf.Name,
+		ProtocolId:   conf.ProtocolId,
+		Family:       conf.Family,
+		MetricLabel:  strings.ToLower(conf.Name),
+		InitCodeHash: common.HexToHash(conf.InitCodeHash),
+		PoolDeployer: common.HexToAddress(conf.PoolDeployer),
+		Events:       map[string]*ethabi.Event{},
+	}
+	for _, factory := range conf.Factories {
+		if !common.IsHexAddress(factory) {
+			return nil, fmt.Errorf("dex %s: invalid factory address %q", conf.Name, factory)
+		}
+		dex.Factories = append(dex.Factories, common.HexToAddress(factory))
+	}
+
+	for eventName, event := range familyDefaultEvents(conf.Family) {
+		dex.Events[eventName] = event
+	}
+	for eventName, eventAbiJson := range conf.EventAbis {
+		event, err := parseEventAbi(eventAbiJson)
+		if err != nil {
+			return nil, fmt.Errorf("dex %s: invalid %s event abi: %w", conf.Name, eventName, err)
+		}
+		dex.Events[eventName] = event
+	}
+	return dex, nil
+}
+
+/*
+LoadDexConf
+registers the built-in dexes plus the ones of the config, a config dex named like a built-in one replaces it
+*/
+func LoadDexConf(confs []*config.DexConf) error {
+	dexes := builtinDexes()
+	for _, conf := range confs {
+		dex, err := newDexFromConf(conf)
+		if err != nil {
+			return err
+		}
+
+		replaced := false
+		for i := range dexes {
+			if dexes[i].Name == dex.Name {
+				dexes[i] = dex
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			dexes = append(dexes, dex)
+		}
+	}
+	return RegisterDexes(dexes)
+}
@@ -0,0 +1,96 @@
+package abi
+
+import (
+	pancakev2 "abchain_scan/abi/pancake/v2"
+	pancakev3 "abchain_scan/abi/pancake/v3"
+	uniswapv2 "abchain_scan/abi/uniswap/v2"
+	uniswapv3 "abchain_scan/abi/uniswap/v3"
+	"abchain_scan/config"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestRegisterDexes_Builtin(t *testing.T) {
+	require.ElementsMatch(t,
+		[]int{types.ProtocolIdNewSwap, types.ProtocolIdPancakeV2, types.ProtocolIdAerodrome},
+		Topic2ProtocolIds[uniswapv2.MintTopic0])
+	require.ElementsMatch(t,
+		[]int{types.ProtocolIdUniswapV3, types.ProtocolIdPancakeV3},
+		Topic2ProtocolIds[uniswapv3.PoolCreatedTopic0])
+	require.Len(t, Topic2FactoryAddresses[uniswapv3.PoolCreatedTopic0], 2)
+	require.Equal(t, types.ProtocolIdUniswapV3, FactoryAddress2ProtocolId[uniswapv3.FactoryAddress])
+}
+
+func TestRegisterDexes_PancakeInitCodeHash(t *testing.T) {
+	weth := common.HexToAddress("0x4200000000000000000000000000000000000006")
+
+	// https://basescan.org/address/0xabd5Ba8F0945d89E48077947037455bD40f475FC
+	v2 := ProtocolId2Dex[types.ProtocolIdPancakeV2]
+	require.True(t, v2.HasInitCodeHash())
+	require.Equal(t, pancakev2.FactoryAddress, v2.Deployer(pancakev2.FactoryAddress))
+	require.Equal(t,
+		common.HexToAddress("0xabd5Ba8F0945d89E48077947037455bD40f475FC"),
+		uniswapv2.PairAddress(pancakev2.FactoryAddress, v2.InitCodeHash, common.HexToAddress("0x357f9404356970F6B1C7208cf966abE3e505BA60"), weth))
+
+	// https://basescan.org/address/0x5845A51630AFab7C68556CF57a7b6827Bd94d434, fee 100, created by the pool deployer, not the factory
+	v3 := ProtocolId2Dex[types.ProtocolIdPancakeV3]
+	require.True(t, v3.HasInitCodeHash())
+	require.Equal(t, pancakev3.PoolDeployer, v3.Deployer(pancakev3.FactoryAddress))
+	token := common.HexToAddress("0x858A6594f86fafb10dC0dEdC588C7CBb8E795129")
+	require.Equal(t,
+		common.HexToAddress("0x5845A51630AFab7C68556CF57a7b6827Bd94d434"),
+		uniswapv3.PoolAddress(v3.Deployer(pancakev3.FactoryAddress), v3.InitCodeHash, weth, token, big.NewInt(100)))
+	require.NotEqual(t,
+		common.HexToAddress("0x5845A51630AFab7C68556CF57a7b6827Bd94d434"),
+		uniswapv3.PoolAddress(pancakev3.FactoryAddress, v3.InitCodeHash, weth, token, big.NewInt(100)))
+}
+
+func TestLoadDexConf(t *testing.T) {
+	t.Cleanup(func() {
+		require.NoError(t, RegisterDexes(builtinDexes()))
+	})
+
+	factory := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	// a fork whose Swap has no indexed sender
+	swapAbi := `{"anonymous":false,"inputs":[{"indexed":false,"name":"sender","type":"address"},{"indexed":false,"name":"amount0In","type":"uint256"},{"indexed":false,"name":"amount1In","type":"uint256"},{"indexed":false,"name":"amount0Out","type":"uint256"},{"indexed":false,"name":"amount1Out","type":"uint256"},{"indexed":true,"name":"to","type":"address"}],"name":"SwapFork","type":"event"}`
+	err := LoadDexConf([]*config.DexConf{{
+		Name:       "ForkSwap",
+		ProtocolId: 100,
+		Family:     FamilyUniswapV2,
+		Factories:  []string{factory.Hex()},
+		EventAbis:  map[string]string{EventSwap: swapAbi},
+	}})
+	require.NoError(t, err)
+
+	dex := ProtocolId2Dex[100]
+	require.NotNil(t, dex)
+	require.Equal(t, "forkswap", dex.MetricLabel)
+	require.Equal(t, "ForkSwap", types.GetProtocolName(100))
+	require.Equal(t, 100, FactoryAddress2ProtocolId[factory])
+	require.Contains(t, Topic2FactoryAddresses[uniswapv2.PairCreatedTopic0], factory)
+	require.Contains(t, Topic2ProtocolIds[uniswapv2.SyncTopic0], 100)
+	require.NotContains(t, Topic2ProtocolIds[uniswapv2.SwapTopic0], 100)
+	require.Equal(t, []int{100}, Topic2ProtocolIds[dex.Events[EventSwap].ID])
+}
+
+func TestLoadDexConf_Invalid(t *testing.T) {
+	t.Cleanup(func() {
+		require.NoError(t, RegisterDexes(builtinDexes()))
+	})
+
+	tests := []*config.DexConf{
+		{Name: "UnknownFamily", ProtocolId: 100, Family: "curve", Factories: []string{"0x1111111111111111111111111111111111111111"}},
+		{Name: "NoFactory", ProtocolId: 100, Family: FamilyUniswapV2},
+		{Name: "DuplicatedId", ProtocolId: types.ProtocolIdUniswapV3, Family: FamilyUniswapV2, Factories: []string{"0x1111111111111111111111111111111111111111"}},
+		{Name: "DuplicatedFactory", ProtocolId: 100, Family: FamilyUniswapV2, Factories: []string{uniswapv2.FactoryAddressHex}},
+		{Name: "BadAbi", ProtocolId: 100, Family: FamilyUniswapV2, Factories: []string{"0x1111111111111111111111111111111111111111"}, EventAbis: map[string]string{EventSwap: "{"}},
+	}
+	for _, test := range tests {
+		require.Error(t, LoadDexConf([]*config.DexConf{test}), test.Name)
+	}
+	// the built-in maps are left alone by a failed load
+	require.Equal(t, types.ProtocolIdNewSwap, FactoryAddress2ProtocolId[uniswapv2.FactoryAddress])
+}
@@ -0,0 +1,15 @@
+package v2
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+PairAddress
+CREATE2 address of a pair, the salt is keccak256(abi.encodePacked(token0, token1)) with token0 < token1
+*/
+func PairAddress(factory common.Address, initCodeHash common.Hash, token0, token1 common.Address) common.Address {
+	salt := crypto.Keccak256Hash(token0.Bytes(), token1.Bytes())
+	return crypto.CreateAddress2(factory, salt, initCodeHash.Bytes())
+}
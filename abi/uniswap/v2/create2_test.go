@@ -0,0 +1,21 @@
+package v2
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPairAddress(t *testing.T) {
+	// USDC/WETH of the uniswap v2 factory on ethereum, the builtin NewSwap factory has no known init code hash
+	factory := common.HexToAddress("0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f")
+	initCodeHash := common.HexToHash("0x96e8ac4277198ff8b6f785478aa9a39f403cb768dd02cbee326c3e7da348845f")
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	weth := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	require.Equal(t,
+		common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"),
+		PairAddress(factory, initCodeHash, usdc, weth))
+	require.NotEqual(t,
+		common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"),
+		PairAddress(FactoryAddress, initCodeHash, usdc, weth))
+}
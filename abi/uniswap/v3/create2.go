@@ -0,0 +1,24 @@
+package v3
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+)
+
+// FeeTiers fees enabled on the uniswap v3 and pancake v3 factories, tried before asking the pool for its fee
+var FeeTiers = []*big.Int{big.NewInt(100), big.NewInt(500), big.NewInt(2500), big.NewInt(3000), big.NewInt(10000)}
+
+/*
+PoolAddress
+CREATE2 address of a pool, the salt is keccak256(abi.encode(token0, token1, fee)) with token0 < token1,
+deployer is the factory, or the pool deployer for pancake v3
+*/
+func PoolAddress(deployer common.Address, initCodeHash common.Hash, token0, token1 common.Address, fee *big.Int) common.Address {
+	salt := crypto.Keccak256Hash(
+		common.LeftPadBytes(token0.Bytes(), 32),
+		common.LeftPadBytes(token1.Bytes(), 32),
+		common.LeftPadBytes(fee.Bytes(), 32),
+	)
+	return crypto.CreateAddress2(deployer, salt, initCodeHash.Bytes())
+}
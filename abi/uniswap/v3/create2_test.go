@@ -0,0 +1,21 @@
+package v3
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestPoolAddress(t *testing.T) {
+	// WETH/USDC 0.05% on base
+	weth := common.HexToAddress("0x4200000000000000000000000000000000000006")
+	usdc := common.HexToAddress("0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913")
+	initCodeHash := common.HexToHash("0xe34f199b19b2b4f47f68442619d555527d244f78a3297ea89325f843f87b8b54")
+	require.Equal(t,
+		common.HexToAddress("0xd0b53D9277642d899DF5C87A3966A349A798F224"),
+		PoolAddress(FactoryAddress, initCodeHash, weth, usdc, big.NewInt(500)))
+	require.NotEqual(t,
+		common.HexToAddress("0xd0b53D9277642d899DF5C87A3966A349A798F224"),
+		PoolAddress(FactoryAddress, initCodeHash, weth, usdc, big.NewInt(3000)))
+}
@@ -13,6 +13,10 @@ const (
 	SwapTopic0Hex = "0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67"
 	MintTopic0Hex = "0x7a53080ba414158be7ec69b987b5fb7d07dee101fe85488f0853ae16239d0bde"
 	BurnTopic0Hex = "0x0c396cd989a39f4459b5fa1aed6a9a8dcdbc45908acfd67e028cd568da98982c"
+
+	InitializeTopic0Hex = "0x98636036cb66a9c19a37435efc1e90142190214e8abeb821bdba3f2990dd4c95" // cast keccak "Initialize(uint160,int24)"
+	CollectTopic0Hex    = "0x70935338e69775456a85ddef226c395fb668b63fa0115f5f20610b388e6ca9c0" // cast keccak "Collect(address,address,int24,int24,uint128,uint128)"
+	FlashTopic0Hex      = "0xbdbdb71d7860376ba52b25a5028beea23581364a40522f6bcfb86bb1f2dca633" // cast keccak "Flash(address,address,uint256,uint256,uint256,uint256)"
 )
 
 var (
@@ -26,6 +30,15 @@ var (
 
 	BurnTopic0 = common.HexToHash(BurnTopic0Hex)
 	BurnEvent  *abi.Event
+
+	InitializeTopic0 = common.HexToHash(InitializeTopic0Hex)
+	InitializeEvent  *abi.Event
+
+	CollectTopic0 = common.HexToHash(CollectTopic0Hex)
+	CollectEvent  *abi.Event
+
+	FlashTopic0 = common.HexToHash(FlashTopic0Hex)
+	FlashEvent  *abi.Event
 )
 
 func init() {
@@ -52,4 +65,22 @@ func init() {
 		log.Logger.Fatal("load abi[PancakeV3Pool] event[burn] err", zap.Error(err))
 	}
 	BurnEvent = burnEvent
+
+	initializeEvent, err := poolAbi.EventByID(InitializeTopic0)
+	if err != nil {
+		log.Logger.Fatal("load abi[PancakeV3Pool] event[initialize] err", zap.Error(err))
+	}
+	InitializeEvent = initializeEvent
+
+	collectEvent, err := poolAbi.EventByID(CollectTopic0)
+	if err != nil {
+		log.Logger.Fatal("load abi[PancakeV3Pool] event[collect] err", zap.Error(err))
+	}
+	CollectEvent = collectEvent
+
+	flashEvent, err := poolAbi.EventByID(FlashTopic0)
+	if err != nil {
+		log.Logger.Fatal("load abi[PancakeV3Pool] event[flash] err", zap.Error(err))
+	}
+	FlashEvent = flashEvent
 }
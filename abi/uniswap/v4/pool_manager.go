@@ -0,0 +1,79 @@
+package v4
+
+import (
+	"abchain_scan/log"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+	"math/big"
+	"strings"
+)
+
+const (
+	PoolManagerAbiJson       = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"PoolId","name":"id","type":"bytes32"},{"indexed":true,"internalType":"Currency","name":"currency0","type":"address"},{"indexed":true,"internalType":"Currency","name":"currency1","type":"address"},{"indexed":false,"internalType":"uint24","name":"fee","type":"uint24"},{"indexed":false,"internalType":"int24","name":"tickSpacing","type":"int24"},{"indexed":false,"internalType":"contract IHooks","name":"hooks","type":"address"},{"indexed":false,"internalType":"uint160","name":"sqrtPriceX96","type":"uint160"},{"indexed":false,"internalType":"int24","name":"tick","type":"int24"}],"name":"Initialize","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"PoolId","name":"id","type":"bytes32"},{"indexed":true,"internalType":"address","name":"sender","type":"address"},{"indexed":false,"internalType":"int128","name":"amount0","type":"int128"},{"indexed":false,"internalType":"int128","name":"amount1","type":"int128"},{"indexed":false,"internalType":"uint160","name":"sqrtPriceX96","type":"uint160"},{"indexed":false,"internalType":"uint128","name":"liquidity","type":"uint128"},{"indexed":false,"internalType":"int24","name":"tick","type":"int24"},{"indexed":false,"internalType":"uint24","name":"fee","type":"uint24"}],"name":"Swap","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"PoolId","name":"id","type":"bytes32"},{"indexed":true,"internalType":"address","name":"sender","type":"address"},{"indexed":false,"internalType":"int24","name":"tickLower","type":"int24"},{"indexed":false,"internalType":"int24","name":"tickUpper","type":"int24"},{"indexed":false,"internalType":"int256","name":"liquidityDelta","type":"int256"},{"indexed":false,"internalType":"bytes32","name":"salt","type":"bytes32"}],"name":"ModifyLiquidity","type":"event"}]`
+	PoolManagerAddressHex    = "0x498581fF718922c3f8e6A244956aF099B2652b2b"
+	InitializeTopic0Hex      = "0xdd466e674ea557f56295e2d0218a125ea4b4f0f6f3307b95f85e6110838d6438" // cast keccak "Initialize(bytes32,address,address,uint24,int24,address,uint160,int24)"
+	SwapTopic0Hex            = "0x40e9cecb9f5f1f1c5b9c97dec2917b7ee92e57ba5563708daca94dd84ad7112f" // cast keccak "Swap(bytes32,address,int128,int128,uint160,uint128,int24,uint24)"
+	ModifyLiquidityTopic0Hex = "0xf208f4912782fd25c7f114ca3723a2d5dd6f3bcc3ac8db5af63baa85f711d5ec" // cast keccak "ModifyLiquidity(bytes32,address,int24,int24,int256,bytes32)"
+)
+
+var (
+	PoolManagerAbi        *abi.ABI
+	PoolManagerAddress    = common.HexToAddress(PoolManagerAddressHex)
+	InitializeTopic0      = common.HexToHash(InitializeTopic0Hex)
+	InitializeEvent       *abi.Event
+	SwapTopic0            = common.HexToHash(SwapTopic0Hex)
+	SwapEvent             *abi.Event
+	ModifyLiquidityTopic0 = common.HexToHash(ModifyLiquidityTopic0Hex)
+	ModifyLiquidityEvent  *abi.Event
+
+	poolKeyArguments abi.Arguments
+)
+
+func init() {
+	poolManagerAbi, err := abi.JSON(strings.NewReader(PoolManagerAbiJson))
+	if err != nil {
+		log.Logger.Fatal("load abi[PoolManager] err", zap.Error(err))
+	}
+	PoolManagerAbi = &poolManagerAbi
+
+	initializeEvent, err := poolManagerAbi.EventByID(InitializeTopic0)
+	if err != nil {
+		log.Logger.Fatal("load abi[PoolManager] event[initialize] err", zap.Error(err))
+	}
+	InitializeEvent = initializeEvent
+
+	swapEvent, err := poolManagerAbi.EventByID(SwapTopic0)
+	if err != nil {
+		log.Logger.Fatal("load abi[PoolManager] event[swap] err", zap.Error(err))
+	}
+	SwapEvent = swapEvent
+
+	modifyLiquidityEvent, err := poolManagerAbi.EventByID(ModifyLiquidityTopic0)
+	if err != nil {
+		log.Logger.Fatal("load abi[PoolManager] event[modifyLiquidity] err", zap.Error(err))
+	}
+	ModifyLiquidityEvent = modifyLiquidityEvent
+
+	// currency0, currency1, fee, tickSpacing and hooks of the Initialize event
+	poolKeyArguments = abi.Arguments{
+		initializeEvent.Inputs[1],
+		initializeEvent.Inputs[2],
+		initializeEvent.Inputs[3],
+		initializeEvent.Inputs[4],
+		initializeEvent.Inputs[5],
+	}
+}
+
+/*
+PoolId
+keccak256(abi.encode(PoolKey)), the id of a pool inside the PoolManager
+*/
+func PoolId(currency0, currency1 common.Address, fee, tickSpacing *big.Int, hooks common.Address) (common.Hash, error) {
+	encoded, err := poolKeyArguments.Pack(currency0, currency1, fee, tickSpacing, hooks)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
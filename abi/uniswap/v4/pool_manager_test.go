@@ -0,0 +1,16 @@
+package v4
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestPoolId(t *testing.T) {
+	// ETH/USDC 0.05% without hooks
+	usdc := common.HexToAddress("0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913")
+	poolId, err := PoolId(common.Address{}, usdc, big.NewInt(500), big.NewInt(10), common.Address{})
+	require.NoError(t, err)
+	require.Equal(t, common.HexToHash("0x96d4b53a38337a5733179751781178a2613306063c511b78cd02684739288c0a"), poolId)
+}
@@ -0,0 +1,37 @@
+package v4
+
+import (
+	"abchain_scan/log"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"strings"
+)
+
+const (
+	PositionManagerAbiJson    = `[{"inputs":[{"internalType":"bytes25","name":"poolId","type":"bytes25"}],"name":"poolKeys","outputs":[{"internalType":"Currency","name":"currency0","type":"address"},{"internalType":"Currency","name":"currency1","type":"address"},{"internalType":"uint24","name":"fee","type":"uint24"},{"internalType":"int24","name":"tickSpacing","type":"int24"},{"internalType":"contract IHooks","name":"hooks","type":"address"}],"stateMutability":"view","type":"function"}]`
+	PositionManagerAddressHex = "0x7C5f5A4bBd8fD63184577525326123B519429bDc"
+)
+
+var (
+	PositionManagerAbi     *abi.ABI
+	PositionManagerAddress = common.HexToAddress(PositionManagerAddressHex)
+)
+
+func init() {
+	positionManagerAbi, err := abi.JSON(strings.NewReader(PositionManagerAbiJson))
+	if err != nil {
+		log.Logger.Fatal("load abi[PositionManager] err", zap.Error(err))
+	}
+	PositionManagerAbi = &positionManagerAbi
+}
+
+/*
+PoolKeyId
+the PositionManager stores pool keys under the first 25 bytes of the PoolId
+*/
+func PoolKeyId(poolId common.Hash) [25]byte {
+	var id [25]byte
+	copy(id[:], poolId[:25])
+	return id
+}
@@ -0,0 +1,236 @@
+package block_getter
+
+import (
+	"abchain_scan/log"
+	"abchain_scan/types"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ArchiveModeRecord = "record"
+	ArchiveModeReplay = "replay"
+)
+
+const (
+	archiveFilePrefix = "blocks-"
+	archiveFileSuffix = ".jsonl.gz"
+)
+
+// archiveFileName files are ordered by index, heights alone go back after reorgs and restarts
+func archiveFileName(index, firstHeight uint64) string {
+	return fmt.Sprintf("%s%08d-%012d%s", archiveFilePrefix, index, firstHeight, archiveFileSuffix)
+}
+
+/*
+archiveRecord
+one line of an archive file, the raw rpc responses of a block as emitted by the getter,
+a block re-emitted after a reorg carries the reorg so replay rolls back the same way
+*/
+type archiveRecord struct {
+	Number   uint64          `json:"number"`
+	Hash     common.Hash     `json:"hash"`
+	Reorg    *types.Reorg    `json:"reorg,omitempty"`
+	Block    json.RawMessage `json:"block"`
+	Receipts json.RawMessage `json:"receipts"`
+}
+
+/*
+archiveWriter
+writes emitted blocks in emission order, a file is named by its index and the height of its first block
+and rotated every blocks_per_file blocks, each block is flushed so a crash loses at most a partial line
+*/
+type archiveWriter struct {
+	dir           string
+	blocksPerFile int
+	nextIndex     uint64
+	file          *os.File
+	gz            *gzip.Writer
+	encoder       *json.Encoder
+	count         int
+}
+
+func newArchiveWriter(dir string, blocksPerFile int) (*archiveWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	files, err := listArchiveFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &archiveWriter{
+		dir:           dir,
+		blocksPerFile: max(blocksPerFile, 1),
+	}
+	if len(files) > 0 {
+		w.nextIndex = files[len(files)-1].index + 1
+	}
+	return w, nil
+}
+
+func (w *archiveWriter) open(firstHeight uint64) error {
+	file, err := os.Create(filepath.Join(w.dir, archiveFileName(w.nextIndex, firstHeight)))
+	if err != nil {
+		return err
+	}
+	w.nextIndex++
+
+	w.file = file
+	w.gz = gzip.NewWriter(file)
+	w.encoder = json.NewEncoder(w.gz)
+	w.count = 0
+	return nil
+}
+
+func (w *archiveWriter) write(pbc *types.ParseBlockContext) error {
+	if pbc.RawBlock == nil {
+		return errors.New("no raw block to archive, record mode needs ingestion_mode blocks")
+	}
+
+	if w.file == nil {
+		if err := w.open(pbc.HeightTime.Height); err != nil {
+			return err
+		}
+	}
+
+	err := w.encoder.Encode(&archiveRecord{
+		Number:   pbc.HeightTime.Height,
+		Hash:     pbc.BlockHash,
+		Reorg:    pbc.Reorg,
+		Block:    pbc.RawBlock,
+		Receipts: pbc.RawReceipts,
+	})
+	if err != nil {
+		return err
+	}
+	if err = w.gz.Flush(); err != nil {
+		return err
+	}
+
+	w.count++
+	if w.count >= w.blocksPerFile {
+		return w.close()
+	}
+	return nil
+}
+
+func (w *archiveWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	gzErr := w.gz.Close()
+	fileErr := w.file.Close()
+	w.file, w.gz, w.encoder = nil, nil, nil
+	return errors.Join(gzErr, fileErr)
+}
+
+type archiveFile struct {
+	path        string
+	index       uint64
+	firstHeight uint64
+}
+
+func listArchiveFiles(dir string) ([]archiveFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]archiveFile, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, archiveFilePrefix) || !strings.HasSuffix(name, archiveFileSuffix) {
+			continue
+		}
+		index, firstHeight, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(name, archiveFilePrefix), archiveFileSuffix), "-")
+		if !ok {
+			continue
+		}
+
+		file := archiveFile{path: filepath.Join(dir, name)}
+		if file.index, err = strconv.ParseUint(index, 10, 64); err != nil {
+			continue
+		}
+		if file.firstHeight, err = strconv.ParseUint(firstHeight, 10, 64); err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].index < files[j].index })
+	return files, nil
+}
+
+/*
+readArchive
+streams the records of every file that may hold blocks from startBlockNumber on, in emission order,
+a file is skipped when the next one starts at or below startBlockNumber, fn returns false to stop
+*/
+func readArchive(dir string, startBlockNumber uint64, fn func(record *archiveRecord) bool) error {
+	files, err := listArchiveFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	for i, file := range files {
+		if i+1 < len(files) && files[i+1].firstHeight <= startBlockNumber {
+			continue
+		}
+
+		next, err := readArchiveFile(file.path, fn)
+		if err != nil {
+			return err
+		}
+		if !next {
+			return nil
+		}
+	}
+	return nil
+}
+
+func readArchiveFile(path string, fn func(record *archiveRecord) bool) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return false, fmt.Errorf("open archive %s err: %w", path, err)
+	}
+	defer gz.Close()
+
+	decoder := json.NewDecoder(gz)
+	for {
+		record := &archiveRecord{}
+		err = decoder.Decode(record)
+		if err == io.EOF {
+			return true, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// the recorder was killed in the middle of a block
+			log.Logger.Warn("archive file truncated", zap.String("path", path))
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("read archive %s err: %w", path, err)
+		}
+
+		if !fn(record) {
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package block_getter
+
+import (
+	"abchain_scan/types"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func newArchivedBlock(t *testing.T, height uint64, fork byte) *types.ParseBlockContext {
+	header := &ethtypes.Header{Number: new(big.Int).SetUint64(height), Time: 1000 + height, Difficulty: common.Big0, Extra: []byte{fork}}
+	rawBlockMap := toJsonMap(t, header)
+	rawBlockMap["hash"] = header.Hash().Hex()
+	rawBlockMap["transactions"] = []interface{}{}
+	rawBlock, err := json.Marshal(rawBlockMap)
+	require.NoError(t, err)
+
+	pbc, err := buildBlock(height, rawBlock, json.RawMessage("[]"))
+	require.NoError(t, err)
+	pbc.RawBlock, pbc.RawReceipts = rawBlock, json.RawMessage("[]")
+	return pbc
+}
+
+func replayAll(rg BlockGetter) []*types.ParseBlockContext {
+	var blocks []*types.ParseBlockContext
+	for pbc := rg.Next(); pbc != nil; pbc = rg.Next() {
+		blocks = append(blocks, pbc)
+	}
+	return blocks
+}
+
+func Test_ArchiveRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	w, err := newArchiveWriter(dir, 2)
+	require.NoError(t, err)
+
+	for height := uint64(100); height <= 103; height++ {
+		require.NoError(t, w.write(newArchivedBlock(t, height, 0)))
+	}
+	// 102 and 103 orphaned
+	reorged := newArchivedBlock(t, 102, 1)
+	reorged.Reorg = &types.Reorg{
+		AncestorHeight: 101,
+		OrphanedBlocks: []*types.OrphanedBlock{{Height: 102}, {Height: 103}},
+	}
+	require.NoError(t, w.write(reorged))
+	require.NoError(t, w.close())
+
+	// restarted recorder writes 102 again
+	w, err = newArchiveWriter(dir, 2)
+	require.NoError(t, err)
+	require.NoError(t, w.write(newArchivedBlock(t, 102, 1)))
+	require.NoError(t, w.write(newArchivedBlock(t, 103, 1)))
+	require.NoError(t, w.close())
+
+	files, err := listArchiveFiles(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 4)
+
+	rg := NewReplayBlockGetter(dir, nil)
+	rg.StartBackfill(rg.GetStartBlockNumber(100), 103, 100)
+	blocks := replayAll(rg)
+
+	heights := make([]uint64, len(blocks))
+	for i, pbc := range blocks {
+		heights[i] = pbc.HeightTime.Height
+		require.Equal(t, uint64(100+i), pbc.Sequence)
+	}
+	require.Equal(t, []uint64{100, 101, 102, 103, 102, 103}, heights)
+	require.Nil(t, blocks[3].Reorg)
+	require.Equal(t, 2, blocks[4].Reorg.Depth())
+	require.Equal(t, newArchivedBlock(t, 103, 1).BlockHash, blocks[5].BlockHash)
+
+	// files superseded by a later file starting below the start height are skipped
+	rg = NewReplayBlockGetter(dir, nil)
+	rg.StartDispatch(103, 103)
+	blocks = replayAll(rg)
+	require.Len(t, blocks, 1)
+	require.Equal(t, blocks[0].BlockHash, newArchivedBlock(t, 103, 1).BlockHash)
+}
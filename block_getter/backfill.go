@@ -0,0 +1,85 @@
+package block_getter
+
+import (
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"go.uber.org/zap"
+	"time"
+)
+
+const backfillReportInterval = 10 * time.Second
+
+/*
+GetBackfillStartBlockNumber
+resumes from the backfill checkpoint if it lies inside the range
+*/
+func (bg *blockGetter) GetBackfillStartBlockNumber(startBlockNumber, endBlockNumber uint64) uint64 {
+	finishedBlock := bg.cache.GetFinishedBlock()
+	if finishedBlock >= startBlockNumber && finishedBlock <= endBlockNumber {
+		return finishedBlock + 1
+	}
+	return startBlockNumber
+}
+
+/*
+StartBackfill
+dispatches [startBlockNumber, endBlockNumber] without following the chain head,
+the output is closed once the whole range is handed out, so Next returns nil at the end
+*/
+func (bg *blockGetter) StartBackfill(startBlockNumber, endBlockNumber, startSequence uint64) {
+	bg.outputSequence = startSequence
+	bg.seedHashHistory(startBlockNumber)
+
+	if startBlockNumber > endBlockNumber {
+		log.Logger.Info("backfill range already finished",
+			zap.Uint64("start", startBlockNumber),
+			zap.Uint64("end", endBlockNumber))
+		bg.doStop()
+		return
+	}
+
+	go bg.reportBackfillProgress(startBlockNumber, endBlockNumber)
+
+	go func() {
+		stopped, nextBlockHeight := bg.dispatchRange(startBlockNumber, endBlockNumber)
+		if stopped {
+			log.Logger.Info("backfill interrupted", zap.Uint64("nextBlockHeight", nextBlockHeight))
+		} else {
+			log.Logger.Info("backfill range dispatched",
+				zap.Uint64("start", startBlockNumber),
+				zap.Uint64("end", endBlockNumber))
+		}
+		bg.doStop()
+	}()
+}
+
+func (bg *blockGetter) reportBackfillProgress(startBlockNumber, endBlockNumber uint64) {
+	ticker := time.NewTicker(backfillReportInterval)
+	defer ticker.Stop()
+
+	total := float64(endBlockNumber - startBlockNumber + 1)
+	startAt := time.Now()
+	for range ticker.C {
+		emitted := bg.emittedHeight.Get()
+		if emitted < startBlockNumber {
+			continue
+		}
+
+		finished := float64(emitted - startBlockNumber + 1)
+		progress := finished / total
+		elapsed := time.Since(startAt)
+		eta := time.Duration(float64(elapsed) / finished * (total - finished))
+		metrics.BackfillProgress.Set(progress)
+
+		log.Logger.Info("backfill progress",
+			zap.Uint64("height", emitted),
+			zap.Uint64("end", endBlockNumber),
+			zap.Float64("progress", progress),
+			zap.Float64("blocks per second", finished/elapsed.Seconds()),
+			zap.Duration("eta", eta.Round(time.Second)))
+
+		if emitted >= endBlockNumber || bg.isStopped() {
+			return
+		}
+	}
+}
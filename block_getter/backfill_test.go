@@ -0,0 +1,33 @@
+package block_getter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_StartBackfill_GiveUpAfterMaxAttempts(t *testing.T) {
+	fatal, logs := catchFatal(t)
+	bg, api := newFailingBlockGetter(t, 2)
+
+	bg.Start()
+	bg.StartBackfill(100, 100, 100)
+
+	select {
+	case msg := <-fatal:
+		require.Equal(t, "get block still failing, give up", msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("backfill block not given up")
+	}
+	require.Equal(t, int32(3), api.getBlockCalls.Load())
+
+	// the range is dispatched, the output stays open while the block is missing
+	require.Eventually(t, func() bool {
+		return logs.FilterMessage("block inputQueue is closed").Len() == 1
+	}, 5*time.Second, time.Millisecond)
+	select {
+	case pbc := <-bg.outputBuffer:
+		t.Fatalf("unexpected block %v", pbc)
+	default:
+	}
+}
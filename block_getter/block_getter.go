@@ -5,18 +5,18 @@ import (
 	"abchain_scan/config"
 	"abchain_scan/log"
 	"abchain_scan/metrics"
+	"abchain_scan/rpc_pool"
 	"abchain_scan/sequencer"
 	"abchain_scan/types"
 	"context"
+	"encoding/json"
 	"github.com/avast/retry-go/v4"
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/panjf2000/ants/v2"
 	"go.uber.org/zap"
-	"math/big"
 	"sync"
 	"time"
 )
@@ -24,30 +24,51 @@ import (
 type BlockGetter interface {
 	Start()
 	GetStartBlockNumber(startBlockNumber uint64) uint64
-	StartDispatch(startBlockNumber uint64)
+	// StartDispatch startSequence is the sequence of the first block handed out
+	StartDispatch(startBlockNumber, startSequence uint64)
+	GetBackfillStartBlockNumber(startBlockNumber, endBlockNumber uint64) uint64
+	StartBackfill(startBlockNumber, endBlockNumber, startSequence uint64)
 	Stop()
 	GetBlockAsync(blockNumber uint64)
 	Next() *types.ParseBlockContext
 }
 
 type blockGetter struct {
-	ctx             context.Context
-	wsEthClient     *ethclient.Client
-	ethClientPool   EthClientPool
-	inputQueue      chan uint64
-	outputBuffer    chan *types.ParseBlockContext
-	workPool        *ants.Pool
-	cache           cache.BlockCache
-	stopped         SafeVar[bool]
-	blockHeaderChan chan *ethtypes.Header
-	blockSequencer  sequencer.Sequencer
-	headerHeight    SafeVar[uint64]
-	retryParams     *config.RetryParams
+	ctx              context.Context
+	ethClient        *ethclient.Client
+	wsEthClient      *ethclient.Client
+	rpcPool          rpc_pool.Pool
+	topics           []common.Hash
+	logRanges        logRangeCache
+	inputQueue       chan uint64
+	outputBuffer     chan *types.ParseBlockContext
+	workPool         *ants.Pool
+	cache            cache.BlockCache
+	stopped          SafeVar[bool]
+	blockSequencer   sequencer.Sequencer
+	headerHeight     SafeVar[uint64]
+	taggedHeight     SafeVar[uint64]
+	emittedHeight    SafeVar[uint64]
+	dispatchedHeight SafeVar[uint64]
+	retryQueue       *retryQueue
+	retryParams      *config.RetryParams
+	commitMu         sync.Mutex
+	hashHistory      *blockHashHistory
+	outputSequence   uint64
+	archive          *archiveWriter
+	concurrency      *concurrencyController
 }
 
+/*
+NewBlockGetter
+wsEthClient is optional, the chain head is polled over ethClient without it
+*/
 func NewBlockGetter(
+	ethClient *ethclient.Client,
 	wsEthClient *ethclient.Client,
-	cache cache.BlockCache,
+	rpcPool rpc_pool.Pool,
+	topics []common.Hash,
+	cache cache.Cache,
 	blockSequencer sequencer.Sequencer,
 	retryParams *config.RetryParams,
 ) BlockGetter {
@@ -56,40 +77,90 @@ func NewBlockGetter(
 		log.Logger.Fatal("ants pool(BlockGetter) init err", zap.Error(err))
 	}
 
-	ethClientPool_ := NewEthClientPool(config.G.Chain.WsEndpoint, 16)
+	var concurrency *concurrencyController
+	if config.G.BlockGetter.AdaptiveConcurrency.Enabled {
+		concurrency = newConcurrencyController(&config.G.BlockGetter.AdaptiveConcurrency)
+	}
+
+	var archive *archiveWriter
+	if archiveConf := config.G.BlockGetter.Archive; archiveConf.Mode == ArchiveModeRecord {
+		if isLogIngestion() {
+			log.Logger.Fatal("archive record mode needs ingestion_mode blocks")
+		}
+		archive, err = newArchiveWriter(archiveConf.Dir, archiveConf.BlocksPerFile)
+		if err != nil {
+			log.Logger.Fatal("archive writer init err", zap.String("dir", archiveConf.Dir), zap.Error(err))
+		}
+	}
 
 	return &blockGetter{
-		ctx:             context.Background(),
-		wsEthClient:     wsEthClient,
-		ethClientPool:   ethClientPool_,
-		inputQueue:      make(chan uint64, config.G.BlockGetter.QueueSize),
-		outputBuffer:    make(chan *types.ParseBlockContext, 10),
-		workPool:        workPool,
-		cache:           cache,
-		blockHeaderChan: make(chan *ethtypes.Header, 100),
-		blockSequencer:  blockSequencer,
-		retryParams:     retryParams,
+		ctx:            context.Background(),
+		ethClient:      ethClient,
+		wsEthClient:    wsEthClient,
+		rpcPool:        rpcPool,
+		topics:         topics,
+		inputQueue:     make(chan uint64, config.G.BlockGetter.QueueSize),
+		outputBuffer:   make(chan *types.ParseBlockContext, 10),
+		workPool:       workPool,
+		cache:          cache,
+		blockSequencer: blockSequencer,
+		retryParams:    retryParams,
+		hashHistory:    newBlockHashHistory(config.G.BlockGetter.ReorgDepth, cache),
+		retryQueue:     newRetryQueue(&config.G.BlockGetter.FailedBlockRetry),
+		archive:        archive,
+		concurrency:    concurrency,
 	}
 }
 
 func (bg *blockGetter) Commit(x sequencer.Sequenceable) {
-	bg.outputBuffer <- x.(*types.ParseBlockContext)
+	pbc := x.(*types.ParseBlockContext)
+
+	bg.commitMu.Lock()
+	defer bg.commitMu.Unlock()
+
+	if !bg.hashHistory.isFork(pbc) {
+		bg.emit(pbc)
+		return
+	}
+
+	for _, canonical := range bg.handleReorg(pbc) {
+		bg.emit(canonical)
+	}
+}
+
+func (bg *blockGetter) emit(pbc *types.ParseBlockContext) {
+	bg.hashHistory.add(pbc.HeightTime.Height, pbc.BlockHash)
+	pbc.Sequence = bg.outputSequence
+	bg.outputSequence++
+	bg.emittedHeight.Set(pbc.HeightTime.Height)
+	if bg.archive != nil {
+		if err := bg.archive.write(pbc); err != nil {
+			log.Logger.Fatal("archive block err", zap.Uint64("height", pbc.HeightTime.Height), zap.Error(err))
+		}
+		pbc.RawBlock, pbc.RawReceipts = nil, nil
+	}
+	bg.outputBuffer <- pbc
 }
 
 func (bg *blockGetter) getBlock(blockNumber uint64) (*types.ParseBlockContext, error) {
 	var (
-		block          *ethtypes.Block
-		blockReceipts  []*ethtypes.Receipt
+		rawBlock       json.RawMessage
+		rawReceipts    json.RawMessage
 		getBlockErr    error
 		getReceiptsErr error
 		wg             sync.WaitGroup
 	)
 
+	blockNumberArg := hexutil.EncodeUint64(blockNumber)
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
 		now := time.Now()
-		block, getBlockErr = bg.ethClientPool.Get().BlockByNumber(bg.ctx, big.NewInt(int64(blockNumber)))
+		rawBlock, getBlockErr = rpc_pool.Do(bg.rpcPool, rpc_pool.RoleBlocks, func(client *ethclient.Client) (json.RawMessage, error) {
+			var raw json.RawMessage
+			err := client.Client().CallContext(bg.ctx, &raw, "eth_getBlockByNumber", blockNumberArg, true)
+			return raw, err
+		})
 		if getBlockErr == nil {
 			duration := time.Since(now)
 			metrics.GetBlockDurationMs.Observe(float64(duration.Milliseconds()))
@@ -99,7 +170,11 @@ func (bg *blockGetter) getBlock(blockNumber uint64) (*types.ParseBlockContext, e
 	go func() {
 		defer wg.Done()
 		now := time.Now()
-		blockReceipts, getReceiptsErr = bg.ethClientPool.Get().BlockReceipts(bg.ctx, rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(blockNumber)))
+		rawReceipts, getReceiptsErr = rpc_pool.Do(bg.rpcPool, rpc_pool.RoleBlocks, func(client *ethclient.Client) (json.RawMessage, error) {
+			var raw json.RawMessage
+			err := client.Client().CallContext(bg.ctx, &raw, "eth_getBlockReceipts", blockNumberArg)
+			return raw, err
+		})
 		if getReceiptsErr == nil {
 			duration := time.Since(now)
 			metrics.GetBlockReceiptsDurationMs.Observe(float64(duration.Milliseconds()))
@@ -114,22 +189,58 @@ func (bg *blockGetter) getBlock(blockNumber uint64) (*types.ParseBlockContext, e
 		return nil, getReceiptsErr
 	}
 
-	metrics.BlockDelay.Observe(time.Now().Sub(time.Unix((int64)(block.Time()), 0)).Seconds())
+	pbc, err := buildBlock(blockNumber, rawBlock, rawReceipts)
+	if err != nil {
+		return nil, err
+	}
+	metrics.BlockDelay.Observe(time.Now().Sub(pbc.HeightTime.Time).Seconds())
+
+	if bg.archive != nil {
+		pbc.RawBlock, pbc.RawReceipts = rawBlock, rawReceipts
+	}
+	return pbc, nil
+}
+
+func buildBlock(blockNumber uint64, rawBlock, rawReceipts json.RawMessage) (*types.ParseBlockContext, error) {
+	block, err := decodeBlock(rawBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	blockReceipts, receiptFroms, err := decodeReceipts(rawReceipts)
+	if err != nil {
+		return nil, err
+	}
 
-	transactions := block.Transactions()
 	return &types.ParseBlockContext{
-		Block:           block,
-		Transactions:    transactions,
-		TransactionsLen: uint(len(transactions)),
+		Sequence:        blockNumber,
+		BlockHash:       block.hash,
+		ParentHash:      block.header.ParentHash,
+		Block:           ethtypes.NewBlockWithHeader(block.header),
+		Transactions:    block.transactions,
+		TransactionsLen: uint(len(block.transactions)),
 		BlockReceipts:   blockReceipts,
-		HeightTime:      types.GetBlockHeightTime(block.Header()),
-		TxSenders:       make([]*common.Address, block.Transactions().Len()),
+		HeightTime:      types.GetBlockHeightTime(block.header),
+		TxSenders:       collectTxSenders(block, blockReceipts, receiptFroms),
 	}, nil
 }
 
 func (bg *blockGetter) getBlockWithRetry(blockNumber uint64) (*types.ParseBlockContext, error) {
 	return retry.DoWithData(func() (*types.ParseBlockContext, error) {
-		return bg.getBlock(blockNumber)
+		var (
+			pbc   *types.ParseBlockContext
+			err   error
+			start = time.Now()
+		)
+		if isLogIngestion() {
+			pbc, err = bg.getBlockByLogs(blockNumber)
+		} else {
+			pbc, err = bg.getBlock(blockNumber)
+		}
+		if bg.concurrency != nil {
+			bg.concurrency.observe(time.Since(start), err)
+		}
+		return pbc, err
 	}, bg.retryParams.Attempts, bg.retryParams.Delay)
 }
 
@@ -142,6 +253,8 @@ func (bg *blockGetter) Next() *types.ParseBlockContext {
 }
 
 func (bg *blockGetter) Start() {
+	bg.startConcurrencyController()
+
 	go func() {
 		wg := &sync.WaitGroup{}
 	tagFor:
@@ -154,25 +267,17 @@ func (bg *blockGetter) Start() {
 				}
 
 				wg.Add(1)
-				bg.workPool.Submit(func() {
-					defer wg.Done()
-
-					log.Logger.Info("get block start", zap.Uint64("block_number", blockNumber))
-					bw, err := bg.getBlockWithRetry(blockNumber)
-					if err != nil {
-						log.Logger.Error("get block err", zap.Uint64("blockNumber", blockNumber), zap.Error(err))
-						return
-					}
-
-					log.Logger.Info("get block success", zap.Uint64("blockNumber", blockNumber))
-					metrics.BlockQueueSize.Set(float64(len(bg.outputBuffer)))
-					bg.blockSequencer.CommitWithSequence(bw, bg)
-				})
+				bg.submitGetBlock(blockNumber, wg)
 			}
 		}
 
 		wg.Wait()
 		log.Logger.Info("all block getter task finish")
+		if bg.archive != nil {
+			if err := bg.archive.close(); err != nil {
+				log.Logger.Error("close archive err", zap.Error(err))
+			}
+		}
 		close(bg.outputBuffer)
 	}()
 }
@@ -187,7 +292,7 @@ func (bg *blockGetter) GetStartBlockNumber(startBlockNumber uint64) uint64 {
 		return finishedBlock + 1
 	}
 
-	newestBlockNumber, err := bg.wsEthClient.BlockNumber(bg.ctx)
+	newestBlockNumber, err := bg.ethClient.BlockNumber(bg.ctx)
 	if err != nil {
 		log.Logger.Fatal("ethClient.BlockNumber() err", zap.Error(err))
 	}
@@ -205,89 +310,30 @@ func (bg *blockGetter) getHeaderHeight() uint64 {
 	return bg.headerHeight.Get()
 }
 
-func (bg *blockGetter) subscribeNewHead() (ethereum.Subscription, <-chan error, error) {
-	sub, err := bg.wsEthClient.SubscribeNewHead(bg.ctx, bg.blockHeaderChan)
-	if err != nil {
-		return nil, nil, err
+func (bg *blockGetter) newHeadSource() HeadSource {
+	polling := newPollingHeadSource(bg.ctx, bg.ethClient,
+		time.Duration(config.G.BlockGetter.PollIntervalMs)*time.Millisecond)
+	if config.G.BlockGetter.HeadSource == HeadSourcePoll || bg.wsEthClient == nil {
+		return polling
 	}
-	return sub, sub.Err(), nil
-}
 
-func (bg *blockGetter) reconnectWithBackoff() (ethereum.Subscription, <-chan error) {
-	retryDelay := time.Second * 1
-	maxRetryDelay := time.Second * 10
-
-	for {
-		sub, errChan, err := bg.subscribeNewHead()
-		if err == nil {
-			log.Logger.Info("WebSocket reconnected successfully")
-			return sub, errChan
-		}
-
-		log.Logger.Error("WebSocket reconnect failed",
-			zap.Error(err),
-			zap.Duration("nextRetry", retryDelay),
-		)
-		time.Sleep(retryDelay)
+	return newWsHeadSource(bg.ctx, bg.wsEthClient, config.G.BlockGetter.WsMaxReconnectFailures, polling)
+}
 
-		retryDelay *= 2
-		if retryDelay > maxRetryDelay {
-			retryDelay = maxRetryDelay
-		}
-	}
+func (bg *blockGetter) onNewHead(height uint64) {
+	log.Logger.Info("New block", zap.Uint64("height", height))
+	bg.setHeaderHeight(height)
+	metrics.NewestHeight.Set(float64(height))
 }
 
-func (bg *blockGetter) startSubscribeNewHead() {
-	headerHeight, err := bg.wsEthClient.BlockNumber(bg.ctx)
+func (bg *blockGetter) startFollowHead() {
+	headerHeight, err := bg.ethClient.BlockNumber(bg.ctx)
 	if err != nil {
 		log.Logger.Fatal("HeightBigInt() err", zap.Error(err))
 	}
 	bg.setHeaderHeight(headerHeight)
 
-	sub, errChan, err := bg.subscribeNewHead()
-	if err != nil {
-		log.Logger.Fatal("subscribeNewHead() err", zap.Error(err))
-	}
-
-	go func() {
-		noBlockTimeout := time.NewTimer(10 * time.Second)
-		defer noBlockTimeout.Stop()
-
-		resetConnection := func() {
-			noBlockTimeout.Stop()
-			select {
-			case <-noBlockTimeout.C:
-			default:
-			}
-			sub.Unsubscribe()
-
-			sub, errChan = bg.reconnectWithBackoff()
-			noBlockTimeout.Reset(10 * time.Second)
-		}
-
-		for {
-			select {
-			case err = <-errChan:
-				log.Logger.Error("WebSocket error", zap.Error(err))
-				resetConnection()
-			case blockHeader := <-bg.blockHeaderChan:
-				height := blockHeader.Number.Uint64()
-				log.Logger.Info("New block", zap.Uint64("height", height))
-				bg.setHeaderHeight(height)
-				metrics.NewestHeight.Set(float64(height))
-
-				noBlockTimeout.Stop()
-				select {
-				case <-noBlockTimeout.C:
-				default:
-				}
-				noBlockTimeout.Reset(10 * time.Second)
-			case <-noBlockTimeout.C:
-				log.Logger.Warn("No new blocks for 10s, reconnect WebSocket")
-				resetConnection()
-			}
-		}
-	}()
+	bg.newHeadSource().Start(bg.onNewHead)
 }
 
 func (bg *blockGetter) dispatchRange(from, to uint64) (stopped bool, nextBlock uint64) {
@@ -295,35 +341,47 @@ func (bg *blockGetter) dispatchRange(from, to uint64) (stopped bool, nextBlock u
 		if bg.isStopped() {
 			return true, i
 		}
+		bg.blockSequencer.WaitForWindow(i)
 		bg.GetBlockAsync(i)
+		bg.dispatchedHeight.Set(i)
 	}
 	return false, 0
 }
 
-func (bg *blockGetter) StartDispatch(startBlockNumber uint64) {
-	bg.startSubscribeNewHead()
+func (bg *blockGetter) StartDispatch(startBlockNumber, startSequence uint64) {
+	bg.outputSequence = startSequence
+	bg.seedHashHistory(startBlockNumber)
+	bg.startFollowHead()
+	bg.startDispatchPolicy()
 
 	go func() {
 		cur := startBlockNumber
 		for {
-			headerHeight := bg.getHeaderHeight()
-			if headerHeight < cur {
+			dispatchHeight := bg.getDispatchHeight()
+			if dispatchHeight < cur {
 				time.Sleep(100 * time.Millisecond)
 				continue
 			}
 
-			stopped, nextBlockHeight := bg.dispatchRange(cur, headerHeight)
+			stopped, nextBlockHeight := bg.dispatchRange(cur, dispatchHeight)
 			if stopped {
 				log.Logger.Info("dispatch interrupted", zap.Uint64("nextBlockHeight", nextBlockHeight))
 				bg.doStop()
 				return
 			}
 
-			cur = headerHeight + 1
+			cur = dispatchHeight + 1
 		}
 	}()
 }
 
+func (bg *blockGetter) seedHashHistory(startBlockNumber uint64) {
+	bg.commitMu.Lock()
+	seeded := bg.hashHistory.seed(startBlockNumber)
+	bg.commitMu.Unlock()
+	log.Logger.Info("block hash history seeded", zap.Uint64("start", startBlockNumber), zap.Int("blocks", seeded))
+}
+
 func (bg *blockGetter) Stop() {
 	bg.stopped.Set(true)
 }
@@ -0,0 +1,145 @@
+package block_getter
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"abchain_scan/rpc_pool"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+type fetchStats struct {
+	requests     int
+	errors       int
+	rateLimited  int
+	totalLatency time.Duration
+}
+
+func (s fetchStats) errorRate() float64 {
+	if s.requests == 0 {
+		return 0
+	}
+	return float64(s.errors) / float64(s.requests)
+}
+
+func (s fetchStats) avgLatency() time.Duration {
+	if s.requests == 0 {
+		return 0
+	}
+	return s.totalLatency / time.Duration(s.requests)
+}
+
+/*
+concurrencyController
+collects the result of every block fetch and tunes the work pool once per interval
+*/
+type concurrencyController struct {
+	conf  *config.AdaptiveConcurrencyConf
+	mu    sync.Mutex
+	stats fetchStats
+}
+
+func newConcurrencyController(conf *config.AdaptiveConcurrencyConf) *concurrencyController {
+	return &concurrencyController{conf: conf}
+}
+
+func (c *concurrencyController) observe(latency time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.requests++
+	c.stats.totalLatency += latency
+	if err != nil {
+		c.stats.errors++
+		if rpc_pool.IsRateLimitErr(err) {
+			c.stats.rateLimited++
+		}
+	}
+}
+
+func (c *concurrencyController) takeStats() fetchStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	c.stats = fetchStats{}
+	return stats
+}
+
+/*
+nextPoolSize
+AIMD, halves on rate limits or too many errors, shrinks by one at the head,
+grows by one while more blocks are behind than being fetched and the rpc keeps up
+*/
+func nextPoolSize(cur int, lag uint64, stats fetchStats, conf *config.AdaptiveConcurrencyConf) int {
+	minSize := max(conf.MinPoolSize, 1)
+	maxSize := max(conf.MaxPoolSize, minSize)
+	target := time.Duration(conf.TargetLatencyMs) * time.Millisecond
+
+	next := cur
+	switch {
+	case stats.rateLimited > 0 || stats.errorRate() > conf.MaxErrorRate:
+		next = cur / 2
+	case lag <= 1:
+		next = cur - 1
+	case target > 0 && stats.avgLatency() > target:
+		// the rpc is already slow, hold
+	case lag > uint64(cur):
+		next = cur + 1
+	}
+	return min(max(next, minSize), maxSize)
+}
+
+// fetchLag blocks dispatched or on chain but not emitted yet
+func (bg *blockGetter) fetchLag() uint64 {
+	top := max(bg.getHeaderHeight(), bg.dispatchedHeight.Get())
+	emitted := bg.emittedHeight.Get()
+	if top <= emitted {
+		return 0
+	}
+	return top - emitted
+}
+
+func (bg *blockGetter) startConcurrencyController() {
+	metrics.BlockGetterConcurrency.Set(float64(bg.workPool.Cap()))
+	if bg.concurrency == nil {
+		return
+	}
+
+	interval := time.Duration(bg.concurrency.conf.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if bg.isStopped() {
+				return
+			}
+
+			cur := bg.workPool.Cap()
+			lag := bg.fetchLag()
+			stats := bg.concurrency.takeStats()
+			next := nextPoolSize(cur, lag, stats, bg.concurrency.conf)
+			if next == cur {
+				continue
+			}
+
+			log.Logger.Info("tune block getter concurrency",
+				zap.Int("from", cur),
+				zap.Int("to", next),
+				zap.Uint64("lag", lag),
+				zap.Int("requests", stats.requests),
+				zap.Int("errors", stats.errors),
+				zap.Int("rate limited", stats.rateLimited),
+				zap.Duration("avg latency", stats.avgLatency()))
+			bg.workPool.Tune(next)
+			metrics.BlockGetterConcurrency.Set(float64(next))
+		}
+	}()
+}
@@ -0,0 +1,51 @@
+package block_getter
+
+import (
+	"abchain_scan/config"
+	"errors"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_NextPoolSize(t *testing.T) {
+	conf := &config.AdaptiveConcurrencyConf{
+		MinPoolSize:     1,
+		MaxPoolSize:     8,
+		TargetLatencyMs: 1000,
+		MaxErrorRate:    0.1,
+	}
+	fast := fetchStats{requests: 10, totalLatency: 10 * 100 * time.Millisecond}
+
+	// catching up, additive increase up to max
+	require.Equal(t, 5, nextPoolSize(4, 100, fast, conf))
+	require.Equal(t, 8, nextPoolSize(8, 100, fast, conf))
+
+	// at the head, back to one at a time
+	require.Equal(t, 3, nextPoolSize(4, 1, fast, conf))
+	require.Equal(t, 1, nextPoolSize(1, 0, fetchStats{}, conf))
+
+	// slow rpc, hold
+	slow := fetchStats{requests: 10, totalLatency: 10 * 2 * time.Second}
+	require.Equal(t, 4, nextPoolSize(4, 100, slow, conf))
+
+	// rate limited or too many errors, multiplicative decrease
+	require.Equal(t, 4, nextPoolSize(8, 100, fetchStats{requests: 10, errors: 1, rateLimited: 1}, conf))
+	require.Equal(t, 2, nextPoolSize(4, 100, fetchStats{requests: 10, errors: 2}, conf))
+	require.Equal(t, 1, nextPoolSize(1, 100, fetchStats{requests: 10, errors: 2}, conf))
+}
+
+func Test_ConcurrencyControllerObserve(t *testing.T) {
+	c := newConcurrencyController(&config.AdaptiveConcurrencyConf{})
+	c.observe(100*time.Millisecond, nil)
+	c.observe(300*time.Millisecond, rpc.HTTPError{StatusCode: 429, Status: "429 Too Many Requests"})
+	c.observe(200*time.Millisecond, errors.New("connection reset by peer"))
+
+	stats := c.takeStats()
+	require.Equal(t, 3, stats.requests)
+	require.Equal(t, 2, stats.errors)
+	require.Equal(t, 1, stats.rateLimited)
+	require.Equal(t, 200*time.Millisecond, stats.avgLatency())
+	require.Equal(t, fetchStats{}, c.takeStats())
+}
@@ -0,0 +1,97 @@
+package block_getter
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.uber.org/zap"
+	"math/big"
+	"time"
+)
+
+const (
+	DispatchPolicyHead          = "head"
+	DispatchPolicyConfirmations = "confirmations"
+	DispatchPolicySafe          = "safe"
+	DispatchPolicyFinalized     = "finalized"
+)
+
+const taggedHeightPollInterval = 2 * time.Second
+
+func (bg *blockGetter) startDispatchPolicy() {
+	switch config.G.BlockGetter.DispatchPolicy {
+	case "", DispatchPolicyHead, DispatchPolicyConfirmations:
+	case DispatchPolicySafe:
+		bg.startPollTaggedHeight(rpc.SafeBlockNumber)
+	case DispatchPolicyFinalized:
+		bg.startPollTaggedHeight(rpc.FinalizedBlockNumber)
+	default:
+		log.Logger.Fatal("unknown dispatch policy", zap.String("dispatch_policy", config.G.BlockGetter.DispatchPolicy))
+	}
+
+	log.Logger.Info("dispatch policy",
+		zap.String("dispatch_policy", config.G.BlockGetter.DispatchPolicy),
+		zap.Uint64("confirmations", config.G.BlockGetter.Confirmations))
+}
+
+func (bg *blockGetter) getTaggedHeight(tag rpc.BlockNumber) (uint64, error) {
+	header, err := bg.ethClient.HeaderByNumber(bg.ctx, big.NewInt(tag.Int64()))
+	if err != nil {
+		return 0, err
+	}
+	return header.Number.Uint64(), nil
+}
+
+func (bg *blockGetter) startPollTaggedHeight(tag rpc.BlockNumber) {
+	height, err := bg.getTaggedHeight(tag)
+	if err != nil {
+		log.Logger.Fatal("get tagged block err", zap.String("tag", tag.String()), zap.Error(err))
+	}
+	bg.taggedHeight.Set(height)
+
+	go func() {
+		ticker := time.NewTicker(taggedHeightPollInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			height, err = bg.getTaggedHeight(tag)
+			if err != nil {
+				log.Logger.Error("get tagged block err", zap.String("tag", tag.String()), zap.Error(err))
+				continue
+			}
+
+			if height > bg.taggedHeight.Get() {
+				bg.taggedHeight.Set(height)
+			}
+		}
+	}()
+}
+
+/*
+getDispatchHeight
+the highest block allowed to be dispatched under the configured policy
+*/
+func (bg *blockGetter) getDispatchHeight() uint64 {
+	headerHeight := bg.getHeaderHeight()
+
+	var dispatchHeight uint64
+	switch config.G.BlockGetter.DispatchPolicy {
+	case DispatchPolicyConfirmations:
+		if headerHeight > config.G.BlockGetter.Confirmations {
+			dispatchHeight = headerHeight - config.G.BlockGetter.Confirmations
+		}
+	case DispatchPolicySafe, DispatchPolicyFinalized:
+		dispatchHeight = bg.taggedHeight.Get()
+	default:
+		dispatchHeight = headerHeight
+	}
+
+	if headerHeight > dispatchHeight {
+		metrics.DispatchLag.Set(float64(headerHeight - dispatchHeight))
+	} else {
+		metrics.DispatchLag.Set(0)
+	}
+
+	return dispatchHeight
+}
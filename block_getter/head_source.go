@@ -0,0 +1,203 @@
+package block_getter
+
+import (
+	"abchain_scan/log"
+	"context"
+	"github.com/ethereum/go-ethereum"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	HeadSourceWs   = "ws"
+	HeadSourcePoll = "poll"
+)
+
+/*
+HeadSource
+follows the chain head and calls onHead with every new height
+*/
+type HeadSource interface {
+	Start(onHead func(height uint64))
+}
+
+// headSubscriber the websocket client subscribing newHeads
+type headSubscriber interface {
+	SubscribeNewHead(ctx context.Context, ch chan<- *ethtypes.Header) (ethereum.Subscription, error)
+}
+
+// blockNumberReader the http client polling the head
+type blockNumberReader interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
+type wsHeadSource struct {
+	ctx                  context.Context
+	wsEthClient          headSubscriber
+	blockHeaderChan      chan *ethtypes.Header
+	maxReconnectFailures int
+	fallback             HeadSource
+}
+
+/*
+newWsHeadSource
+subscribes newHeads over websocket, switches to fallback when the subscription
+can't be made, or reconnecting failed maxReconnectFailures times in a row
+*/
+func newWsHeadSource(ctx context.Context, wsEthClient headSubscriber, maxReconnectFailures int, fallback HeadSource) HeadSource {
+	return &wsHeadSource{
+		ctx:                  ctx,
+		wsEthClient:          wsEthClient,
+		blockHeaderChan:      make(chan *ethtypes.Header, 100),
+		maxReconnectFailures: maxReconnectFailures,
+		fallback:             fallback,
+	}
+}
+
+func (s *wsHeadSource) subscribeNewHead() (ethereum.Subscription, <-chan error, error) {
+	sub, err := s.wsEthClient.SubscribeNewHead(s.ctx, s.blockHeaderChan)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sub, sub.Err(), nil
+}
+
+func (s *wsHeadSource) reconnectWithBackoff() (ethereum.Subscription, <-chan error, bool) {
+	retryDelay := time.Second * 1
+	maxRetryDelay := time.Second * 10
+
+	for failures := 1; ; failures++ {
+		sub, errChan, err := s.subscribeNewHead()
+		if err == nil {
+			log.Logger.Info("WebSocket reconnected successfully")
+			return sub, errChan, true
+		}
+
+		log.Logger.Error("WebSocket reconnect failed",
+			zap.Error(err),
+			zap.Int("failures", failures),
+			zap.Duration("nextRetry", retryDelay),
+		)
+		if s.fallback != nil && s.maxReconnectFailures > 0 && failures >= s.maxReconnectFailures {
+			return nil, nil, false
+		}
+		time.Sleep(retryDelay)
+
+		retryDelay *= 2
+		if retryDelay > maxRetryDelay {
+			retryDelay = maxRetryDelay
+		}
+	}
+}
+
+func (s *wsHeadSource) startFallback(onHead func(height uint64)) {
+	log.Logger.Warn("WebSocket head source unavailable, fall back to polling")
+	s.fallback.Start(onHead)
+}
+
+func (s *wsHeadSource) Start(onHead func(height uint64)) {
+	sub, errChan, err := s.subscribeNewHead()
+	if err != nil {
+		if s.fallback == nil {
+			log.Logger.Fatal("subscribeNewHead() err", zap.Error(err))
+		}
+		log.Logger.Error("subscribeNewHead() err", zap.Error(err))
+		s.startFallback(onHead)
+		return
+	}
+
+	go func() {
+		noBlockTimeout := time.NewTimer(10 * time.Second)
+		defer noBlockTimeout.Stop()
+
+		resetConnection := func() bool {
+			noBlockTimeout.Stop()
+			select {
+			case <-noBlockTimeout.C:
+			default:
+			}
+			sub.Unsubscribe()
+
+			var ok bool
+			sub, errChan, ok = s.reconnectWithBackoff()
+			if !ok {
+				s.startFallback(onHead)
+				return false
+			}
+			noBlockTimeout.Reset(10 * time.Second)
+			return true
+		}
+
+		for {
+			select {
+			case err = <-errChan:
+				log.Logger.Error("WebSocket error", zap.Error(err))
+				if !resetConnection() {
+					return
+				}
+			case blockHeader := <-s.blockHeaderChan:
+				onHead(blockHeader.Number.Uint64())
+
+				noBlockTimeout.Stop()
+				select {
+				case <-noBlockTimeout.C:
+				default:
+				}
+				noBlockTimeout.Reset(10 * time.Second)
+			case <-noBlockTimeout.C:
+				log.Logger.Warn("No new blocks for 10s, reconnect WebSocket")
+				if !resetConnection() {
+					return
+				}
+			}
+		}
+	}()
+}
+
+type pollingHeadSource struct {
+	ctx      context.Context
+	ethCli   blockNumberReader
+	interval time.Duration
+}
+
+/*
+newPollingHeadSource
+polls eth_blockNumber, for providers that only offer http
+*/
+func newPollingHeadSource(ctx context.Context, ethCli blockNumberReader, interval time.Duration) HeadSource {
+	return &pollingHeadSource{
+		ctx:      ctx,
+		ethCli:   ethCli,
+		interval: interval,
+	}
+}
+
+func (s *pollingHeadSource) Start(onHead func(height uint64)) {
+	log.Logger.Info("poll chain head", zap.Duration("interval", s.interval))
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		var lastHeight uint64
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			height, err := s.ethCli.BlockNumber(s.ctx)
+			if err != nil {
+				log.Logger.Error("poll block number err", zap.Error(err))
+				continue
+			}
+
+			if height > lastHeight {
+				lastHeight = height
+				onHead(height)
+			}
+		}
+	}()
+}
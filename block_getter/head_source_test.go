@@ -0,0 +1,121 @@
+package block_getter
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSubscription struct {
+	errChan chan error
+}
+
+func (s *testSubscription) Unsubscribe() {}
+
+func (s *testSubscription) Err() <-chan error {
+	return s.errChan
+}
+
+// testHeadSubscriber fails the subscriptions after the first ok ones
+type testHeadSubscriber struct {
+	mu    sync.Mutex
+	ok    int
+	calls int
+	sub   *testSubscription
+	ch    chan<- *ethtypes.Header
+}
+
+func (s *testHeadSubscriber) SubscribeNewHead(ctx context.Context, ch chan<- *ethtypes.Header) (ethereum.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls > s.ok {
+		return nil, errors.New("dial ws failed")
+	}
+	s.ch = ch
+	s.sub = &testSubscription{errChan: make(chan error, 1)}
+	return s.sub, nil
+}
+
+type testHeadSource struct {
+	started chan struct{}
+}
+
+func (s *testHeadSource) Start(onHead func(height uint64)) {
+	close(s.started)
+}
+
+type testBlockNumberReader struct {
+	mu      sync.Mutex
+	heights []uint64
+}
+
+func (r *testBlockNumberReader) BlockNumber(ctx context.Context) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.heights) == 0 {
+		return 0, errors.New("no block number")
+	}
+	height := r.heights[0]
+	r.heights = r.heights[1:]
+	return height, nil
+}
+
+func Test_WsHeadSource_FallbackWhenSubscribeFails(t *testing.T) {
+	fallback := &testHeadSource{started: make(chan struct{})}
+	newWsHeadSource(context.Background(), &testHeadSubscriber{}, 3, fallback).Start(func(height uint64) {})
+
+	select {
+	case <-fallback.started:
+	case <-time.After(time.Second):
+		t.Fatal("polling not started")
+	}
+}
+
+func Test_WsHeadSource_FallbackAfterReconnectFailures(t *testing.T) {
+	subscriber := &testHeadSubscriber{ok: 1}
+	fallback := &testHeadSource{started: make(chan struct{})}
+	heads := make(chan uint64, 1)
+	newWsHeadSource(context.Background(), subscriber, 1, fallback).Start(func(height uint64) {
+		heads <- height
+	})
+
+	subscriber.mu.Lock()
+	subscriber.ch <- &ethtypes.Header{Number: big.NewInt(100)}
+	subscriber.mu.Unlock()
+	require.Equal(t, uint64(100), <-heads)
+
+	// the subscription drops and reconnecting fails
+	subscriber.sub.errChan <- errors.New("ws closed")
+	select {
+	case <-fallback.started:
+	case <-time.After(time.Second):
+		t.Fatal("polling not started")
+	}
+	require.Equal(t, 2, subscriber.calls)
+}
+
+func Test_PollingHeadSource(t *testing.T) {
+	reader := &testBlockNumberReader{heights: []uint64{10, 10, 9, 12}}
+	heads := make(chan uint64, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	newPollingHeadSource(ctx, reader, time.Millisecond).Start(func(height uint64) {
+		heads <- height
+	})
+
+	// a repeated or lower head is skipped, an error is retried on the next tick
+	require.Equal(t, uint64(10), <-heads)
+	require.Equal(t, uint64(12), <-heads)
+	select {
+	case height := <-heads:
+		t.Fatalf("unexpected head %d", height)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,300 @@
+package block_getter
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/metrics"
+	"abchain_scan/rpc_pool"
+	"abchain_scan/types"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	IngestionModeBlocks = "blocks"
+	IngestionModeLogs   = "logs"
+)
+
+const rpcBatchSize = 200
+
+var errLogBlockHashMismatch = errors.New("log block hash mismatch, chain moved while fetching")
+
+/*
+logRange
+one eth_getLogs call serves every height in [from, to],
+the workers asking for those heights wait on done
+*/
+type logRange struct {
+	from   uint64
+	to     uint64
+	done   chan struct{}
+	blocks map[uint64]*types.ParseBlockContext
+	err    error
+	taken  uint64
+}
+
+type logRangeCache struct {
+	mu     sync.Mutex
+	ranges []*logRange
+}
+
+func (c *logRangeCache) find(height uint64) *logRange {
+	for _, r := range c.ranges {
+		if r.from <= height && height <= r.to {
+			return r
+		}
+	}
+	return nil
+}
+
+/*
+add
+a range ends before the next range already in the cache, heights asked out of order
+in one window never end up in two ranges and each of them is fetched once
+*/
+func (c *logRangeCache) add(from, to uint64) *logRange {
+	for _, r := range c.ranges {
+		if r.from > from && r.from <= to {
+			to = r.from - 1
+		}
+	}
+	r := &logRange{from: from, to: to, done: make(chan struct{})}
+	c.ranges = append(c.ranges, r)
+	return r
+}
+
+func (c *logRangeCache) remove(r *logRange) {
+	for i, cr := range c.ranges {
+		if cr == r {
+			c.ranges = append(c.ranges[:i], c.ranges[i+1:]...)
+			return
+		}
+	}
+}
+
+func isLogIngestion() bool {
+	return config.G.BlockGetter.IngestionMode == IngestionModeLogs
+}
+
+/*
+getBlockByLogs
+heights are grouped into ranges from the first height asked up to the end of its log_range_size window,
+a range never overlaps another one nor goes past the dispatched height, which is known to exist on chain
+(or in the backfill range)
+*/
+func (bg *blockGetter) getBlockByLogs(blockNumber uint64) (*types.ParseBlockContext, error) {
+	bg.logRanges.mu.Lock()
+	r := bg.logRanges.find(blockNumber)
+	if r == nil {
+		rangeSize := max(config.G.BlockGetter.LogRangeSize, 1)
+		to := blockNumber - blockNumber%rangeSize + rangeSize - 1
+		if limit := max(blockNumber, bg.dispatchedHeight.Get()); to > limit {
+			to = limit
+		}
+
+		r = bg.logRanges.add(blockNumber, to)
+		bg.logRanges.mu.Unlock()
+
+		r.blocks, r.err = bg.fetchLogRange(r.from, r.to)
+		close(r.done)
+	} else {
+		bg.logRanges.mu.Unlock()
+	}
+
+	<-r.done
+
+	bg.logRanges.mu.Lock()
+	defer bg.logRanges.mu.Unlock()
+	if r.err != nil {
+		bg.logRanges.remove(r)
+		return nil, r.err
+	}
+
+	r.taken++
+	if r.taken == r.to-r.from+1 {
+		bg.logRanges.remove(r)
+	}
+	return r.blocks[blockNumber], nil
+}
+
+func (bg *blockGetter) fetchLogRange(from, to uint64) (map[uint64]*types.ParseBlockContext, error) {
+	now := time.Now()
+	ethLogs, err := rpc_pool.Do(bg.rpcPool, rpc_pool.RoleBlocks, func(client *ethclient.Client) ([]ethtypes.Log, error) {
+		return client.FilterLogs(bg.ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Topics:    [][]common.Hash{bg.topics},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.GetLogsDurationMs.Observe(float64(time.Since(now).Milliseconds()))
+
+	headers, err := bg.getHeaders(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	txHashes := make([]common.Hash, 0, len(ethLogs))
+	seen := make(map[common.Hash]bool, len(ethLogs))
+	for i := range ethLogs {
+		ethLog := &ethLogs[i]
+		if ethLog.BlockHash != headers[ethLog.BlockNumber-from].Hash() {
+			return nil, errLogBlockHashMismatch
+		}
+		if !seen[ethLog.TxHash] {
+			seen[ethLog.TxHash] = true
+			txHashes = append(txHashes, ethLog.TxHash)
+		}
+	}
+
+	senders, err := bg.getTxSenders(txHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildBlocksFromLogs(headers, ethLogs, senders), nil
+}
+
+func (bg *blockGetter) batchCall(elems []rpc.BatchElem) error {
+	for start := 0; start < len(elems); start += rpcBatchSize {
+		end := min(start+rpcBatchSize, len(elems))
+		batch := elems[start:end]
+		_, err := rpc_pool.Do(bg.rpcPool, rpc_pool.RoleBlocks, func(client *ethclient.Client) (struct{}, error) {
+			return struct{}{}, client.Client().BatchCallContext(bg.ctx, batch)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, elem := range batch {
+			if elem.Error != nil {
+				return elem.Error
+			}
+		}
+	}
+	return nil
+}
+
+func (bg *blockGetter) getHeaders(from, to uint64) ([]*ethtypes.Header, error) {
+	headers := make([]*ethtypes.Header, to-from+1)
+	elems := make([]rpc.BatchElem, len(headers))
+	for i := range elems {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []interface{}{hexutil.EncodeUint64(from + uint64(i)), false},
+			Result: &headers[i],
+		}
+	}
+
+	if err := bg.batchCall(elems); err != nil {
+		return nil, err
+	}
+
+	for i, header := range headers {
+		if header == nil {
+			return nil, fmt.Errorf("header %d not found", from+uint64(i))
+		}
+	}
+	return headers, nil
+}
+
+type txFrom struct {
+	From common.Address `json:"from"`
+}
+
+func (bg *blockGetter) getTxSenders(txHashes []common.Hash) (map[common.Hash]common.Address, error) {
+	results := make([]*txFrom, len(txHashes))
+	elems := make([]rpc.BatchElem, len(txHashes))
+	for i, txHash := range txHashes {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getTransactionByHash",
+			Args:   []interface{}{txHash},
+			Result: &results[i],
+		}
+	}
+
+	if err := bg.batchCall(elems); err != nil {
+		return nil, err
+	}
+
+	senders := make(map[common.Hash]common.Address, len(txHashes))
+	for i, result := range results {
+		if result == nil {
+			return nil, fmt.Errorf("tx %s not found", txHashes[i])
+		}
+		senders[txHashes[i]] = result.From
+	}
+	return senders, nil
+}
+
+/*
+buildBlocksFromLogs
+synthesizes one successful receipt per tx holding matching logs,
+eth_getLogs never returns logs of reverted txs
+*/
+func buildBlocksFromLogs(headers []*ethtypes.Header, ethLogs []ethtypes.Log, senders map[common.Hash]common.Address) map[uint64]*types.ParseBlockContext {
+	receiptsByBlock := make(map[uint64]map[uint]*ethtypes.Receipt, len(headers))
+	for i := range ethLogs {
+		ethLog := &ethLogs[i]
+		receipts, ok := receiptsByBlock[ethLog.BlockNumber]
+		if !ok {
+			receipts = make(map[uint]*ethtypes.Receipt)
+			receiptsByBlock[ethLog.BlockNumber] = receipts
+		}
+
+		receipt, ok := receipts[ethLog.TxIndex]
+		if !ok {
+			receipt = &ethtypes.Receipt{
+				Status:           ethtypes.ReceiptStatusSuccessful,
+				TxHash:           ethLog.TxHash,
+				BlockHash:        ethLog.BlockHash,
+				BlockNumber:      new(big.Int).SetUint64(ethLog.BlockNumber),
+				TransactionIndex: ethLog.TxIndex,
+			}
+			receipts[ethLog.TxIndex] = receipt
+		}
+		receipt.Logs = append(receipt.Logs, ethLog)
+	}
+
+	blocks := make(map[uint64]*types.ParseBlockContext, len(headers))
+	for _, header := range headers {
+		height := header.Number.Uint64()
+		receipts := make([]*ethtypes.Receipt, 0, len(receiptsByBlock[height]))
+		txCount := uint(0)
+		for _, receipt := range receiptsByBlock[height] {
+			sort.Slice(receipt.Logs, func(i, j int) bool { return receipt.Logs[i].Index < receipt.Logs[j].Index })
+			receipts = append(receipts, receipt)
+			txCount = max(txCount, receipt.TransactionIndex+1)
+		}
+		sort.Slice(receipts, func(i, j int) bool { return receipts[i].TransactionIndex < receipts[j].TransactionIndex })
+
+		txSenders := make([]*common.Address, txCount)
+		for _, receipt := range receipts {
+			sender := senders[receipt.TxHash]
+			txSenders[receipt.TransactionIndex] = &sender
+		}
+
+		blocks[height] = &types.ParseBlockContext{
+			Sequence:        height,
+			BlockHash:       header.Hash(),
+			ParentHash:      header.ParentHash,
+			Transactions:    make([]*ethtypes.Transaction, txCount),
+			TransactionsLen: txCount,
+			BlockReceipts:   receipts,
+			HeightTime:      types.GetBlockHeightTime(header),
+			TxSenders:       txSenders,
+		}
+	}
+	return blocks
+}
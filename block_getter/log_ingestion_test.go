@@ -0,0 +1,73 @@
+package block_getter
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func Test_BuildBlocksFromLogs(t *testing.T) {
+	headers := []*ethtypes.Header{
+		{Number: big.NewInt(100), Time: 1000, Difficulty: common.Big0},
+		{Number: big.NewInt(101), Time: 1002, Difficulty: common.Big0},
+	}
+	headers[1].ParentHash = headers[0].Hash()
+
+	txA := common.HexToHash("0xa")
+	txB := common.HexToHash("0xb")
+	ethLogs := []ethtypes.Log{
+		{BlockNumber: 101, BlockHash: headers[1].Hash(), TxHash: txB, TxIndex: 5, Index: 9},
+		{BlockNumber: 101, BlockHash: headers[1].Hash(), TxHash: txA, TxIndex: 2, Index: 4},
+		{BlockNumber: 101, BlockHash: headers[1].Hash(), TxHash: txB, TxIndex: 5, Index: 7},
+	}
+	senders := map[common.Hash]common.Address{
+		txA: common.HexToAddress("0x01"),
+		txB: common.HexToAddress("0x02"),
+	}
+
+	blocks := buildBlocksFromLogs(headers, ethLogs, senders)
+	require.Len(t, blocks, 2)
+
+	empty := blocks[100]
+	require.Empty(t, empty.BlockReceipts)
+	require.Equal(t, headers[0].Hash(), empty.BlockHash)
+
+	pbc := blocks[101]
+	require.Equal(t, headers[0].Hash(), pbc.ParentHash)
+	require.Equal(t, uint64(101), pbc.GetSequence())
+	require.Len(t, pbc.BlockReceipts, 2)
+	require.Equal(t, uint(2), pbc.BlockReceipts[0].TransactionIndex)
+	require.Equal(t, uint(5), pbc.BlockReceipts[1].TransactionIndex)
+	require.Equal(t, uint(7), pbc.BlockReceipts[1].Logs[0].Index)
+	require.Equal(t, uint(9), pbc.BlockReceipts[1].Logs[1].Index)
+
+	sender, err := pbc.GetTxSender(5)
+	require.NoError(t, err)
+	require.Equal(t, common.HexToAddress("0x02"), sender)
+}
+
+func Test_LogRangeCache_OutOfOrder(t *testing.T) {
+	c := &logRangeCache{}
+
+	// the worker of 105 comes first in the window [100, 109]
+	later := c.add(105, 109)
+	require.Nil(t, c.find(102))
+	earlier := c.add(102, 109)
+	require.Equal(t, uint64(102), earlier.from)
+	require.Equal(t, uint64(104), earlier.to)
+
+	for height := uint64(102); height <= 109; height++ {
+		r := c.find(height)
+		if height < 105 {
+			require.Same(t, earlier, r)
+		} else {
+			require.Same(t, later, r)
+		}
+	}
+
+	c.remove(earlier)
+	c.remove(later)
+	require.Empty(t, c.ranges)
+}
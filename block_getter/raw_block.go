@@ -0,0 +1,107 @@
+package block_getter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+type rawBlock struct {
+	Hash         common.Hash       `json:"hash"`
+	Transactions []json.RawMessage `json:"transactions"`
+}
+
+type rawFrom struct {
+	From *common.Address `json:"from"`
+}
+
+/*
+decodedBlock
+decoded from the raw json of eth_getBlockByNumber, a tx of a type unknown to
+go-ethereum (deposit txs on op-stack chains for example) is kept as nil,
+the `from` returned by the node is kept for every tx
+*/
+type decodedBlock struct {
+	header       *ethtypes.Header
+	hash         common.Hash
+	transactions []*ethtypes.Transaction
+	txFroms      []*common.Address
+}
+
+func decodeBlock(raw json.RawMessage) (*decodedBlock, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, ethereum.NotFound
+	}
+
+	header := &ethtypes.Header{}
+	if err := json.Unmarshal(raw, header); err != nil {
+		return nil, fmt.Errorf("decode header err: %w", err)
+	}
+
+	var rb rawBlock
+	if err := json.Unmarshal(raw, &rb); err != nil {
+		return nil, fmt.Errorf("decode block err: %w", err)
+	}
+
+	b := &decodedBlock{
+		header:       header,
+		hash:         rb.Hash,
+		transactions: make([]*ethtypes.Transaction, len(rb.Transactions)),
+		txFroms:      make([]*common.Address, len(rb.Transactions)),
+	}
+	for i, rawTx := range rb.Transactions {
+		var from rawFrom
+		if err := json.Unmarshal(rawTx, &from); err != nil {
+			return nil, fmt.Errorf("decode tx %d err: %w", i, err)
+		}
+		b.txFroms[i] = from.From
+
+		tx := &ethtypes.Transaction{}
+		if err := tx.UnmarshalJSON(rawTx); err == nil {
+			b.transactions[i] = tx
+		}
+	}
+	return b, nil
+}
+
+func decodeReceipts(raw json.RawMessage) ([]*ethtypes.Receipt, []*common.Address, error) {
+	var rawReceipts []json.RawMessage
+	if err := json.Unmarshal(raw, &rawReceipts); err != nil {
+		return nil, nil, fmt.Errorf("decode receipts err: %w", err)
+	}
+
+	receipts := make([]*ethtypes.Receipt, len(rawReceipts))
+	froms := make([]*common.Address, len(rawReceipts))
+	for i, rawReceipt := range rawReceipts {
+		receipt := &ethtypes.Receipt{}
+		if err := json.Unmarshal(rawReceipt, receipt); err != nil {
+			return nil, nil, fmt.Errorf("decode receipt %d err: %w", i, err)
+		}
+		receipts[i] = receipt
+
+		var from rawFrom
+		if err := json.Unmarshal(rawReceipt, &from); err != nil {
+			return nil, nil, fmt.Errorf("decode receipt %d err: %w", i, err)
+		}
+		froms[i] = from.From
+	}
+	return receipts, froms, nil
+}
+
+/*
+collectTxSenders
+prefers the receipt `from`, then the tx `from`,
+senders left nil are recovered from the signature when needed
+*/
+func collectTxSenders(block *decodedBlock, receipts []*ethtypes.Receipt, receiptFroms []*common.Address) []*common.Address {
+	txSenders := make([]*common.Address, len(block.transactions))
+	copy(txSenders, block.txFroms)
+	for i, receipt := range receipts {
+		if receiptFroms[i] != nil && receipt.TransactionIndex < uint(len(txSenders)) {
+			txSenders[receipt.TransactionIndex] = receiptFroms[i]
+		}
+	}
+	return txSenders
+}
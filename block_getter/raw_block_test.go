@@ -0,0 +1,80 @@
+package block_getter
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func toJsonMap(t *testing.T, v interface{}) map[string]interface{} {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	m := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(b, &m))
+	return m
+}
+
+func Test_DecodeBlock(t *testing.T) {
+	_, err := decodeBlock(json.RawMessage("null"))
+	require.ErrorIs(t, err, ethereum.NotFound)
+
+	header := &ethtypes.Header{Number: big.NewInt(100), Time: 1000, Difficulty: common.Big0}
+	legacyFrom := common.HexToAddress("0x01")
+	depositFrom := common.HexToAddress("0x02")
+
+	legacyTx := toJsonMap(t, ethtypes.NewTx(&ethtypes.LegacyTx{Nonce: 1, GasPrice: common.Big1, Gas: 21000}))
+	legacyTx["from"] = legacyFrom.Hex()
+	// deposit tx of op-stack chains, unknown to go-ethereum
+	depositTx := map[string]interface{}{
+		"type": "0x7e",
+		"hash": common.HexToHash("0xd").Hex(),
+		"from": depositFrom.Hex(),
+	}
+
+	rawBlockMap := toJsonMap(t, header)
+	rawBlockMap["hash"] = common.HexToHash("0xb").Hex()
+	rawBlockMap["transactions"] = []interface{}{depositTx, legacyTx}
+	raw, err := json.Marshal(rawBlockMap)
+	require.NoError(t, err)
+
+	block, err := decodeBlock(raw)
+	require.NoError(t, err)
+	require.Equal(t, common.HexToHash("0xb"), block.hash)
+	require.Equal(t, uint64(100), block.header.Number.Uint64())
+	require.Len(t, block.transactions, 2)
+	require.Nil(t, block.transactions[0])
+	require.NotNil(t, block.transactions[1])
+	require.Equal(t, depositFrom, *block.txFroms[0])
+	require.Equal(t, legacyFrom, *block.txFroms[1])
+}
+
+func Test_CollectTxSenders(t *testing.T) {
+	txFrom := common.HexToAddress("0x01")
+	receiptFrom := common.HexToAddress("0x02")
+	block := &decodedBlock{
+		transactions: make([]*ethtypes.Transaction, 3),
+		txFroms:      []*common.Address{&txFrom, &txFrom, nil},
+	}
+
+	receipt := toJsonMap(t, &ethtypes.Receipt{
+		Status:           ethtypes.ReceiptStatusSuccessful,
+		TransactionIndex: 1,
+		Logs:             []*ethtypes.Log{},
+	})
+	receipt["from"] = receiptFrom.Hex()
+	raw, err := json.Marshal([]interface{}{receipt})
+	require.NoError(t, err)
+
+	receipts, receiptFroms, err := decodeReceipts(raw)
+	require.NoError(t, err)
+	require.Len(t, receipts, 1)
+
+	txSenders := collectTxSenders(block, receipts, receiptFroms)
+	require.Equal(t, txFrom, *txSenders[0])
+	require.Equal(t, receiptFrom, *txSenders[1])
+	require.Nil(t, txSenders[2])
+}
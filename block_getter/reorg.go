@@ -0,0 +1,208 @@
+package block_getter
+
+import (
+	"abchain_scan/cache"
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"abchain_scan/rpc_pool"
+	"abchain_scan/types"
+	"github.com/avast/retry-go/v4"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"go.uber.org/zap"
+	"math/big"
+	"time"
+)
+
+/*
+blockHashHistory
+remembers the hash of the last `depth` emitted blocks, also written to the store
+so the history of the blocks emitted before a restart can be seeded back,
+only accessed from Commit under commitMu
+*/
+type blockHashHistory struct {
+	depth  uint64
+	hashes map[uint64]common.Hash
+	store  cache.BlockHashCache // optional
+}
+
+func newBlockHashHistory(depth uint64, store cache.BlockHashCache) *blockHashHistory {
+	if depth == 0 {
+		depth = 1
+	}
+
+	return &blockHashHistory{
+		depth:  depth,
+		hashes: make(map[uint64]common.Hash, depth+1),
+		store:  store,
+	}
+}
+
+/*
+seed
+loads the hashes of the blocks emitted before a restart below startHeight,
+the first block dispatched is then checked against its stored parent
+*/
+func (h *blockHashHistory) seed(startHeight uint64) int {
+	if h.store == nil || startHeight == 0 {
+		return 0
+	}
+
+	seeded := 0
+	for height := startHeight - 1; height > 0 && height+h.depth >= startHeight; height-- {
+		hash, ok := h.store.GetBlockHash(height)
+		if !ok {
+			break
+		}
+		h.hashes[height] = hash
+		seeded++
+	}
+	return seeded
+}
+
+func (h *blockHashHistory) add(height uint64, hash common.Hash) {
+	h.hashes[height] = hash
+	if h.store != nil {
+		h.store.SetBlockHash(height, hash)
+	}
+	if height >= h.depth {
+		delete(h.hashes, height-h.depth)
+		if h.store != nil {
+			h.store.DelBlockHash(height - h.depth)
+		}
+	}
+}
+
+func (h *blockHashHistory) get(height uint64) (common.Hash, bool) {
+	hash, ok := h.hashes[height]
+	return hash, ok
+}
+
+func (h *blockHashHistory) truncate(height uint64) {
+	for k := range h.hashes {
+		if k > height {
+			delete(h.hashes, k)
+			if h.store != nil {
+				h.store.DelBlockHash(k)
+			}
+		}
+	}
+}
+
+func (h *blockHashHistory) isFork(pbc *types.ParseBlockContext) bool {
+	prevHash, ok := h.get(pbc.HeightTime.Height - 1)
+	return ok && prevHash != pbc.ParentHash
+}
+
+func (bg *blockGetter) getCanonicalHash(height uint64) (common.Hash, error) {
+	return retry.DoWithData(func() (common.Hash, error) {
+		header, err := rpc_pool.Do(bg.rpcPool, rpc_pool.RoleBlocks, func(client *ethclient.Client) (*ethtypes.Header, error) {
+			return client.HeaderByNumber(bg.ctx, new(big.Int).SetUint64(height))
+		})
+		if err != nil {
+			return common.Hash{}, err
+		}
+		return header.Hash(), nil
+	}, bg.retryParams.Attempts, bg.retryParams.Delay)
+}
+
+func (bg *blockGetter) findCommonAncestor(height uint64) (uint64, common.Hash) {
+	for h := height; ; h-- {
+		hash, ok := bg.hashHistory.get(h)
+		if !ok {
+			log.Logger.Fatal("reorg deeper than reorg_depth",
+				zap.Uint64("fork height", height+1),
+				zap.Uint64("reorg_depth", bg.hashHistory.depth))
+		}
+
+		canonicalHash, err := bg.getCanonicalHash(h)
+		if err != nil {
+			log.Logger.Fatal("get canonical hash err", zap.Uint64("height", h), zap.Error(err))
+		}
+
+		if canonicalHash == hash {
+			return h, hash
+		}
+	}
+}
+
+/*
+fetchCanonicalBranch
+fetches (ancestor, tip] and checks every block links to its parent,
+returns false if the chain moved again while fetching
+*/
+func (bg *blockGetter) fetchCanonicalBranch(ancestor uint64, ancestorHash common.Hash, tip uint64) ([]*types.ParseBlockContext, bool) {
+	var logBlocks map[uint64]*types.ParseBlockContext
+	if isLogIngestion() {
+		// bypass the range cache, the cached ranges belong to the dispatched heights
+		var err error
+		logBlocks, err = retry.DoWithData(func() (map[uint64]*types.ParseBlockContext, error) {
+			return bg.fetchLogRange(ancestor+1, tip)
+		}, bg.retryParams.Attempts, bg.retryParams.Delay)
+		if err != nil {
+			log.Logger.Fatal("get canonical logs err", zap.Uint64("from", ancestor+1), zap.Uint64("to", tip), zap.Error(err))
+		}
+	}
+
+	branch := make([]*types.ParseBlockContext, 0, tip-ancestor)
+	parentHash := ancestorHash
+	for h := ancestor + 1; h <= tip; h++ {
+		var pbc *types.ParseBlockContext
+		var err error
+		if logBlocks != nil {
+			pbc = logBlocks[h]
+		} else {
+			pbc, err = bg.getBlockWithRetry(h)
+		}
+		if err != nil {
+			log.Logger.Fatal("get canonical block err", zap.Uint64("height", h), zap.Error(err))
+		}
+
+		if pbc.ParentHash != parentHash {
+			return nil, false
+		}
+		parentHash = pbc.BlockHash
+		branch = append(branch, pbc)
+	}
+	return branch, true
+}
+
+func (bg *blockGetter) handleReorg(pbc *types.ParseBlockContext) []*types.ParseBlockContext {
+	tip := pbc.HeightTime.Height
+	for {
+		ancestor, ancestorHash := bg.findCommonAncestor(tip - 1)
+		branch, ok := bg.fetchCanonicalBranch(ancestor, ancestorHash, tip)
+		if !ok {
+			log.Logger.Warn("chain moved while fetching canonical branch, retry", zap.Uint64("ancestor", ancestor))
+			time.Sleep(time.Second)
+			continue
+		}
+
+		reorg := &types.Reorg{
+			AncestorHeight: ancestor,
+			AncestorHash:   ancestorHash,
+			OrphanedBlocks: make([]*types.OrphanedBlock, 0, tip-ancestor-1),
+		}
+		for h := ancestor + 1; h < tip; h++ {
+			hash, _ := bg.hashHistory.get(h)
+			reorg.OrphanedBlocks = append(reorg.OrphanedBlocks, &types.OrphanedBlock{Height: h, Hash: hash})
+		}
+		bg.hashHistory.truncate(ancestor)
+		if reorg.Depth() == 0 {
+			// the fetched block was stale, nothing we emitted is orphaned
+			return branch
+		}
+		branch[0].Reorg = reorg
+
+		log.Logger.Warn("reorg detected",
+			zap.Uint64("ancestor", ancestor),
+			zap.String("ancestor hash", ancestorHash.String()),
+			zap.Uint64("tip", tip),
+			zap.Int("depth", reorg.Depth()))
+		metrics.ReorgTotal.Inc()
+		metrics.ReorgDepth.Set(float64(reorg.Depth()))
+
+		return branch
+	}
+}
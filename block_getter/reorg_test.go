@@ -0,0 +1,65 @@
+package block_getter
+
+import (
+	"abchain_scan/cache"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_BlockHashHistory(t *testing.T) {
+	h := newBlockHashHistory(3, nil)
+	for i := uint64(1); i <= 5; i++ {
+		h.add(i, common.Hash{byte(i)})
+	}
+
+	_, ok := h.get(2)
+	require.False(t, ok)
+	hash, ok := h.get(5)
+	require.True(t, ok)
+	require.Equal(t, common.Hash{5}, hash)
+
+	require.False(t, h.isFork(&types.ParseBlockContext{
+		HeightTime: &types.BlockHeightTime{Height: 6},
+		ParentHash: hash,
+	}))
+	require.True(t, h.isFork(&types.ParseBlockContext{
+		HeightTime: &types.BlockHeightTime{Height: 6},
+		ParentHash: common.HexToHash("0x01"),
+	}))
+	// parent not remembered, can't tell
+	require.False(t, h.isFork(&types.ParseBlockContext{
+		HeightTime: &types.BlockHeightTime{Height: 10},
+		ParentHash: common.HexToHash("0x01"),
+	}))
+
+	h.truncate(3)
+	_, ok = h.get(4)
+	require.False(t, ok)
+	_, ok = h.get(3)
+	require.True(t, ok)
+}
+
+func Test_BlockHashHistorySeed(t *testing.T) {
+	store := cache.NewMockCache()
+	h := newBlockHashHistory(3, store)
+	for i := uint64(1); i <= 5; i++ {
+		h.add(i, common.Hash{byte(i)})
+	}
+	h.truncate(4)
+
+	// restarted at the block after the last finished one
+	restarted := newBlockHashHistory(3, store)
+	require.Equal(t, 2, restarted.seed(5))
+	_, ok := restarted.get(2)
+	require.False(t, ok)
+	hash, ok := restarted.get(4)
+	require.True(t, ok)
+	require.Equal(t, common.Hash{4}, hash)
+
+	require.True(t, restarted.isFork(&types.ParseBlockContext{
+		HeightTime: &types.BlockHeightTime{Height: 5},
+		ParentHash: common.HexToHash("0x01"),
+	}))
+}
@@ -0,0 +1,132 @@
+package block_getter
+
+import (
+	"abchain_scan/cache"
+	"abchain_scan/log"
+	"abchain_scan/types"
+	"go.uber.org/zap"
+	"math"
+)
+
+/*
+replayBlockGetter
+a BlockGetter reading the blocks recorded by archive record mode instead of a node,
+blocks come out in the recorded emission order, reorgs included
+*/
+type replayBlockGetter struct {
+	dir          string
+	cache        cache.BlockCache
+	outputBuffer chan *types.ParseBlockContext
+	stopped      SafeVar[bool]
+}
+
+func NewReplayBlockGetter(dir string, cache cache.BlockCache) BlockGetter {
+	return &replayBlockGetter{
+		dir:          dir,
+		cache:        cache,
+		outputBuffer: make(chan *types.ParseBlockContext, 10),
+	}
+}
+
+func (rg *replayBlockGetter) Start() {
+	log.Logger.Info("replay blocks from archive", zap.String("dir", rg.dir))
+}
+
+func (rg *replayBlockGetter) GetStartBlockNumber(startBlockNumber uint64) uint64 {
+	if startBlockNumber != 0 {
+		return startBlockNumber
+	}
+
+	finishedBlock := rg.cache.GetFinishedBlock()
+	if finishedBlock != 0 {
+		return finishedBlock + 1
+	}
+
+	files, err := listArchiveFiles(rg.dir)
+	if err != nil || len(files) == 0 {
+		log.Logger.Fatal("no archive to replay", zap.String("dir", rg.dir), zap.Error(err))
+	}
+	firstHeight := files[0].firstHeight
+	for _, file := range files {
+		firstHeight = min(firstHeight, file.firstHeight)
+	}
+	return firstHeight
+}
+
+func (rg *replayBlockGetter) GetBackfillStartBlockNumber(startBlockNumber, endBlockNumber uint64) uint64 {
+	finishedBlock := rg.cache.GetFinishedBlock()
+	if finishedBlock >= startBlockNumber && finishedBlock <= endBlockNumber {
+		return finishedBlock + 1
+	}
+	return startBlockNumber
+}
+
+// StartDispatch replays until the end of the archive
+func (rg *replayBlockGetter) StartDispatch(startBlockNumber, startSequence uint64) {
+	go rg.replay(startBlockNumber, math.MaxUint64, startSequence)
+}
+
+func (rg *replayBlockGetter) StartBackfill(startBlockNumber, endBlockNumber, startSequence uint64) {
+	go rg.replay(startBlockNumber, endBlockNumber, startSequence)
+}
+
+/*
+replay
+a height recorded again without a reorg comes from a restarted recorder and is skipped,
+the reorg of a block is dropped when the orphaned blocks were never replayed
+*/
+func (rg *replayBlockGetter) replay(startBlockNumber, endBlockNumber, startSequence uint64) {
+	defer close(rg.outputBuffer)
+
+	var (
+		sequence   = startSequence
+		lastHeight uint64
+		emitted    bool
+	)
+	err := readArchive(rg.dir, startBlockNumber, func(record *archiveRecord) bool {
+		if rg.stopped.Get() {
+			log.Logger.Info("replay interrupted", zap.Uint64("height", record.Number))
+			return false
+		}
+		if record.Number < startBlockNumber {
+			return true
+		}
+		if record.Number > endBlockNumber {
+			return false
+		}
+		if emitted && record.Number <= lastHeight && record.Reorg == nil {
+			return true
+		}
+
+		pbc, err := buildBlock(record.Number, record.Block, record.Receipts)
+		if err != nil {
+			log.Logger.Fatal("decode archived block err", zap.Uint64("height", record.Number), zap.Error(err))
+		}
+		if emitted && record.Reorg != nil && record.Reorg.AncestorHeight+1 >= startBlockNumber {
+			pbc.Reorg = record.Reorg
+		}
+		pbc.Sequence = sequence
+		sequence++
+		lastHeight = record.Number
+		emitted = true
+
+		rg.outputBuffer <- pbc
+		return true
+	})
+	if err != nil {
+		log.Logger.Fatal("replay archive err", zap.String("dir", rg.dir), zap.Error(err))
+	}
+	log.Logger.Info("replay finished", zap.Uint64("last height", lastHeight))
+}
+
+func (rg *replayBlockGetter) Stop() {
+	rg.stopped.Set(true)
+}
+
+func (rg *replayBlockGetter) GetBlockAsync(blockNumber uint64) {
+	log.Logger.Fatal("get block is not supported in replay", zap.Uint64("blockNumber", blockNumber))
+}
+
+func (rg *replayBlockGetter) Next() *types.ParseBlockContext {
+	return <-rg.outputBuffer
+}
@@ -0,0 +1,98 @@
+package block_getter
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+/*
+retryQueue
+blocks that exhausted getBlockWithRetry, each is fetched again after an exponential backoff,
+a block is never dropped since the sequencer would wait for it forever
+*/
+type retryQueue struct {
+	mu           sync.Mutex
+	attempts     map[uint64]uint
+	maxAttempts  uint
+	initialDelay time.Duration
+	maxDelay     time.Duration
+}
+
+func newRetryQueue(conf *config.FailedBlockRetryConf) *retryQueue {
+	return &retryQueue{
+		attempts:     make(map[uint64]uint),
+		maxAttempts:  conf.MaxAttempts,
+		initialDelay: time.Duration(conf.InitialDelayMs) * time.Millisecond,
+		maxDelay:     time.Duration(conf.MaxDelayMs) * time.Millisecond,
+	}
+}
+
+// add returns the delay before the next attempt, false if the block ran out of attempts
+func (q *retryQueue) add(blockNumber uint64) (time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	attempts := q.attempts[blockNumber] + 1
+	if attempts > q.maxAttempts {
+		return 0, false
+	}
+	q.attempts[blockNumber] = attempts
+	metrics.BlockRetryQueueSize.Set(float64(len(q.attempts)))
+
+	delay := q.initialDelay << (attempts - 1)
+	if delay > q.maxDelay || delay <= 0 {
+		delay = q.maxDelay
+	}
+	return delay, true
+}
+
+func (q *retryQueue) remove(blockNumber uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.attempts, blockNumber)
+	metrics.BlockRetryQueueSize.Set(float64(len(q.attempts)))
+}
+
+func (bg *blockGetter) submitGetBlock(blockNumber uint64, wg *sync.WaitGroup) {
+	err := bg.workPool.Submit(func() {
+		bg.getBlockAndCommit(blockNumber, wg)
+	})
+	if err != nil {
+		log.Logger.Fatal("submit get block task err", zap.Uint64("blockNumber", blockNumber), zap.Error(err))
+	}
+}
+
+func (bg *blockGetter) getBlockAndCommit(blockNumber uint64, wg *sync.WaitGroup) {
+	log.Logger.Info("get block start", zap.Uint64("block_number", blockNumber))
+	bw, err := bg.getBlockWithRetry(blockNumber)
+	if err != nil {
+		delay, ok := bg.retryQueue.add(blockNumber)
+		if !ok {
+			log.Logger.Fatal("get block still failing, give up",
+				zap.Uint64("blockNumber", blockNumber),
+				zap.Uint("max attempts", bg.retryQueue.maxAttempts),
+				zap.Error(err))
+		}
+
+		log.Logger.Error("get block err, retry later",
+			zap.Uint64("blockNumber", blockNumber),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+		metrics.BlockRetryTotal.Inc()
+		time.AfterFunc(delay, func() {
+			bg.submitGetBlock(blockNumber, wg)
+		})
+		return
+	}
+	defer wg.Done()
+	bg.retryQueue.remove(blockNumber)
+
+	log.Logger.Info("get block success", zap.Uint64("blockNumber", blockNumber))
+	metrics.BlockQueueSize.Set(float64(len(bg.outputBuffer)))
+	bg.blockSequencer.CommitWithSequence(bw, bg)
+}
@@ -0,0 +1,137 @@
+package block_getter
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/log"
+	"abchain_scan/rpc_pool"
+	"abchain_scan/sequencer"
+	"encoding/json"
+	"errors"
+	"github.com/avast/retry-go/v4"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_RetryQueueBackoff(t *testing.T) {
+	q := newRetryQueue(&config.FailedBlockRetryConf{
+		MaxAttempts:    4,
+		InitialDelayMs: 100,
+		MaxDelayMs:     300,
+	})
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for _, e := range expected {
+		delay, ok := q.add(10)
+		require.True(t, ok)
+		require.Equal(t, e, delay)
+	}
+	_, ok := q.add(10)
+	require.False(t, ok)
+
+	_, ok = q.add(9)
+	require.True(t, ok)
+	require.Equal(t, map[uint64]uint{9: 1, 10: 4}, q.attempts)
+
+	q.remove(10)
+	require.Equal(t, map[uint64]uint{9: 1}, q.attempts)
+}
+
+// failingEthApi an rpc node failing every block request
+type failingEthApi struct {
+	getBlockCalls atomic.Int32
+}
+
+func (api *failingEthApi) GetBlockByNumber(number hexutil.Uint64, full bool) (json.RawMessage, error) {
+	api.getBlockCalls.Add(1)
+	return nil, errors.New("header not found")
+}
+
+func (api *failingEthApi) GetBlockReceipts(number hexutil.Uint64) (json.RawMessage, error) {
+	return nil, errors.New("header not found")
+}
+
+type testRpcPool struct {
+	client *ethclient.Client
+}
+
+func (p *testRpcPool) Get(role rpc_pool.Role) (*ethclient.Client, func(err error)) {
+	return p.client, func(err error) {}
+}
+
+func (p *testRpcPool) Close() {}
+
+// fatalHook stops the goroutine calling Fatal instead of exiting the test binary
+type fatalHook chan string
+
+func (h fatalHook) OnWrite(entry *zapcore.CheckedEntry, fields []zapcore.Field) {
+	h <- entry.Message
+	runtime.Goexit()
+}
+
+// catchFatal the messages logged with Fatal until the end of the test, along with all the logs
+func catchFatal(t *testing.T) (<-chan string, *observer.ObservedLogs) {
+	logger := log.Logger
+	t.Cleanup(func() { log.Logger = logger })
+
+	hook := make(fatalHook, 1)
+	core, logs := observer.New(zapcore.InfoLevel)
+	log.Logger = zap.New(core, zap.WithFatalHook(hook))
+	return hook, logs
+}
+
+/*
+newFailingBlockGetter
+a block getter on a node that never returns a block, a failed block is given up after maxAttempts retries
+*/
+func newFailingBlockGetter(t *testing.T, maxAttempts uint) (*blockGetter, *failingEthApi) {
+	saved := *config.G.BlockGetter
+	t.Cleanup(func() { *config.G.BlockGetter = saved })
+	config.G.BlockGetter.IngestionMode = ""
+	config.G.BlockGetter.AdaptiveConcurrency.Enabled = false
+	config.G.BlockGetter.Archive.Mode = ""
+	config.G.BlockGetter.FailedBlockRetry = config.FailedBlockRetryConf{MaxAttempts: maxAttempts, InitialDelayMs: 1, MaxDelayMs: 1}
+
+	api := &failingEthApi{}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", api))
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+
+	bg := NewBlockGetter(nil, nil, &testRpcPool{client: client}, nil, nil, sequencer.NewSequencer("test"), &config.RetryParams{
+		Attempts: retry.Attempts(1),
+		Delay:    retry.Delay(0),
+	})
+	return bg.(*blockGetter), api
+}
+
+func Test_GetBlockAndCommit_GiveUpAfterMaxAttempts(t *testing.T) {
+	fatal, _ := catchFatal(t)
+	bg, api := newFailingBlockGetter(t, 3)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	bg.submitGetBlock(100, wg)
+
+	select {
+	case msg := <-fatal:
+		require.Equal(t, "get block still failing, give up", msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("block not given up")
+	}
+	// the first attempt and the 3 retries of the queue
+	require.Equal(t, int32(4), api.getBlockCalls.Load())
+	require.Equal(t, map[uint64]uint{100: 3}, bg.retryQueue.attempts)
+}
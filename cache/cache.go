@@ -19,6 +19,7 @@ import (
 type PriceCache interface {
 	SetPrice(blockNumber *big.Int, price decimal.Decimal)
 	GetPrice(blockNumber *big.Int) (decimal.Decimal, bool)
+	DelPrice(blockNumber *big.Int)
 }
 
 type TokenCache interface {
@@ -39,17 +40,43 @@ type BlockCache interface {
 	GetFinishedBlock() uint64
 }
 
+// UndoCache the undo data of the recent committed blocks, kept next to the finished block so a restart can still roll them back
+type UndoCache interface {
+	SetBlockUndo(height uint64, undo []byte)
+	GetBlockUndo(height uint64) ([]byte, bool)
+	DelBlockUndo(height uint64)
+}
+
+// BlockHashCache the hashes of the recent emitted blocks, kept next to the finished block so a reorg across a restart is still detected
+type BlockHashCache interface {
+	SetBlockHash(height uint64, hash common.Hash)
+	GetBlockHash(height uint64) (common.Hash, bool)
+	DelBlockHash(height uint64)
+}
+
+// SequencerCache the last committed sequence of the sequencers, kept next to the finished block
+type SequencerCache interface {
+	SetSequencerCheckpoint(name string, sequence uint64)
+	GetSequencerCheckpoint(name string) (uint64, bool)
+}
+
 type Cache interface {
 	PriceCache
 	TokenCache
 	PairCache
 	BlockCache
+	UndoCache
+	BlockHashCache
+	SequencerCache
 }
 
 type twoTierCache struct {
 	ctx    context.Context
 	memory *cache.Cache
 	redis  *redis.Client
+	fbKey  string
+	// the tokens and pairs are shared with the live indexer in redis, a backfill only reads them from redis
+	sharedReadOnly bool
 }
 
 func NewTwoTierCache(redis *redis.Client) Cache {
@@ -57,6 +84,22 @@ func NewTwoTierCache(redis *redis.Client) Cache {
 		ctx:    context.Background(),
 		memory: cache.New(time.Hour*24, time.Hour),
 		redis:  redis,
+		fbKey:  fbKey,
+	}
+}
+
+/*
+NewTwoTierCacheWithFinishedBlockKey
+for backfill, keeps the finished block away from the live indexer's key,
+the tokens and pairs it sets or deletes stay in memory
+*/
+func NewTwoTierCacheWithFinishedBlockKey(redis *redis.Client, finishedBlockKey string) Cache {
+	return &twoTierCache{
+		ctx:            context.Background(),
+		memory:         cache.New(time.Hour*24, time.Hour),
+		redis:          redis,
+		fbKey:          finishedBlockKey,
+		sharedReadOnly: true,
 	}
 }
 
@@ -72,6 +115,10 @@ func PairCacheKey(address common.Address) string {
 	return fmt.Sprintf("%d:p:%s", chain.Id, address.Hex())
 }
 
+func BackfillFinishedBlockKey(startBlockNumber, endBlockNumber uint64) string {
+	return fmt.Sprintf("%d:fb:backfill:%d-%d", chain.Id, startBlockNumber, endBlockNumber)
+}
+
 var (
 	fbKey = fmt.Sprintf("%d:fb", chain.Id)
 )
@@ -108,10 +155,22 @@ func (c *twoTierCache) GetPrice(blockNumber *big.Int) (decimal.Decimal, bool) {
 	return decimalPrice, true
 }
 
+func (c *twoTierCache) DelPrice(blockNumber *big.Int) {
+	k := PriceCacheKey(blockNumber)
+	c.memory.Delete(k)
+	err := c.redis.Del(c.ctx, k).Err()
+	if err != nil {
+		log.Logger.Error("redis del err", zap.Error(err))
+	}
+}
+
 func (c *twoTierCache) SetToken(token *types.Token) {
 	token.Timestamp = time.Now()
 	k := TokenCacheKey(token.Address)
 	c.memory.Set(k, token, cache.DefaultExpiration)
+	if c.sharedReadOnly {
+		return
+	}
 	err := c.redis.Set(c.ctx, k, token, 0).Err()
 	if err != nil {
 		log.Logger.Error("save token failed", zap.Error(err))
@@ -139,6 +198,9 @@ func (c *twoTierCache) GetToken(address common.Address) (*types.Token, bool) {
 func (c *twoTierCache) DelToken(address common.Address) {
 	k := TokenCacheKey(address)
 	c.memory.Delete(k)
+	if c.sharedReadOnly {
+		return
+	}
 	err := c.redis.Del(c.ctx, k).Err()
 	if err != nil {
 		log.Logger.Error("redis del err", zap.Error(err))
@@ -149,6 +211,9 @@ func (c *twoTierCache) SetPair(pair *types.Pair) {
 	pair.Timestamp = time.Now()
 	k := PairCacheKey(pair.Address)
 	c.memory.Set(k, pair, cache.DefaultExpiration)
+	if c.sharedReadOnly {
+		return
+	}
 	err := c.redis.Set(c.ctx, k, pair, 0).Err()
 	if err != nil {
 		log.Logger.Error("save pair failed", zap.Error(err))
@@ -183,6 +248,9 @@ func (c *twoTierCache) PairExist(address common.Address) bool {
 func (c *twoTierCache) DelPair(address common.Address) {
 	k := PairCacheKey(address)
 	c.memory.Delete(k)
+	if c.sharedReadOnly {
+		return
+	}
 	err := c.redis.Del(c.ctx, k).Err()
 	if err != nil {
 		log.Logger.Error("redis del err", zap.Error(err))
@@ -190,11 +258,11 @@ func (c *twoTierCache) DelPair(address common.Address) {
 }
 
 func (c *twoTierCache) SetFinishedBlock(blockNumber uint64) {
-	c.redis.Set(c.ctx, fbKey, blockNumber, 0)
+	c.redis.Set(c.ctx, c.fbKey, blockNumber, 0)
 }
 
 func (c *twoTierCache) GetFinishedBlock() uint64 {
-	v, err := c.redis.Get(c.ctx, fbKey).Uint64()
+	v, err := c.redis.Get(c.ctx, c.fbKey).Uint64()
 	if err != nil {
 		if !errors.Is(err, redis.Nil) {
 			log.Logger.Error("redis get err", zap.Error(err))
@@ -203,3 +271,83 @@ func (c *twoTierCache) GetFinishedBlock() uint64 {
 	}
 	return v
 }
+
+func (c *twoTierCache) blockUndoKey(height uint64) string {
+	return fmt.Sprintf("%s:u:%d", c.fbKey, height)
+}
+
+func (c *twoTierCache) SetBlockUndo(height uint64, undo []byte) {
+	err := c.redis.Set(c.ctx, c.blockUndoKey(height), undo, 0).Err()
+	if err != nil {
+		log.Logger.Error("save block undo failed", zap.Uint64("height", height), zap.Error(err))
+	}
+}
+
+func (c *twoTierCache) GetBlockUndo(height uint64) ([]byte, bool) {
+	v, err := c.redis.Get(c.ctx, c.blockUndoKey(height)).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Logger.Error("redis get err", zap.Error(err))
+		}
+		return nil, false
+	}
+	return v, true
+}
+
+func (c *twoTierCache) DelBlockUndo(height uint64) {
+	err := c.redis.Del(c.ctx, c.blockUndoKey(height)).Err()
+	if err != nil {
+		log.Logger.Error("redis del err", zap.Error(err))
+	}
+}
+
+func (c *twoTierCache) blockHashKey(height uint64) string {
+	return fmt.Sprintf("%s:h:%d", c.fbKey, height)
+}
+
+func (c *twoTierCache) SetBlockHash(height uint64, hash common.Hash) {
+	err := c.redis.Set(c.ctx, c.blockHashKey(height), hash.Bytes(), 0).Err()
+	if err != nil {
+		log.Logger.Error("save block hash failed", zap.Uint64("height", height), zap.Error(err))
+	}
+}
+
+func (c *twoTierCache) GetBlockHash(height uint64) (common.Hash, bool) {
+	v, err := c.redis.Get(c.ctx, c.blockHashKey(height)).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Logger.Error("redis get err", zap.Error(err))
+		}
+		return common.Hash{}, false
+	}
+	return common.BytesToHash(v), true
+}
+
+func (c *twoTierCache) DelBlockHash(height uint64) {
+	err := c.redis.Del(c.ctx, c.blockHashKey(height)).Err()
+	if err != nil {
+		log.Logger.Error("redis del err", zap.Error(err))
+	}
+}
+
+func (c *twoTierCache) sequencerKey(name string) string {
+	return fmt.Sprintf("%s:s:%s", c.fbKey, name)
+}
+
+func (c *twoTierCache) SetSequencerCheckpoint(name string, sequence uint64) {
+	err := c.redis.Set(c.ctx, c.sequencerKey(name), sequence, 0).Err()
+	if err != nil {
+		log.Logger.Error("save sequencer checkpoint failed", zap.String("sequencer", name), zap.Error(err))
+	}
+}
+
+func (c *twoTierCache) GetSequencerCheckpoint(name string) (uint64, bool) {
+	v, err := c.redis.Get(c.ctx, c.sequencerKey(name)).Uint64()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Logger.Error("redis get err", zap.Error(err))
+		}
+		return 0, false
+	}
+	return v, true
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"abchain_scan/types"
+	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/patrickmn/go-cache"
 	"github.com/shopspring/decimal"
@@ -37,6 +38,10 @@ func (c *MockCache) GetPrice(blockNumber *big.Int) (decimal.Decimal, bool) {
 	return decimal.Decimal{}, false
 }
 
+func (c *MockCache) DelPrice(blockNumber *big.Int) {
+	c.memory.Delete(blockNumber.String())
+}
+
 func (c *MockCache) SetToken(token *types.Token) {
 	c.memory.Set(token.Address.String(), token, 0)
 }
@@ -73,4 +78,45 @@ func (c *MockCache) GetFinishedBlock() uint64 {
 	return 0
 }
 
+func (c *MockCache) SetBlockUndo(height uint64, undo []byte) {
+	c.memory.Set(fmt.Sprintf("u:%d", height), undo, 0)
+}
+
+func (c *MockCache) GetBlockUndo(height uint64) ([]byte, bool) {
+	if undo, found := c.memory.Get(fmt.Sprintf("u:%d", height)); found {
+		return undo.([]byte), true
+	}
+	return nil, false
+}
+
+func (c *MockCache) DelBlockUndo(height uint64) {
+	c.memory.Delete(fmt.Sprintf("u:%d", height))
+}
+
+func (c *MockCache) SetBlockHash(height uint64, hash common.Hash) {
+	c.memory.Set(fmt.Sprintf("h:%d", height), hash, 0)
+}
+
+func (c *MockCache) GetBlockHash(height uint64) (common.Hash, bool) {
+	if hash, found := c.memory.Get(fmt.Sprintf("h:%d", height)); found {
+		return hash.(common.Hash), true
+	}
+	return common.Hash{}, false
+}
+
+func (c *MockCache) DelBlockHash(height uint64) {
+	c.memory.Delete(fmt.Sprintf("h:%d", height))
+}
+
+func (c *MockCache) SetSequencerCheckpoint(name string, sequence uint64) {
+	c.memory.Set("s:"+name, sequence, 0)
+}
+
+func (c *MockCache) GetSequencerCheckpoint(name string) (uint64, bool) {
+	if sequence, found := c.memory.Get("s:" + name); found {
+		return sequence.(uint64), true
+	}
+	return 0, false
+}
+
 var _ Cache = &MockCache{}
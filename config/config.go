@@ -20,6 +20,22 @@ type ChainConf struct {
 	WsEndpoint      string `json:"ws_endpoint"`
 }
 
+type RpcEndpointConf struct {
+	Name        string   `json:"name"`
+	Url         string   `json:"url"`
+	Weight      int      `json:"weight"`
+	RateLimit   float64  `json:"rate_limit"`
+	Connections int      `json:"connections"`
+	Roles       []string `json:"roles"`
+}
+
+type RpcPoolConf struct {
+	Endpoints            []*RpcEndpointConf `json:"endpoints"`
+	MaxHeadLag           uint64             `json:"max_head_lag"`
+	MaxConsecutiveErrors int                `json:"max_consecutive_errors"`
+	ProbeIntervalMs      int                `json:"probe_interval_ms"`
+}
+
 type RedisConf struct {
 	Addr     string `json:"addr"`
 	Username string `json:"username"`
@@ -27,10 +43,73 @@ type RedisConf struct {
 }
 
 type BlockGetterConf struct {
-	PoolSize         int       `json:"pool_size"`
-	QueueSize        int       `json:"queue_size"`
-	StartBlockNumber uint64    `json:"start_block_number"`
-	Retry            RetryConf `json:"retry"`
+	PoolSize               int                     `json:"pool_size"`
+	QueueSize              int                     `json:"queue_size"`
+	StartBlockNumber       uint64                  `json:"start_block_number"`
+	ReorgDepth             uint64                  `json:"reorg_depth"`
+	DispatchPolicy         string                  `json:"dispatch_policy"`
+	Confirmations          uint64                  `json:"confirmations"`
+	Backfill               BackfillConf            `json:"backfill"`
+	FailedBlockRetry       FailedBlockRetryConf    `json:"failed_block_retry"`
+	IngestionMode          string                  `json:"ingestion_mode"`
+	LogRangeSize           uint64                  `json:"log_range_size"`
+	HeadSource             string                  `json:"head_source"`
+	PollIntervalMs         int                     `json:"poll_interval_ms"`
+	WsMaxReconnectFailures int                     `json:"ws_max_reconnect_failures"`
+	Archive                ArchiveConf             `json:"archive"`
+	AdaptiveConcurrency    AdaptiveConcurrencyConf `json:"adaptive_concurrency"`
+	Retry                  RetryConf               `json:"retry"`
+}
+
+/*
+BackfillConf
+re-indexes [start_block_number, end_block_number] under its own finished block key, the holders, main pairs,
+kafka messages and the tokens and pairs cached in redis are left to the live indexer
+*/
+type BackfillConf struct {
+	Enabled          bool   `json:"enabled"`
+	StartBlockNumber uint64 `json:"start_block_number"`
+	EndBlockNumber   uint64 `json:"end_block_number"`
+}
+
+// Validate the values that would panic or spin at runtime
+func (c *BlockGetterConf) Validate() error {
+	if c.PollIntervalMs <= 0 {
+		return fmt.Errorf("block_getter.poll_interval_ms must be positive, got %d", c.PollIntervalMs)
+	}
+	return nil
+}
+
+/*
+MaxPoolSize
+the most blocks fetched at once, pool_size is only the initial size when adaptive concurrency is enabled
+*/
+func (c *BlockGetterConf) MaxPoolSize() int {
+	if c.AdaptiveConcurrency.Enabled {
+		return max(c.AdaptiveConcurrency.MaxPoolSize, c.PoolSize)
+	}
+	return c.PoolSize
+}
+
+type AdaptiveConcurrencyConf struct {
+	Enabled         bool    `json:"enabled"`
+	MinPoolSize     int     `json:"min_pool_size"`
+	MaxPoolSize     int     `json:"max_pool_size"`
+	IntervalMs      int     `json:"interval_ms"`
+	TargetLatencyMs int     `json:"target_latency_ms"`
+	MaxErrorRate    float64 `json:"max_error_rate"`
+}
+
+type ArchiveConf struct {
+	Mode          string `json:"mode"`
+	Dir           string `json:"dir"`
+	BlocksPerFile int    `json:"blocks_per_file"`
+}
+
+type FailedBlockRetryConf struct {
+	MaxAttempts    uint `json:"max_attempts"`
+	InitialDelayMs int  `json:"initial_delay_ms"`
+	MaxDelayMs     int  `json:"max_delay_ms"`
 }
 
 type BlockHandlerConf struct {
@@ -59,8 +138,143 @@ type RetryParams struct {
 	Timeout  time.Duration `json:"timeout"`
 }
 
+type SequencerConf struct {
+	StallTimeoutMs int    `json:"stall_timeout_ms"`
+	ReorderWindow  uint64 `json:"reorder_window"`
+}
+
+/*
+DexConf
+a dex on top of the built-in ones, a dex with the name of a built-in one replaces it,
+family is one of uniswap_v2, uniswap_v3, solidly and uniswap_v4,
+event_abis maps pool_created, swap, sync, mint, burn, initialize, collect, flash and modify_liquidity to the json abi of the event,
+events left out use the abi of the family,
+with init_code_hash v2 and v3 pools are verified by CREATE2 without calling the factory
+*/
+type DexConf struct {
+	Name         string            `json:"name"`
+	ProtocolId   int               `json:"protocol_id"`
+	Family       string            `json:"family"`
+	Factories    []string          `json:"factories"`
+	InitCodeHash string            `json:"init_code_hash"`
+	PoolDeployer string            `json:"pool_deployer"`
+	EventAbis    map[string]string `json:"event_abis"`
+}
+
+/*
+HolderConf
+indexes the Transfer events of the tokens in the token cache into holder balances,
+needs the token_pair database, off in a backfill and in the logs ingestion mode,
+balances are counted from the token discovery on, so the holder counts miss earlier holders who stay idle
+*/
+type HolderConf struct {
+	Enabled bool `json:"enabled"`
+}
+
+/*
+PoolStateConf
+keeps the last reserves, price and usd liquidity of every updated pool in the pool_state table,
+needs the token_pair database
+*/
+type PoolStateConf struct {
+	Enabled bool `json:"enabled"`
+}
+
+/*
+MainPairConf
+picks the main pair of each token among its pairs against a quote token by the usd liquidity of the pool states,
+another pair takes over only with more than (1 + hysteresis) times the liquidity of the main pair and at least
+min_liquidity_usd, tokens not updated for stale_blocks are dropped from memory and loaded again from the token_pair
+database, which it needs, with the pool_state table for the pairs not updated since a restart,
+the liquidity of a concentrated liquidity pool is the virtual reserves of its active range, deeper than the real
+reserves of a v2 pair holding the same funds, so it is weighted by concentrated_liquidity_weight, 0 leaves it as is
+*/
+type MainPairConf struct {
+	Enabled                     bool    `json:"enabled"`
+	Hysteresis                  float64 `json:"hysteresis"`
+	MinLiquidityUsd             float64 `json:"min_liquidity_usd"`
+	StaleBlocks                 uint64  `json:"stale_blocks"`
+	ConcentratedLiquidityWeight float64 `json:"concentrated_liquidity_weight"`
+}
+
+/*
+TokenCreatorConf
+resolves the creator and deployment block of new tokens, contracts deployed in the last recent_blocks
+blocks are taken from the block stream while parsing, older ones are searched on the archive endpoint
+by workers in the background after the block is committed, a search is tried again by retry,
+needs an archive endpoint and the token_pair database to keep the results
+*/
+type TokenCreatorConf struct {
+	Enabled      bool      `json:"enabled"`
+	RecentBlocks uint64    `json:"recent_blocks"`
+	Workers      int       `json:"workers"`
+	QueueSize    int       `json:"queue_size"`
+	Retry        RetryConf `json:"retry"`
+}
+
+/*
+CandleConf
+aggregates the swaps of each pair into usd candles of the intervals, among 1s, 1m, 5m, 1h and 1d,
+closed candles are written to the tx database and the open ones are rebuilt from its txs on restart,
+needs the tx database
+*/
+type CandleConf struct {
+	Enabled   bool     `json:"enabled"`
+	Intervals []string `json:"intervals"`
+}
+
+/*
+QuoteTokenConf
+the quote tokens in priority order, the token of a pair coming first becomes token1 and prices the other one,
+price_source is native for the native token and its wrapper, stable for a token pegged at usd_peg (1 when 0),
+or pool for a token priced by its pool against a quote token listed before it, the stable_token of the pool
+*/
+type QuoteTokenConf struct {
+	Address     string         `json:"address"`
+	Symbol      string         `json:"symbol"`
+	Decimals    int8           `json:"decimals"`
+	PriceSource string         `json:"price_source"`
+	UsdPeg      float64        `json:"usd_peg"`
+	Pool        *PricePoolConf `json:"pool"`
+}
+
+/*
+PricePoolConf
+a native/stable pool the native token price is read from, dex is the name of a registered dex,
+its family tells how the pool state is read: reserves for v2 and solidly, sqrtPriceX96 and liquidity for v3,
+stable marks a solidly stable pool, priced on its curve instead of the reserve ratio
+*/
+type PricePoolConf struct {
+	Address        string `json:"address"`
+	Dex            string `json:"dex"`
+	StableToken    string `json:"stable_token"`
+	StableDecimals int32  `json:"stable_decimals"`
+	Stable         bool   `json:"stable"`
+}
+
+/*
+PriceServiceConf
+pool_size > 0 prefetches the prices ahead of the parser with archive calls,
+pools farther than max_deviation from the liquidity weighted median are left out of the price
+*/
 type PriceServiceConf struct {
-	PoolSize int `json:"pool_size"`
+	PoolSize     int              `json:"pool_size"`
+	Pools        []*PricePoolConf `json:"pools"`
+	MaxDeviation float64          `json:"max_deviation"`
+}
+
+/*
+PriceGraphConf
+enabled keeps the pairs without a base token and prices their txs from the token prices of the graph,
+a token is priced over at most max_hops pools from a base token through its most liquid pool,
+pools with less than min_liquidity_usd are not used, pools not updated for stale_blocks are dropped,
+pairs already cached as filtered stay filtered until the cache is dropped
+*/
+type PriceGraphConf struct {
+	Enabled         bool    `json:"enabled"`
+	MaxHops         int     `json:"max_hops"`
+	MinLiquidityUsd float64 `json:"min_liquidity_usd"`
+	StaleBlocks     uint64  `json:"stale_blocks"`
 }
 
 type KafkaConf struct {
@@ -97,15 +311,25 @@ type DBConf struct {
 type Config struct {
 	Log               *LogConf            `json:"log"`
 	Chain             *ChainConf          `json:"chain"`
+	RpcPool           *RpcPoolConf        `json:"rpc_pool"`
 	Redis             *RedisConf          `json:"redis"`
 	BlockGetter       *BlockGetterConf    `json:"block_getter"`
 	BlockHandler      *BlockHandlerConf   `json:"block_handler"`
 	EnableSequencer   bool                `json:"enable_sequencer"`
+	Sequencer         *SequencerConf      `json:"sequencer"`
 	PriceService      *PriceServiceConf   `json:"price_service"`
+	PriceGraph        *PriceGraphConf     `json:"price_graph"`
+	QuoteTokens       []*QuoteTokenConf   `json:"quote_tokens"`
 	Kafka             *KafkaConf          `json:"kafka"`
 	ContractCaller    *ContractCallerConf `json:"contract_caller"`
 	TxDatabase        *DBConf             `json:"tx_database"`
 	TokenPairDatabase *DBConf             `json:"token_pair_database"`
+	Holder            *HolderConf         `json:"holder"`
+	PoolState         *PoolStateConf      `json:"pool_state"`
+	MainPair          *MainPairConf       `json:"main_pair"`
+	TokenCreator      *TokenCreatorConf   `json:"token_creator"`
+	Candle            *CandleConf         `json:"candle"`
+	Dexes             []*DexConf          `json:"dexes"`
 }
 
 var (
@@ -120,6 +344,12 @@ var (
 			EndpointArchive: "https://base-rpc.publicnode.com",
 			WsEndpoint:      "wss://base-rpc.publicnode.com",
 		},
+		RpcPool: &RpcPoolConf{
+			Endpoints:            nil,
+			MaxHeadLag:           5,
+			MaxConsecutiveErrors: 5,
+			ProbeIntervalMs:      5000,
+		},
 		Redis: &RedisConf{
 			Addr:     "localhost:6379",
 			Username: "",
@@ -129,6 +359,37 @@ var (
 			PoolSize:         1,
 			QueueSize:        1,
 			StartBlockNumber: 48000000,
+			ReorgDepth:       64,
+			DispatchPolicy:   "head",
+			Confirmations:    0,
+			Backfill: BackfillConf{
+				Enabled:          false,
+				StartBlockNumber: 0,
+				EndBlockNumber:   0,
+			},
+			FailedBlockRetry: FailedBlockRetryConf{
+				MaxAttempts:    20,
+				InitialDelayMs: 1000,
+				MaxDelayMs:     60000,
+			},
+			IngestionMode:          "blocks",
+			LogRangeSize:           100,
+			HeadSource:             "ws",
+			PollIntervalMs:         1000,
+			WsMaxReconnectFailures: 5,
+			Archive: ArchiveConf{
+				Mode:          "",
+				Dir:           "archive",
+				BlocksPerFile: 1000,
+			},
+			AdaptiveConcurrency: AdaptiveConcurrencyConf{
+				Enabled:         false,
+				MinPoolSize:     1,
+				MaxPoolSize:     64,
+				IntervalMs:      1000,
+				TargetLatencyMs: 1000,
+				MaxErrorRate:    0.05,
+			},
 			Retry: RetryConf{
 				Attempts:  10,
 				DelayMs:   100,
@@ -140,8 +401,19 @@ var (
 			QueueSize: 1,
 		},
 		EnableSequencer: true,
+		Sequencer: &SequencerConf{
+			StallTimeoutMs: 30000,
+			ReorderWindow:  1024,
+		},
 		PriceService: &PriceServiceConf{
-			PoolSize: 1,
+			PoolSize:     1,
+			MaxDeviation: 0.02,
+		},
+		PriceGraph: &PriceGraphConf{
+			Enabled:         false,
+			MaxHops:         2,
+			MinLiquidityUsd: 1000,
+			StaleBlocks:     302400,
 		},
 		Kafka: &KafkaConf{
 			Enabled:           false,
@@ -178,11 +450,93 @@ var (
 				DBName:   "test",
 			},
 		},
+		Holder: &HolderConf{
+			Enabled: false,
+		},
+		PoolState: &PoolStateConf{
+			Enabled: false,
+		},
+		MainPair: &MainPairConf{
+			Enabled:                     false,
+			Hysteresis:                  0.2,
+			MinLiquidityUsd:             0,
+			StaleBlocks:                 302400,
+			ConcentratedLiquidityWeight: 0.25,
+		},
+		TokenCreator: &TokenCreatorConf{
+			Enabled:      false,
+			RecentBlocks: 1000,
+			Workers:      2,
+			QueueSize:    10000,
+			Retry: RetryConf{
+				Attempts:  5,
+				DelayMs:   2000,
+				TimeoutMs: 60000,
+			},
+		},
+		Candle: &CandleConf{
+			Enabled:   false,
+			Intervals: []string{"1s", "1m", "5m", "1h", "1d"},
+		},
+		Dexes: []*DexConf{},
 	}
 
 	G = defaultConfig
 )
 
+func defaultPricePools() []*PricePoolConf {
+	return []*PricePoolConf{
+		{
+			Address:        "0x88A43bbDF9D098eEC7bCEda4e2494615dfD9bB9C",
+			Dex:            "NewSwap",
+			StableToken:    "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+			StableDecimals: 6,
+		},
+	}
+}
+
+func defaultQuoteTokens() []*QuoteTokenConf {
+	return []*QuoteTokenConf{
+		{
+			Address:     "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+			Symbol:      "USDC",
+			Decimals:    6,
+			PriceSource: "stable",
+		},
+		{
+			Address:     "0x51dA03503FBBA94B9d0D88C15690D840F02F15F4",
+			Symbol:      "WETH",
+			Decimals:    18,
+			PriceSource: "native",
+		},
+		{
+			Address:     "0x0000000000000000000000000000000000000000",
+			Symbol:      "ETH",
+			Decimals:    18,
+			PriceSource: "native",
+		},
+	}
+}
+
+func init() {
+	fillDefaultSlices(&G)
+}
+
+/*
+fillDefaultSlices
+the slices of pointers are left out of defaultConfig, the json decoder decodes into the elements already there
+and an entry of the config file would inherit the fields it leaves out from the default at its index,
+they are filled only when the config file gives none
+*/
+func fillDefaultSlices(c *Config) {
+	if c.PriceService != nil && len(c.PriceService.Pools) == 0 {
+		c.PriceService.Pools = defaultPricePools()
+	}
+	if len(c.QuoteTokens) == 0 {
+		c.QuoteTokens = defaultQuoteTokens()
+	}
+}
+
 func LoadConfigFile(configFilePath string) error {
 	file, err := os.Open(configFilePath)
 	if err != nil {
@@ -190,10 +544,18 @@ func LoadConfigFile(configFilePath string) error {
 	}
 	defer file.Close()
 
+	if G.PriceService != nil {
+		G.PriceService.Pools = nil
+	}
+	G.QuoteTokens = nil
 	decoder := json.NewDecoder(file)
 	if err = decoder.Decode(&G); err != nil {
 		return err
 	}
+	fillDefaultSlices(&G)
 
+	if G.BlockGetter != nil {
+		return G.BlockGetter.Validate()
+	}
 	return nil
 }
@@ -2,7 +2,10 @@ package config
 
 import (
 	"encoding/json"
+	"github.com/stretchr/testify/require"
 	"log"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -10,3 +13,28 @@ func TestGenerateConfig(t *testing.T) {
 	bs, _ := json.Marshal(G)
 	log.Println(string(bs))
 }
+
+func TestLoadConfigFile_SliceDefaults(t *testing.T) {
+	savedPools, savedQuoteTokens := G.PriceService.Pools, G.QuoteTokens
+	defer func() {
+		G.PriceService.Pools, G.QuoteTokens = savedPools, savedQuoteTokens
+	}()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"price_service": {"pools": [{"address": "0x01", "dex": "Aerodrome", "stable_token": "0x02"}]}}`), 0o644)
+	require.NoError(t, err)
+	require.NoError(t, LoadConfigFile(path))
+
+	// nothing inherited from the default pool
+	require.Len(t, G.PriceService.Pools, 1)
+	require.Equal(t, &PricePoolConf{Address: "0x01", Dex: "Aerodrome", StableToken: "0x02"}, G.PriceService.Pools[0])
+	require.Equal(t, defaultQuoteTokens(), G.QuoteTokens)
+}
+
+func TestBlockGetterConf_Validate(t *testing.T) {
+	conf := BlockGetterConf{PollIntervalMs: 1000}
+	require.NoError(t, conf.Validate())
+
+	conf.PollIntervalMs = 0
+	require.Error(t, conf.Validate())
+}
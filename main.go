@@ -1,12 +1,15 @@
 package main
 
 import (
+	"abchain_scan/abi"
 	"abchain_scan/block_getter"
 	"abchain_scan/cache"
 	"abchain_scan/config"
 	"abchain_scan/log"
 	"abchain_scan/parser"
+	"abchain_scan/parser/event_parser"
 	"abchain_scan/repository"
+	"abchain_scan/rpc_pool"
 	"abchain_scan/sequencer"
 	"abchain_scan/service"
 	"abchain_scan/types"
@@ -24,15 +27,28 @@ import (
 	"time"
 )
 
+/*
+holdersEnabled
+a backfill leaves the holders to the live indexer, the stored balances are already past its blocks,
+the logs ingestion mode has no token filter for eth_getLogs and would pull every Transfer on chain
+*/
+func holdersEnabled() bool {
+	return config.G.Holder.Enabled && !config.G.BlockGetter.Backfill.Enabled &&
+		config.G.BlockGetter.IngestionMode != block_getter.IngestionModeLogs
+}
+
 func createDBService() service.DBService {
 	var (
-		txDb            *gorm.DB
-		txDbErr         error
-		tokenPairDb     *gorm.DB
-		tokenPairDbErr  error
-		tokenRepository *repository.TokenRepository
-		pairRepository  *repository.PairRepository
-		txRepository    *repository.TxRepository
+		txDb                *gorm.DB
+		txDbErr             error
+		tokenPairDb         *gorm.DB
+		tokenPairDbErr      error
+		tokenRepository     *repository.TokenRepository
+		pairRepository      *repository.PairRepository
+		txRepository        *repository.TxRepository
+		holderRepository    *repository.TokenHolderRepository
+		poolStateRepository *repository.PoolStateRepository
+		candleRepository    *repository.CandleRepository
 	)
 
 	if config.G.TxDatabase.Enabled {
@@ -42,6 +58,9 @@ func createDBService() service.DBService {
 		}
 
 		txRepository = repository.NewTxRepository(txDb)
+		if config.G.Candle.Enabled {
+			candleRepository = repository.NewCandleRepository(txDb)
+		}
 	}
 
 	if config.G.TokenPairDatabase.Enabled {
@@ -52,9 +71,25 @@ func createDBService() service.DBService {
 
 		tokenRepository = repository.NewTokenRepository(tokenPairDb)
 		pairRepository = repository.NewPairRepository(tokenPairDb)
+		if holdersEnabled() {
+			holderRepository = repository.NewTokenHolderRepository(tokenPairDb)
+		}
+		if config.G.PoolState.Enabled {
+			poolStateRepository = repository.NewPoolStateRepository(tokenPairDb)
+		}
+	}
+
+	return service.NewDBService(tokenRepository, pairRepository, txRepository, holderRepository, poolStateRepository, candleRepository)
+}
+
+func createCache(redisCli *redis.Client) cache.Cache {
+	backfill := config.G.BlockGetter.Backfill
+	if backfill.Enabled {
+		return cache.NewTwoTierCacheWithFinishedBlockKey(redisCli,
+			cache.BackfillFinishedBlockKey(backfill.StartBlockNumber, backfill.EndBlockNumber))
 	}
 
-	return service.NewDBService(tokenRepository, pairRepository, txRepository)
+	return cache.NewTwoTierCache(redisCli)
 }
 
 func main() {
@@ -78,19 +113,30 @@ func main() {
 		log.Logger.Fatal("load config file err", zap.Error(loadConfigErr))
 	}
 
+	if loadDexErr := abi.LoadDexConf(config.G.Dexes); loadDexErr != nil {
+		log.Logger.Fatal("load dexes err", zap.Error(loadDexErr))
+	}
+	if loadQuoteErr := types.LoadQuoteTokenConf(config.G.QuoteTokens); loadQuoteErr != nil {
+		log.Logger.Fatal("load quote tokens err", zap.Error(loadQuoteErr))
+	}
+	if reloadErr := event_parser.Reload(); reloadErr != nil {
+		log.Logger.Fatal("build event parsers err", zap.Error(reloadErr))
+	}
+	log.Logger.Info("dexes", zap.Int("count", len(abi.Dexes)))
+
 	ethClient, dialEthErr := ethclient.Dial(config.G.Chain.Endpoint)
 	if dialEthErr != nil {
 		log.Logger.Fatal("Failed to connect to the chain(http): %v", zap.Error(dialEthErr))
 	}
 
-	ethClientArchive, dialEthErrArchive := ethclient.Dial(config.G.Chain.EndpointArchive)
-	if dialEthErrArchive != nil {
-		log.Logger.Fatal("Failed to connect to the chain archive(http): %v", zap.Error(dialEthErrArchive))
-	}
-
-	wsEthClient, dialEthWsErr := ethclient.Dial(config.G.Chain.WsEndpoint)
-	if dialEthWsErr != nil {
-		log.Logger.Fatal("Failed to connect to the chain(ws): %v", zap.Error(dialEthWsErr))
+	var wsEthClient *ethclient.Client
+	if config.G.BlockGetter.HeadSource != block_getter.HeadSourcePoll && config.G.Chain.WsEndpoint != "" {
+		var dialEthWsErr error
+		wsEthClient, dialEthWsErr = ethclient.Dial(config.G.Chain.WsEndpoint)
+		if dialEthWsErr != nil {
+			log.Logger.Error("Failed to connect to the chain(ws), poll the chain head instead", zap.Error(dialEthWsErr))
+			wsEthClient = nil
+		}
 	}
 
 	redisCli := redis.NewClient(&redis.Options{
@@ -98,35 +144,99 @@ func main() {
 		Username: config.G.Redis.Username,
 		Password: config.G.Redis.Password,
 	})
-	cache := cache.NewTwoTierCache(redisCli)
+	backfill := config.G.BlockGetter.Backfill
+	cache := createCache(redisCli)
+
+	rpcPool := rpc_pool.NewPool(config.G.RpcPool, config.G.Chain)
+	contractCaller := service.NewContractCaller(rpcPool, rpc_pool.RoleCalls, config.G.ContractCaller.Retry.GetRetryParams())
 
-	contractCaller := service.NewContractCaller(ethClient, config.G.ContractCaller.Retry.GetRetryParams())
+	dbService := createDBService()
+	var tokenCreatorService service.TokenCreatorService
+	if config.G.TokenCreator.Enabled {
+		if !config.G.TokenPairDatabase.Enabled {
+			log.Logger.Warn("token creations found after the commit are only written to the cache without the token_pair database")
+		}
+		tokenCreatorService = service.NewTokenCreatorService(rpcPool, cache, dbService, config.G.TokenCreator)
+		tokenCreatorService.Start()
+	}
+	pairService := service.NewPairService(cache, contractCaller, tokenCreatorService)
+	var priceGraphService service.PriceGraphService
+	if config.G.PriceGraph.Enabled {
+		priceGraphService = service.NewPriceGraphService(config.G.PriceGraph)
+		types.SetKeepNonBasePairs(true)
+	}
+	contractCallerArchive := service.NewContractCaller(rpcPool, rpc_pool.RoleArchive, config.G.ContractCaller.Retry.GetRetryParams())
+	priceService := service.NewPriceService(cache, contractCallerArchive, ethClient, config.G.PriceService)
 
-	pairService := service.NewPairService(cache, contractCaller)
-	contractCallerArchive := service.NewContractCaller(ethClientArchive, config.G.ContractCaller.Retry.GetRetryParams())
-	priceService := service.NewPriceService(cache, contractCallerArchive, ethClient, config.G.PriceService.PoolSize)
+	var candleService service.CandleService
+	if config.G.Candle.Enabled {
+		if !config.G.TxDatabase.Enabled {
+			log.Logger.Warn("candles without the tx database are neither written nor rebuilt on restart")
+		}
+		candleService = service.NewCandleService(dbService, config.G.Candle)
+	}
 
-	sequencerForBlockHandler := sequencer.NewSequencer()
+	if config.G.Holder.Enabled && config.G.BlockGetter.IngestionMode == block_getter.IngestionModeLogs {
+		log.Logger.Warn("holders are not indexed in the logs ingestion mode, the Transfer topic would pull every transfer on chain")
+	}
 
-	topicRouter := parser.NewTopicRouter()
-	kafkaSender := service.NewKafkaSender(config.G.Kafka)
+	if backfill.Enabled {
+		log.Logger.Warn("backfill writes no holders, main pairs or kafka messages and keeps the tokens and pairs it caches out of redis, they follow the live indexer")
+	}
+
+	var mainPairService service.MainPairService
+	if config.G.MainPair.Enabled && !backfill.Enabled {
+		if !config.G.TokenPairDatabase.Enabled {
+			log.Logger.Fatal("main pair needs the token_pair database")
+		}
+		mainPairService = service.NewMainPairService(dbService, config.G.MainPair)
+	}
+
+	sequencerForBlockHandler := sequencer.NewCheckpointedSequencer("block_parser", cache)
+
+	topicRouter := parser.NewTopicRouter(holdersEnabled())
+	var kafkaSender service.KafkaSender
+	if backfill.Enabled {
+		kafkaSender = service.NewNopKafkaSender()
+	} else {
+		kafkaSender = service.NewKafkaSender(config.G.Kafka)
+	}
 
 	blockParser := parser.NewBlockParser(
 		cache,
 		sequencerForBlockHandler,
 		priceService,
 		pairService,
+		tokenCreatorService,
+		priceGraphService,
+		candleService,
+		mainPairService,
 		topicRouter,
 		kafkaSender,
-		createDBService(),
+		dbService,
 	)
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	blockParser.Start(wg)
 
-	sequencerForBlockGetter := sequencer.NewSequencer()
-	blockGetter := block_getter.NewBlockGetter(wsEthClient, cache, sequencerForBlockGetter, config.G.BlockGetter.Retry.GetRetryParams())
-	startBlockNumber := blockGetter.GetStartBlockNumber(config.G.BlockGetter.StartBlockNumber)
+	sequencerForBlockGetter := sequencer.NewSequencer("block_getter")
+	var blockGetter block_getter.BlockGetter
+	if archive := config.G.BlockGetter.Archive; archive.Mode == block_getter.ArchiveModeReplay {
+		blockGetter = block_getter.NewReplayBlockGetter(archive.Dir, cache)
+	} else {
+		blockGetter = block_getter.NewBlockGetter(ethClient, wsEthClient, rpcPool, topicRouter.Topics(), cache, sequencerForBlockGetter, config.G.BlockGetter.Retry.GetRetryParams())
+	}
+	var startBlockNumber uint64
+	if backfill.Enabled {
+		if backfill.StartBlockNumber == 0 || backfill.EndBlockNumber < backfill.StartBlockNumber {
+			log.Logger.Fatal("invalid backfill range",
+				zap.Uint64("start", backfill.StartBlockNumber),
+				zap.Uint64("end", backfill.EndBlockNumber))
+		}
+		startBlockNumber = blockGetter.GetBackfillStartBlockNumber(backfill.StartBlockNumber, backfill.EndBlockNumber)
+	} else {
+		startBlockNumber = blockGetter.GetStartBlockNumber(config.G.BlockGetter.StartBlockNumber)
+	}
 	if startBlockNumber == 0 {
 		log.Logger.Fatal("start block number is zero")
 	}
@@ -136,7 +246,11 @@ func main() {
 
 	priceService.Start(startBlockNumber)
 	blockGetter.Start()
-	blockGetter.StartDispatch(startBlockNumber)
+	if backfill.Enabled {
+		blockGetter.StartBackfill(startBlockNumber, backfill.EndBlockNumber, sequencerForBlockHandler.WaitingOn())
+	} else {
+		blockGetter.StartDispatch(startBlockNumber, sequencerForBlockHandler.WaitingOn())
+	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
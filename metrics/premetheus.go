@@ -37,6 +37,14 @@ var (
 		Objectives: defaultObjectives,
 	})
 
+	GetLogsDurationMs = prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "get_logs_duration_ms",
+		Help:       "get logs of a block range duration in Milliseconds",
+		MaxAge:     defaultMaxAge,
+		AgeBuckets: defaultAgeBuckets,
+		Objectives: defaultObjectives,
+	})
+
 	BlockDelay = prometheus.NewSummary(prometheus.SummaryOpts{
 		Name:       "block_delay",
 		Help:       "block delay in Seconds",
@@ -47,6 +55,28 @@ var (
 
 	BlockQueueSize = prometheus.NewGauge(prometheus.GaugeOpts{Name: "block_queue_size"})
 
+	BlockRetryQueueSize = prometheus.NewGauge(prometheus.GaugeOpts{Name: "block_retry_queue_size"})
+
+	BlockRetryTotal = prometheus.NewCounter(prometheus.CounterOpts{Name: "block_retry_total"})
+
+	ReorgTotal = prometheus.NewCounter(prometheus.CounterOpts{Name: "reorg_total"})
+
+	ReorgDepth = prometheus.NewGauge(prometheus.GaugeOpts{Name: "reorg_depth", Help: "orphaned blocks count of the last reorg"})
+
+	ReorgMissingUndoTotal = prometheus.NewCounter(prometheus.CounterOpts{Name: "reorg_missing_undo_total", Help: "orphaned blocks rolled back without their undo data"})
+
+	DispatchLag = prometheus.NewGauge(prometheus.GaugeOpts{Name: "dispatch_lag", Help: "blocks between the chain head and the dispatch height"})
+
+	BackfillProgress = prometheus.NewGauge(prometheus.GaugeOpts{Name: "backfill_progress", Help: "finished ratio of the backfill range"})
+
+	BlockGetterConcurrency = prometheus.NewGauge(prometheus.GaugeOpts{Name: "block_getter_concurrency", Help: "blocks fetched at once"})
+
+	SequencerPending = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "sequencer_pending", Help: "items parked until their sequence comes"}, []string{"sequencer"})
+
+	SequencerWaitingOn = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "sequencer_waiting_on"}, []string{"sequencer"})
+
+	SequencerStalled = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "sequencer_stalled", Help: "1 while nothing is committed for stall_timeout_ms with items pending"}, []string{"sequencer"})
+
 	ParseBlockDurationMs = prometheus.NewSummary(prometheus.SummaryOpts{
 		Name:       "parse_block_duration_ms",
 		Help:       "parse block duration in Milliseconds",
@@ -55,6 +85,8 @@ var (
 		Objectives: defaultObjectives,
 	})
 
+	SkippedTxTotal = prometheus.NewCounter(prometheus.CounterOpts{Name: "skipped_tx_total", Help: "txs skipped since the sender is unknown"})
+
 	DbOperationDurationMs = prometheus.NewSummary(prometheus.SummaryOpts{
 		Name:       "db_operation_duration_ms",
 		Help:       "db operation duration in Milliseconds",
@@ -107,6 +139,25 @@ var (
 		Objectives: defaultObjectives,
 	})
 
+	RpcRequestDurationMs = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "rpc_request_duration_ms",
+		Help:       "rpc request duration in Milliseconds by endpoint",
+		MaxAge:     defaultMaxAge,
+		AgeBuckets: defaultAgeBuckets,
+		Objectives: defaultObjectives,
+	}, []string{"endpoint"})
+
+	RpcRequestErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rpc_request_errors_total",
+		},
+		[]string{"endpoint"},
+	)
+
+	RpcEndpointHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "rpc_endpoint_healthy"}, []string{"endpoint"})
+
+	RpcEndpointHeadLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "rpc_endpoint_head_lag"}, []string{"endpoint"})
+
 	VerifyPairDurationMs = prometheus.NewSummary(prometheus.SummaryOpts{
 		Name:       "verify_pair_duration_ms",
 		MaxAge:     defaultMaxAge,
@@ -127,6 +178,74 @@ var (
 		},
 		[]string{"protocol"},
 	)
+
+	TransferTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transfer_total",
+		},
+		[]string{"result"},
+	)
+
+	NativeTokenPrice = prometheus.NewGauge(prometheus.GaugeOpts{Name: "native_token_price"})
+
+	NativePriceSourceTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "native_price_source_total",
+		},
+		[]string{"source"},
+	)
+
+	NativePriceOutlierTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "native_price_outlier_total",
+		},
+		[]string{"pool"},
+	)
+
+	PoolStateV3ReadErrTotal = prometheus.NewCounter(prometheus.CounterOpts{Name: "pool_state_v3_read_err_total", Help: "v3 pools whose state after a mint or burn could not be read from the chain"})
+
+	PriceGraphPools  = prometheus.NewGauge(prometheus.GaugeOpts{Name: "price_graph_pools"})
+	PriceGraphTokens = prometheus.NewGauge(prometheus.GaugeOpts{Name: "price_graph_tokens"})
+
+	TokenCreationTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "token_creation_total",
+		},
+		[]string{"source"},
+	)
+
+	TokenCreationSearchDurationMs = prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "token_creation_search_duration_ms",
+		MaxAge:     defaultMaxAge,
+		AgeBuckets: defaultAgeBuckets,
+		Objectives: defaultObjectives,
+	})
+
+	OpenCandles = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "open_candles",
+		},
+		[]string{"interval"},
+	)
+
+	ClosedCandleTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "closed_candle_total",
+		},
+		[]string{"interval"},
+	)
+
+	CandleRebuildDurationMs = prometheus.NewGauge(prometheus.GaugeOpts{Name: "candle_rebuild_duration_ms"})
+
+	MainPairTokens      = prometheus.NewGauge(prometheus.GaugeOpts{Name: "main_pair_tokens"})
+	MainPairChangeTotal = prometheus.NewCounter(prometheus.CounterOpts{Name: "main_pair_change_total"})
+
+	VerifyPairByMethod = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "verify_pair_by_method_total",
+		},
+		[]string{"method"},
+	)
 )
 
 func init() {
@@ -136,10 +255,23 @@ func init() {
 
 	prometheus.MustRegister(GetBlockDurationMs)
 	prometheus.MustRegister(GetBlockReceiptsDurationMs)
+	prometheus.MustRegister(GetLogsDurationMs)
 	prometheus.MustRegister(BlockDelay)
 	prometheus.MustRegister(BlockQueueSize)
+	prometheus.MustRegister(BlockRetryQueueSize)
+	prometheus.MustRegister(BlockRetryTotal)
+	prometheus.MustRegister(ReorgTotal)
+	prometheus.MustRegister(ReorgDepth)
+	prometheus.MustRegister(ReorgMissingUndoTotal)
+	prometheus.MustRegister(DispatchLag)
+	prometheus.MustRegister(BackfillProgress)
+	prometheus.MustRegister(BlockGetterConcurrency)
+	prometheus.MustRegister(SequencerPending)
+	prometheus.MustRegister(SequencerWaitingOn)
+	prometheus.MustRegister(SequencerStalled)
 
 	prometheus.MustRegister(ParseBlockDurationMs)
+	prometheus.MustRegister(SkippedTxTotal)
 	prometheus.MustRegister(DbOperationDurationMs)
 	prometheus.MustRegister(SendBlockKafkaDurationMs)
 
@@ -149,9 +281,29 @@ func init() {
 	prometheus.MustRegister(GetPairDurationMs)
 	prometheus.MustRegister(GetTokenDurationMs)
 
+	prometheus.MustRegister(RpcRequestDurationMs)
+	prometheus.MustRegister(RpcRequestErrors)
+	prometheus.MustRegister(RpcEndpointHealthy)
+	prometheus.MustRegister(RpcEndpointHeadLag)
+
 	prometheus.MustRegister(VerifyPairDurationMs)
 	prometheus.MustRegister(VerifyPairTotal)
 	prometheus.MustRegister(VerifyPairOkByProtocol)
+	prometheus.MustRegister(VerifyPairByMethod)
+	prometheus.MustRegister(TransferTotal)
+	prometheus.MustRegister(NativeTokenPrice)
+	prometheus.MustRegister(NativePriceSourceTotal)
+	prometheus.MustRegister(NativePriceOutlierTotal)
+	prometheus.MustRegister(PoolStateV3ReadErrTotal)
+	prometheus.MustRegister(PriceGraphPools)
+	prometheus.MustRegister(PriceGraphTokens)
+	prometheus.MustRegister(TokenCreationTotal)
+	prometheus.MustRegister(TokenCreationSearchDurationMs)
+	prometheus.MustRegister(OpenCandles)
+	prometheus.MustRegister(ClosedCandleTotal)
+	prometheus.MustRegister(CandleRebuildDurationMs)
+	prometheus.MustRegister(MainPairTokens)
+	prometheus.MustRegister(MainPairChangeTotal)
 }
 
 func init() {
@@ -5,15 +5,16 @@ import (
 	"abchain_scan/config"
 	"abchain_scan/log"
 	"abchain_scan/metrics"
+	"abchain_scan/repository/orm"
 	"abchain_scan/sequencer"
 	"abchain_scan/service"
 	"abchain_scan/types"
 	"fmt"
+	"github.com/ethereum/go-ethereum/common"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/panjf2000/ants/v2"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
-	"math/big"
 	"sync"
 	"time"
 )
@@ -27,22 +28,31 @@ type BlockParser interface {
 type blockParser struct {
 	inputQueue   chan *types.ParseBlockContext
 	workPool     *ants.Pool
-	cache        cache.BlockCache
+	cache        cache.Cache
 	sequencer    sequencer.Sequencer
 	outputQueue  chan *types.ParseBlockContext
 	priceService service.PriceService
 	pairService  service.PairService
+	creators     service.TokenCreatorService
+	priceGraph   service.PriceGraphService
+	candles      service.CandleService
+	mainPairs    service.MainPairService
 	topicRouter  TopicRouter
 	kafkaSender  service.KafkaSender
 	dbService    service.DBService
 	parseTxPool  *ants.Pool
+	commitState  *commitState
 }
 
 func NewBlockParser(
-	cache cache.BlockCache,
+	cache cache.Cache,
 	sequencer sequencer.Sequencer,
 	priceService service.PriceService,
 	pairService service.PairService,
+	tokenCreatorService service.TokenCreatorService,
+	priceGraphService service.PriceGraphService,
+	candleService service.CandleService,
+	mainPairService service.MainPairService,
 	topicRouter TopicRouter,
 	kafkaSender service.KafkaSender,
 	dbService service.DBService,
@@ -65,10 +75,15 @@ func NewBlockParser(
 		outputQueue:  make(chan *types.ParseBlockContext, config.G.BlockHandler.QueueSize),
 		priceService: priceService,
 		pairService:  pairService,
+		creators:     tokenCreatorService,
+		priceGraph:   priceGraphService,
+		candles:      candleService,
+		mainPairs:    mainPairService,
 		topicRouter:  topicRouter,
 		kafkaSender:  kafkaSender,
 		dbService:    dbService,
 		parseTxPool:  parseTxPool,
+		commitState:  newCommitState(config.G.BlockGetter.ReorgDepth, cache),
 	}
 }
 
@@ -109,18 +124,24 @@ func (p *blockParser) Stop() {
 }
 
 func (p *blockParser) ParseBlockAsync(bw *types.ParseBlockContext) {
+	p.commitState.start(bw.GetSequence())
+	if bw.Reorg != nil {
+		p.commitState.waitCommitted(bw.GetSequence() - 1)
+		p.rollback(bw.Reorg)
+	}
+	p.sequencer.WaitForWindow(bw.GetSequence())
 	p.inputQueue <- bw
 }
 
-func (p *blockParser) waitForNativeTokenPrice(blockNumber *big.Int) decimal.Decimal {
+func (p *blockParser) waitForBlockPrices(pbc *types.ParseBlockContext) (decimal.Decimal, types.TokenPrices) {
 	for {
-		bnbPrice, err := p.priceService.GetNativeTokenPrice(blockNumber)
+		bnbPrice, quoteTokenPrices, err := p.priceService.GetBlockPrices(pbc)
 		if err != nil {
-			log.Logger.Error("get price err", zap.Error(err), zap.Any("blockNumber", blockNumber))
+			log.Logger.Error("get price err", zap.Error(err), zap.Uint64("blockNumber", pbc.HeightTime.Height))
 			time.Sleep(time.Millisecond * 100)
 			continue
 		}
-		return bnbPrice
+		return bnbPrice, quoteTokenPrices
 	}
 }
 
@@ -143,25 +164,34 @@ func collectNewPairAndTokens(br *types.BlockResult, pairWrap *types.PairWrap) {
 type TxResultAndPairWrap struct {
 	TxResult  *types.TxResult
 	PairWraps []*types.PairWrap
+	Events    []types.Event // in log order
 }
 
 func (p *blockParser) parseTxReceipt(pbc *types.ParseBlockContext, txReceipt *ethtypes.Receipt) *TxResultAndPairWrap {
 	txSender, err := pbc.GetTxSender(txReceipt.TransactionIndex)
 	if err != nil {
-		log.Logger.Fatal("Err: get tx sender err",
+		log.Logger.Error("Err: get tx sender err, skip tx",
 			zap.Error(err),
-			zap.Any("pbc", pbc),
-			zap.Any("txReceipt", txReceipt),
+			zap.Uint64("height", pbc.HeightTime.Height),
+			zap.String("txHash", txReceipt.TxHash.String()),
 		)
+		metrics.SkippedTxTotal.Inc()
+		return nil
 	}
 
 	tr := types.NewTxResult(txSender)
 	pairWraps := make([]*types.PairWrap, 0, len(txReceipt.Logs))
+	events := make([]types.Event, 0, len(txReceipt.Logs))
 	for _, ethLog := range txReceipt.Logs {
 		if len(ethLog.Topics) == 0 {
 			continue
 		}
 
+		if transfer, transferErr := p.topicRouter.ParseTransfer(ethLog); transferErr == nil {
+			p.collectTransfer(tr, transfer)
+			continue
+		}
+
 		event, parseErr := p.topicRouter.Parse(ethLog)
 		if parseErr != nil {
 			continue
@@ -176,19 +206,42 @@ func (p *blockParser) parseTxReceipt(pbc *types.ParseBlockContext, txReceipt *et
 		event.SetPair(pairWrap.Pair)
 		event.SetBlockTime(pbc.HeightTime.Time)
 		tr.AddEvent(event)
+		events = append(events, event)
 	}
 
 	return &TxResultAndPairWrap{
 		TxResult:  tr,
 		PairWraps: pairWraps,
+		Events:    events,
 	}
 }
 
+// collectTransfer only tokens already in the token cache are tracked, everything else on chain is skipped
+func (p *blockParser) collectTransfer(tr *types.TxResult, transfer *types.Transfer) {
+	token, ok := p.cache.GetToken(transfer.Token)
+	if !ok || token.Filtered {
+		metrics.TransferTotal.WithLabelValues("unknown_token").Inc()
+		return
+	}
+
+	metrics.TransferTotal.WithLabelValues("indexed").Inc()
+	transfer.Decimals = token.Decimals
+	tr.AddTransfer(transfer)
+}
+
 func (p *blockParser) parseBlock(pbc *types.ParseBlockContext) {
-	pbc.NativeTokenPrice = p.waitForNativeTokenPrice(pbc.HeightTime.HeightBigInt)
+	pbc.NativeTokenPrice, pbc.QuoteTokenPrices = p.waitForBlockPrices(pbc)
+	// before the txs are parsed, a token deployed in this block may get its first pool in this block too
+	if p.creators != nil {
+		p.creators.ObserveBlock(pbc)
+	}
 
 	now := time.Now()
 	br := types.NewBlockResult(pbc.HeightTime.Height, pbc.HeightTime.Timestamp, pbc.NativeTokenPrice)
+	br.QuoteTokenPrices = pbc.QuoteTokenPrices
+	if p.priceGraph != nil {
+		br.PriceGraph = p.priceGraph
+	}
 
 	wg := &sync.WaitGroup{}
 	results := make([]*TxResultAndPairWrap, len(pbc.BlockReceipts))
@@ -205,6 +258,7 @@ func (p *blockParser) parseBlock(pbc *types.ParseBlockContext) {
 		})
 	}
 	wg.Wait()
+	p.applyLiquidityChangesV3(pbc.HeightTime.HeightBigInt, results)
 
 	for _, result := range results {
 		if result == nil {
@@ -241,9 +295,24 @@ func (p *blockParser) getPairByEvent(event types.Event) *types.PairWrap {
 		return p.pairService.GetPairTokens(pair)
 	}
 
+	if poolId := event.GetPoolId(); poolId != (common.Hash{}) {
+		return p.pairService.GetPoolV4(poolId)
+	}
+
 	return p.pairService.GetPair(event.GetPairAddress(), event.GetPossibleProtocolIds())
 }
 
+// unresolvedTokens the new tokens whose creation was not in the recent blocks
+func unresolvedTokens(newTokens map[common.Address]*types.Token) []common.Address {
+	var tokens []common.Address
+	for address, token := range newTokens {
+		if token.Creator == (common.Address{}) && !types.IsNativeToken(address) {
+			tokens = append(tokens, address)
+		}
+	}
+	return tokens
+}
+
 func (p *blockParser) commitBlockResult(blockResult *types.BlockResult) {
 	blockInfo := blockResult.GetKafkaMessage()
 
@@ -252,6 +321,9 @@ func (p *blockParser) commitBlockResult(blockResult *types.BlockResult) {
 	if err != nil {
 		log.Logger.Fatal("add tokens err", zap.Any("height", blockInfo.Height), zap.Error(err))
 	}
+	if p.creators != nil {
+		p.creators.Resolve(unresolvedTokens(blockResult.NewTokens))
+	}
 
 	err = p.dbService.AddPairs(blockInfo.NewPairs)
 	if err != nil {
@@ -263,6 +335,51 @@ func (p *blockParser) commitBlockResult(blockResult *types.BlockResult) {
 		log.Logger.Fatal("add txs err", zap.Any("height", blockInfo.Height), zap.Error(err))
 	}
 
+	holders := make([]*orm.TokenHolder, 0, len(blockInfo.BalanceDeltas))
+	for _, delta := range blockInfo.BalanceDeltas {
+		holders = append(holders, delta.GetOrmTokenHolder(blockInfo.Height))
+	}
+	err = p.dbService.AddTokenHolders(holders)
+	if err != nil {
+		log.Logger.Fatal("add token holders err", zap.Any("height", blockInfo.Height), zap.Error(err))
+	}
+
+	poolStates := make([]*orm.PoolState, 0, len(blockInfo.PoolUpdates)+len(blockInfo.PoolUpdatesV3))
+	for _, pu := range blockInfo.PoolUpdates {
+		poolStates = append(poolStates, pu.GetOrmPoolState(blockInfo.Height))
+	}
+	for _, pu := range blockInfo.PoolUpdatesV3 {
+		poolStates = append(poolStates, pu.GetOrmPoolState(blockInfo.Height))
+	}
+	block := newCommittedBlock(blockInfo)
+	block.poolStates, err = p.poolStateUndos(poolStates)
+	if err != nil {
+		log.Logger.Fatal("get pool states err", zap.Any("height", blockInfo.Height), zap.Error(err))
+	}
+	err = p.dbService.UpsertPoolStates(poolStates)
+	if err != nil {
+		log.Logger.Fatal("upsert pool states err", zap.Any("height", blockInfo.Height), zap.Error(err))
+	}
+
+	var mainPairChanges []*types.MainPairChange
+	if p.mainPairs != nil {
+		mainPairChanges, err = p.mainPairs.Update(blockInfo.Height, poolStates)
+		if err != nil {
+			log.Logger.Fatal("update main pairs err", zap.Any("height", blockInfo.Height), zap.Error(err))
+		}
+		err = p.dbService.UpdateMainPairs(mainPairChanges)
+		if err != nil {
+			log.Logger.Fatal("write main pairs err", zap.Any("height", blockInfo.Height), zap.Error(err))
+		}
+	}
+
+	if p.candles != nil {
+		blockInfo.Candles, err = p.candles.Apply(blockInfo)
+		if err != nil {
+			log.Logger.Fatal("apply candles err", zap.Any("height", blockInfo.Height), zap.Error(err))
+		}
+	}
+
 	duration := time.Since(now)
 	metrics.DbOperationDurationMs.Observe(float64(duration.Milliseconds()))
 	log.Logger.Info("db operation duration",
@@ -271,13 +388,30 @@ func (p *blockParser) commitBlockResult(blockResult *types.BlockResult) {
 		zap.String("price", blockInfo.NativeTokenPrice),
 		zap.Int("new tokens", len(blockInfo.NewTokens)),
 		zap.Int("new pairs", len(blockInfo.NewPairs)),
-		zap.Int("txs", len(blockInfo.Txs)))
+		zap.Int("txs", len(blockInfo.Txs)),
+		zap.Int("balance deltas", len(blockInfo.BalanceDeltas)),
+		zap.Int("pool states", len(poolStates)),
+		zap.Int("candles", len(blockInfo.Candles)),
+		zap.Int("main pair changes", len(mainPairChanges)))
 
 	err = p.kafkaSender.Send(blockInfo)
 	if err != nil {
 		log.Logger.Fatal("kafka send msg err", zap.Error(err), zap.Any("block", blockResult.Height))
 	}
 
+	if len(mainPairChanges) > 0 {
+		err = p.kafkaSender.SendMainPairChange(&types.MainPairChangeInfo{
+			MainPairChanged: true,
+			Height:          blockResult.Height,
+			Changes:         mainPairChanges,
+		})
+		if err != nil {
+			log.Logger.Fatal("kafka send main pair change msg err", zap.Error(err), zap.Any("block", blockResult.Height))
+		}
+	}
+
+	block.mainPairChanges = mainPairChanges
+	p.commitState.record(blockResult.Height, block)
 	p.cache.SetFinishedBlock(blockResult.Height)
 	metrics.CurrentHeight.Set(float64(blockResult.Height))
 	metrics.TxCntByBlock.Set(float64(len(blockInfo.Txs)))
@@ -294,6 +428,7 @@ func (p *blockParser) startHandleBlockResult(wg *sync.WaitGroup) {
 			}
 
 			p.commitBlockResult(blockContext.BlockResult)
+			p.commitState.markCommitted(blockContext.GetSequence())
 		}
 	}()
 }
@@ -21,6 +21,10 @@ func (o *BurnEventParserV3) Parse(ethLog *ethtypes.Log) (types.Event, error) {
 		EventCommon: types.EventCommonFromEthLog(ethLog),
 		Amount0Wei:  input[1].(*big.Int),
 		Amount1Wei:  input[2].(*big.Int),
+		// Burn(indexed owner, indexed tickLower, indexed tickUpper, amount, amount0, amount1)
+		TickLower:    topicInt24(ethLog.Topics[2]),
+		TickUpper:    topicInt24(ethLog.Topics[3]),
+		LiquidityWei: input[0].(*big.Int),
 	}
 
 	e.Pair = &types.Pair{
@@ -0,0 +1,33 @@
+package event_parser
+
+import (
+	"abchain_scan/parser/event_parser/event"
+	"abchain_scan/types"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+type CollectEventParserV3 struct {
+	PoolEventParser
+}
+
+func (o *CollectEventParserV3) Parse(ethLog *ethtypes.Log) (types.Event, error) {
+	input, err := o.ethLogUnpacker.Unpack(ethLog)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &event.CollectEvent{
+		EventCommon: types.EventCommonFromEthLog(ethLog),
+		Amount0Wei:  input[1].(*big.Int),
+		Amount1Wei:  input[2].(*big.Int),
+	}
+
+	e.Pair = &types.Pair{
+		Address: ethLog.Address,
+	}
+
+	e.PossibleProtocolIds = o.PossibleProtocolIds
+
+	return e, nil
+}
@@ -3,5 +3,6 @@ package event_parser
 import "errors"
 
 var (
-	ErrWrongFactoryAddress = errors.New("wrong factory address")
+	ErrWrongFactoryAddress     = errors.New("wrong factory address")
+	ErrWrongPoolManagerAddress = errors.New("wrong pool manager address")
 )
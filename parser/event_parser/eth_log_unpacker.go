@@ -1,8 +1,10 @@
 package event_parser
 
 import (
+	"encoding/binary"
 	"errors"
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -33,3 +35,8 @@ func (p *EthLogUnpacker) Unpack(ethLog *ethtypes.Log) ([]interface{}, error) {
 
 	return eventInput, nil
 }
+
+// topicInt24 an indexed int24 such as a tick, sign extended to the 32 bytes of the topic
+func topicInt24(topic common.Hash) int64 {
+	return int64(int32(binary.BigEndian.Uint32(topic[28:])))
+}
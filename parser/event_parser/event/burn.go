@@ -11,6 +11,11 @@ type BurnEvent struct {
 	*types.EventCommon
 	Amount0Wei *big.Int
 	Amount1Wei *big.Int
+	// the position of a concentrated liquidity burn, LiquidityWei is nil for v2 pairs
+	TickLower    int64
+	TickUpper    int64
+	LiquidityWei *big.Int
+	poolUpdateV3 *types.PoolUpdateV3
 }
 
 func (e *BurnEvent) CanGetTx() bool {
@@ -37,4 +42,25 @@ func (e *BurnEvent) GetTx(bnbPrice decimal.Decimal) *orm.Tx {
 	return tx
 }
 
+// LiquidityChangeV3 the range of the position and the liquidity removed as a negative delta, false for a v2 burn
+func (e *BurnEvent) LiquidityChangeV3() (tickLower, tickUpper int64, delta *big.Int, ok bool) {
+	if e.LiquidityWei == nil {
+		return 0, 0, nil, false
+	}
+	return e.TickLower, e.TickUpper, new(big.Int).Neg(e.LiquidityWei), true
+}
+
+// SetPoolStateV3 the state of the pool right after the burn
+func (e *BurnEvent) SetPoolStateV3(sqrtPriceX96, liquidity, tick *big.Int) {
+	e.poolUpdateV3 = NewPoolUpdateV3(e.EventCommon, sqrtPriceX96, liquidity, tick)
+}
+
+func (e *BurnEvent) CanGetPoolUpdateV3() bool {
+	return e.poolUpdateV3 != nil
+}
+
+func (e *BurnEvent) GetPoolUpdateV3() *types.PoolUpdateV3 {
+	return e.poolUpdateV3
+}
+
 var _ types.Event = (*BurnEvent)(nil)
@@ -0,0 +1,32 @@
+package event
+
+import (
+	"abchain_scan/types"
+	"math/big"
+)
+
+/*
+CollectEvent
+v3 fees and burnt liquidity leaving the pool, the amounts are no trade so it only yields a pool update parameter
+*/
+type CollectEvent struct {
+	*types.EventCommon
+	Amount0Wei *big.Int
+	Amount1Wei *big.Int
+}
+
+func (e *CollectEvent) CanGetPoolUpdateParameter() bool {
+	return true
+}
+
+func (e *CollectEvent) GetPoolUpdateParameter() *types.PoolUpdateParameter {
+	return &types.PoolUpdateParameter{
+		BlockNumber:   e.BlockNumber,
+		PairAddress:   e.Pair.Address,
+		PoolId:        e.Pair.PoolId,
+		Token0Address: e.Pair.Token0Core.Address,
+		Token1Address: e.Pair.Token1Core.Address,
+	}
+}
+
+var _ types.Event = (*CollectEvent)(nil)
@@ -0,0 +1,34 @@
+package event
+
+import (
+	"abchain_scan/types"
+	"math/big"
+)
+
+/*
+FlashEvent
+v3 flash loan, the fees paid back stay in the pool so the balances change without a swap
+*/
+type FlashEvent struct {
+	*types.EventCommon
+	Amount0Wei *big.Int
+	Amount1Wei *big.Int
+	Paid0Wei   *big.Int
+	Paid1Wei   *big.Int
+}
+
+func (e *FlashEvent) CanGetPoolUpdateParameter() bool {
+	return true
+}
+
+func (e *FlashEvent) GetPoolUpdateParameter() *types.PoolUpdateParameter {
+	return &types.PoolUpdateParameter{
+		BlockNumber:   e.BlockNumber,
+		PairAddress:   e.Pair.Address,
+		PoolId:        e.Pair.PoolId,
+		Token0Address: e.Pair.Token0Core.Address,
+		Token1Address: e.Pair.Token1Core.Address,
+	}
+}
+
+var _ types.Event = (*FlashEvent)(nil)
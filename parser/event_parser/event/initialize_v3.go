@@ -0,0 +1,31 @@
+package event
+
+import (
+	"abchain_scan/types"
+	"math/big"
+)
+
+/*
+InitializeEventV3
+initial price of a v3 pool, emitted once right after PoolCreated, the pool has no liquidity yet
+*/
+type InitializeEventV3 struct {
+	*types.EventCommon
+	SqrtPriceX96 *big.Int
+	Tick         *big.Int
+}
+
+func (e *InitializeEventV3) CanGetPoolUpdateV3() bool {
+	return true
+}
+
+func (e *InitializeEventV3) GetPoolUpdateV3() *types.PoolUpdateV3 {
+	return NewPoolUpdateV3(e.EventCommon, e.SqrtPriceX96, big.NewInt(0), e.Tick)
+}
+
+// PoolStateV3 the state of the pool after the initialize
+func (e *InitializeEventV3) PoolStateV3() (sqrtPriceX96, liquidity, tick *big.Int) {
+	return e.SqrtPriceX96, big.NewInt(0), e.Tick
+}
+
+var _ types.Event = (*InitializeEventV3)(nil)
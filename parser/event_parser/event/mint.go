@@ -11,6 +11,11 @@ type MintEvent struct {
 	*types.EventCommon
 	Amount0Wei *big.Int
 	Amount1Wei *big.Int
+	// the position of a concentrated liquidity mint, LiquidityWei is nil for v2 pairs
+	TickLower    int64
+	TickUpper    int64
+	LiquidityWei *big.Int
+	poolUpdateV3 *types.PoolUpdateV3
 }
 
 func (e *MintEvent) GetMintAmount() (decimal.Decimal, decimal.Decimal) {
@@ -45,4 +50,25 @@ func (e *MintEvent) IsMint() bool {
 	return true
 }
 
+// LiquidityChangeV3 the range of the position and the liquidity added, false for a v2 mint
+func (e *MintEvent) LiquidityChangeV3() (tickLower, tickUpper int64, delta *big.Int, ok bool) {
+	if e.LiquidityWei == nil {
+		return 0, 0, nil, false
+	}
+	return e.TickLower, e.TickUpper, e.LiquidityWei, true
+}
+
+// SetPoolStateV3 the state of the pool right after the mint
+func (e *MintEvent) SetPoolStateV3(sqrtPriceX96, liquidity, tick *big.Int) {
+	e.poolUpdateV3 = NewPoolUpdateV3(e.EventCommon, sqrtPriceX96, liquidity, tick)
+}
+
+func (e *MintEvent) CanGetPoolUpdateV3() bool {
+	return e.poolUpdateV3 != nil
+}
+
+func (e *MintEvent) GetPoolUpdateV3() *types.PoolUpdateV3 {
+	return e.poolUpdateV3
+}
+
 var _ types.Event = (*MintEvent)(nil)
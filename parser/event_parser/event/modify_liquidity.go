@@ -0,0 +1,34 @@
+package event
+
+import (
+	"abchain_scan/types"
+	"math/big"
+)
+
+/*
+ModifyLiquidityEvent
+uniswap v4 liquidity change, it carries liquidity rather than token amounts so it yields no tx,
+only a pool update parameter
+*/
+type ModifyLiquidityEvent struct {
+	*types.EventCommon
+	TickLower      *big.Int
+	TickUpper      *big.Int
+	LiquidityDelta *big.Int
+}
+
+func (e *ModifyLiquidityEvent) CanGetPoolUpdateParameter() bool {
+	return true
+}
+
+func (e *ModifyLiquidityEvent) GetPoolUpdateParameter() *types.PoolUpdateParameter {
+	return &types.PoolUpdateParameter{
+		BlockNumber:   e.BlockNumber,
+		PairAddress:   e.Pair.Address,
+		PoolId:        e.Pair.PoolId,
+		Token0Address: e.Pair.Token0Core.Address,
+		Token1Address: e.Pair.Token1Core.Address,
+	}
+}
+
+var _ types.Event = (*ModifyLiquidityEvent)(nil)
@@ -9,8 +9,13 @@ import (
 
 type SwapEventV3 struct {
 	*types.EventCommon
-	Amount0Wei *big.Int
-	Amount1Wei *big.Int
+	Amount0Wei       *big.Int
+	Amount1Wei       *big.Int
+	SqrtPriceX96     *big.Int
+	Liquidity        *big.Int
+	Tick             *big.Int
+	ProtocolFees0Wei *big.Int // pancake v3 only
+	ProtocolFees1Wei *big.Int // pancake v3 only
 }
 
 func (e *SwapEventV3) CanGetTx() bool {
@@ -27,7 +32,7 @@ func (e *SwapEventV3) GetTx(bnbPrice decimal.Decimal) *orm.Tx {
 		BlockAt:       e.BlockTime,
 		BlockIndex:    e.TxIndex,
 		TxIndex:       e.LogIndex,
-		PairAddress:   e.Pair.Address.String(),
+		PairAddress:   e.Pair.AddressString(),
 		Program:       types.GetProtocolName(e.Pair.ProtocolId),
 	}
 
@@ -45,6 +50,23 @@ func (e *SwapEventV3) GetTx(bnbPrice decimal.Decimal) *orm.Tx {
 	return tx
 }
 
+func (e *SwapEventV3) CanGetPoolUpdateV3() bool {
+	return e.SqrtPriceX96 != nil
+}
+
+func (e *SwapEventV3) GetPoolUpdateV3() *types.PoolUpdateV3 {
+	poolUpdate := NewPoolUpdateV3(e.EventCommon, e.SqrtPriceX96, e.Liquidity, e.Tick)
+	if e.ProtocolFees0Wei != nil {
+		poolUpdate.ProtocolFees0, poolUpdate.ProtocolFees1 = ParseAmountsByPair(e.ProtocolFees0Wei, e.ProtocolFees1Wei, e.Pair)
+	}
+	return poolUpdate
+}
+
+// PoolStateV3 the state of the pool after the swap
+func (e *SwapEventV3) PoolStateV3() (sqrtPriceX96, liquidity, tick *big.Int) {
+	return e.SqrtPriceX96, e.Liquidity, e.Tick
+}
+
 func (e *SwapEventV3) CanGetPoolUpdateParameter() bool {
 	return true
 }
@@ -53,6 +75,7 @@ func (e *SwapEventV3) GetPoolUpdateParameter() *types.PoolUpdateParameter {
 	return &types.PoolUpdateParameter{
 		BlockNumber:   e.BlockNumber,
 		PairAddress:   e.Pair.Address,
+		PoolId:        e.Pair.PoolId,
 		Token0Address: e.Pair.Token0Core.Address,
 		Token1Address: e.Pair.Token1Core.Address,
 	}
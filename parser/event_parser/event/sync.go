@@ -22,6 +22,7 @@ func (e *SyncEvent) GetPoolUpdate() *types.PoolUpdate {
 		Address:       e.ContractAddress,
 		Token0Address: e.Pair.Token0Core.Address,
 		Token1Address: e.Pair.Token1Core.Address,
+		Stable:        e.Pair.Stable,
 	}
 
 	pu.Token0Amount, pu.Token1Amount = ParseAmountsByPair(e.Amount0Wei, e.Amount1Wei, e.Pair)
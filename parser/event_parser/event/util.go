@@ -18,21 +18,83 @@ func ParseAmountsByPair(token0AmountWei, token1AmountWei *big.Int, pair *types.P
 	return
 }
 
+var (
+	// q96 2^96, sqrtPriceX96 is the square root of the price in Q96
+	q96 = decimal.NewFromBigInt(new(big.Int).Lsh(big.NewInt(1), 96), 0)
+	// q192 2^192, sqrtPriceX96 squared is the price in Q192
+	q192 = decimal.NewFromBigInt(new(big.Int).Lsh(big.NewInt(1), 192), 0)
+)
+
+const priceDivisionPrecision = 36
+
+/*
+PriceFromSqrtPriceX96
+price of Token0Core in Token1Core, sqrtPriceX96 is in the pool's order so a reversed pair takes the inverse
+*/
+func PriceFromSqrtPriceX96(sqrtPriceX96 *big.Int, pair *types.Pair) decimal.Decimal {
+	if sqrtPriceX96 == nil || sqrtPriceX96.Sign() == 0 {
+		return decimal.Zero
+	}
+	priceX192 := decimal.NewFromBigInt(new(big.Int).Mul(sqrtPriceX96, sqrtPriceX96), 0)
+	decimalsShift := int32(pair.Token0Core.Decimals) - int32(pair.Token1Core.Decimals)
+	if !pair.TokensReversed {
+		return priceX192.Shift(decimalsShift).DivRound(q192, priceDivisionPrecision)
+	}
+	return q192.Shift(decimalsShift).DivRound(priceX192, priceDivisionPrecision)
+}
+
+/*
+VirtualReservesFromSqrtPriceX96
+the reserves a v2 pool with the same active liquidity and price would hold,
+liquidity / sqrtPrice of token0 and liquidity * sqrtPrice of token1 in the pool's order, returned in the pair's order
+*/
+func VirtualReservesFromSqrtPriceX96(sqrtPriceX96, liquidity *big.Int, pair *types.Pair) (token0Reserve, token1Reserve decimal.Decimal) {
+	if sqrtPriceX96 == nil || liquidity == nil || sqrtPriceX96.Sign() == 0 || liquidity.Sign() == 0 {
+		return decimal.Zero, decimal.Zero
+	}
+	sqrtPrice := decimal.NewFromBigInt(sqrtPriceX96, 0)
+	l := decimal.NewFromBigInt(liquidity, 0)
+	reserve0Wei := l.Mul(q96).DivRound(sqrtPrice, 0).BigInt()
+	reserve1Wei := l.Mul(sqrtPrice).DivRound(q96, 0).BigInt()
+	return ParseAmountsByPair(reserve0Wei, reserve1Wei, pair)
+}
+
+// NewPoolUpdateV3 the event must have its pair set
+func NewPoolUpdateV3(e *types.EventCommon, sqrtPriceX96, liquidity, tick *big.Int) *types.PoolUpdateV3 {
+	token0Reserve, token1Reserve := VirtualReservesFromSqrtPriceX96(sqrtPriceX96, liquidity, e.Pair)
+	return &types.PoolUpdateV3{
+		Program:       types.GetProtocolName(e.GetProtocolId()),
+		LogIndex:      e.LogIndex,
+		Address:       e.Pair.Address,
+		PoolId:        e.Pair.PoolId,
+		Token0Address: e.Pair.Token0Core.Address,
+		Token1Address: e.Pair.Token1Core.Address,
+		SqrtPriceX96:  decimal.NewFromBigInt(sqrtPriceX96, 0),
+		Liquidity:     decimal.NewFromBigInt(liquidity, 0),
+		Tick:          tick.Int64(),
+		Price:         PriceFromSqrtPriceX96(sqrtPriceX96, e.Pair),
+		Token0Reserve: token0Reserve,
+		Token1Reserve: token1Reserve,
+	}
+}
+
+/*
+CalcAmountAndPrice
+usd of a tx quoted in a native or stable priced quote token, pool priced quote tokens
+and pairs without a quote token are priced later with the prices of the block
+*/
 func CalcAmountAndPrice(
 	bnbPrice decimal.Decimal,
 	token0Amount, token1Amount decimal.Decimal,
 	token1Address common.Address,
 ) (amountUSD, priceUSD decimal.Decimal) {
-	if types.IsWETH(token1Address) {
-		amountUSD = token1Amount.Mul(bnbPrice)
-		if !token0Amount.IsZero() {
-			priceUSD = amountUSD.Div(token0Amount)
-		}
-	} else if types.IsUSDC(token1Address) {
-		amountUSD = token1Amount
-		if !token0Amount.IsZero() {
-			priceUSD = amountUSD.Div(token0Amount)
-		}
+	quotePrice, ok := types.QuoteTokenUsdPrice(token1Address, bnbPrice)
+	if !ok {
+		return
+	}
+	amountUSD = token1Amount.Mul(quotePrice)
+	if !token0Amount.IsZero() {
+		priceUSD = amountUSD.Div(token0Amount)
 	}
 	return
 }
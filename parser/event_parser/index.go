@@ -2,122 +2,163 @@ package event_parser
 
 import (
 	"abchain_scan/abi"
-	uniswapv2 "abchain_scan/abi/uniswap/v2"
-	uniswapv3 "abchain_scan/abi/uniswap/v3"
+	"abchain_scan/log"
+	"fmt"
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"reflect"
 )
 
-var (
-	pairCreatedEventParser = &PairCreatedEventParser{
-		FactoryEventParser: FactoryEventParser{
-			Topic:                    uniswapv2.PairCreatedTopic0,
-			PossibleFactoryAddresses: abi.Topic2FactoryAddresses[uniswapv2.PairCreatedTopic0],
-			LogUnpacker: EthLogUnpacker{
-				AbiEvent:      uniswapv2.PairCreatedEvent,
-				TopicLen:      3,
-				DataUnpackLen: 2,
-			},
-		},
+var Topic2EventParser map[common.Hash]EventParser
+
+func init() {
+	if err := Reload(); err != nil {
+		log.Logger.Fatal("Failed to build event parsers", zap.Error(err))
 	}
+}
 
-	burnEventParser = &BurnEventParser{
-		PoolEventParser: PoolEventParser{
-			Topic:               uniswapv2.BurnTopic0,
-			PossibleProtocolIds: abi.Topic2ProtocolIds[uniswapv2.BurnTopic0],
-			ethLogUnpacker: EthLogUnpacker{
-				AbiEvent:      uniswapv2.BurnEvent,
-				TopicLen:      3,
-				DataUnpackLen: 2,
-			},
-		},
+/*
+Reload
+rebuilds Topic2EventParser from abi.Dexes, called again at startup once the dexes of the config are registered
+*/
+func Reload() error {
+	topic2EventParser, err := NewTopic2EventParser()
+	if err != nil {
+		return err
 	}
+	Topic2EventParser = topic2EventParser
+	return nil
+}
 
-	swapEventParser = &SwapEventParser{
-		PoolEventParser: PoolEventParser{
-			Topic:               uniswapv2.SwapTopic0,
-			PossibleProtocolIds: abi.Topic2ProtocolIds[uniswapv2.SwapTopic0],
-			ethLogUnpacker: EthLogUnpacker{
-				AbiEvent:      uniswapv2.SwapEvent,
-				TopicLen:      3,
-				DataUnpackLen: 4,
-			},
-		},
+func newEthLogUnpacker(event *ethabi.Event) EthLogUnpacker {
+	topicLen := 1
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			topicLen++
+		}
+	}
+	return EthLogUnpacker{
+		AbiEvent:      event,
+		TopicLen:      topicLen,
+		DataUnpackLen: len(event.Inputs.NonIndexed()),
 	}
+}
 
-	syncEventParser = &SyncEventParser{
-		PoolEventParser: PoolEventParser{
-			Topic:               uniswapv2.SyncTopic0,
-			PossibleProtocolIds: abi.Topic2ProtocolIds[uniswapv2.SyncTopic0],
-			ethLogUnpacker: EthLogUnpacker{
-				AbiEvent:      uniswapv2.SyncEvent,
-				TopicLen:      1,
-				DataUnpackLen: 2,
-			},
-		},
+func newFactoryEventParser(event *ethabi.Event) FactoryEventParser {
+	return FactoryEventParser{
+		Topic:                    event.ID,
+		PossibleFactoryAddresses: abi.Topic2FactoryAddresses[event.ID],
+		LogUnpacker:              newEthLogUnpacker(event),
 	}
+}
 
-	mintEventParser = &MintEventParser{
-		PoolEventParser: PoolEventParser{
-			Topic:               uniswapv2.MintTopic0,
-			PossibleProtocolIds: abi.Topic2ProtocolIds[uniswapv2.MintTopic0],
-			ethLogUnpacker: EthLogUnpacker{
-				AbiEvent:      uniswapv2.MintEvent,
-				TopicLen:      2,
-				DataUnpackLen: 2,
-			},
-		},
+func newPoolEventParser(event *ethabi.Event) PoolEventParser {
+	return PoolEventParser{
+		Topic:               event.ID,
+		PossibleProtocolIds: abi.Topic2ProtocolIds[event.ID],
+		ethLogUnpacker:      newEthLogUnpacker(event),
 	}
+}
+
+// newEventParser pool events sharing a topic across dexes share one parser, the possible protocol ids tell them apart
+func newEventParser(dex *abi.Dex, eventName string, poolManagers map[common.Address]struct{}) EventParser {
+	event := dex.Events[eventName]
+	switch dex.Family {
+	case abi.FamilyUniswapV2, abi.FamilySolidly:
+		switch eventName {
+		case abi.EventPoolCreated:
+			if dex.Family == abi.FamilySolidly {
+				return &PoolCreatedEventParserAerodrome{FactoryEventParser: newFactoryEventParser(event)}
+			}
+			return &PairCreatedEventParser{FactoryEventParser: newFactoryEventParser(event)}
+		case abi.EventSwap:
+			return &SwapEventParser{PoolEventParser: newPoolEventParser(event)}
+		case abi.EventSync:
+			return &SyncEventParser{PoolEventParser: newPoolEventParser(event)}
+		case abi.EventMint:
+			return &MintEventParser{PoolEventParser: newPoolEventParser(event)}
+		case abi.EventBurn:
+			return &BurnEventParser{PoolEventParser: newPoolEventParser(event)}
+		}
 
-	Topic2EventParser = map[common.Hash]EventParser{
-		uniswapv2.PairCreatedTopic0: pairCreatedEventParser,
-		uniswapv2.MintTopic0:        mintEventParser,
-		uniswapv2.BurnTopic0:        burnEventParser,
-		uniswapv2.SwapTopic0:        swapEventParser,
-		uniswapv2.SyncTopic0:        syncEventParser,
+	case abi.FamilyUniswapV3:
+		switch eventName {
+		case abi.EventPoolCreated:
+			return &PoolCreatedEventParser{FactoryEventParser: newFactoryEventParser(event)}
+		case abi.EventSwap:
+			return &SwapEventParserV3{PoolEventParser: newPoolEventParser(event)}
+		case abi.EventMint:
+			return &MintEventParserV3{PoolEventParser: newPoolEventParser(event)}
+		case abi.EventBurn:
+			return &BurnEventParserV3{PoolEventParser: newPoolEventParser(event)}
+		case abi.EventInitialize:
+			return &InitializeEventParserV3{PoolEventParser: newPoolEventParser(event)}
+		case abi.EventCollect:
+			return &CollectEventParserV3{PoolEventParser: newPoolEventParser(event)}
+		case abi.EventFlash:
+			return &FlashEventParserV3{PoolEventParser: newPoolEventParser(event)}
+		}
 
-		uniswapv3.PoolCreatedTopic0: &PoolCreatedEventParser{
-			FactoryEventParser: FactoryEventParser{
-				Topic:                    uniswapv3.PoolCreatedTopic0,
-				PossibleFactoryAddresses: abi.Topic2FactoryAddresses[uniswapv3.PoolCreatedTopic0],
-				LogUnpacker: EthLogUnpacker{
-					AbiEvent:      uniswapv3.PoolCreatedEvent,
-					TopicLen:      4,
-					DataUnpackLen: 2,
-				},
-			},
-		},
-		uniswapv3.MintTopic0: &MintEventParserV3{
-			PoolEventParser: PoolEventParser{
-				Topic:               uniswapv3.MintTopic0,
-				PossibleProtocolIds: abi.Topic2ProtocolIds[uniswapv3.MintTopic0],
-				ethLogUnpacker: EthLogUnpacker{
-					AbiEvent:      uniswapv3.MintEvent,
-					TopicLen:      4,
-					DataUnpackLen: 4,
-				},
-			},
-		},
-		uniswapv3.BurnTopic0: &BurnEventParserV3{
-			PoolEventParser: PoolEventParser{
-				Topic:               uniswapv3.BurnTopic0,
-				PossibleProtocolIds: abi.Topic2ProtocolIds[uniswapv3.BurnTopic0],
-				ethLogUnpacker: EthLogUnpacker{
-					AbiEvent:      uniswapv3.BurnEvent,
-					TopicLen:      4,
-					DataUnpackLen: 3,
-				},
-			},
-		},
-		uniswapv3.SwapTopic0: &SwapEventParserV3{
-			PoolEventParser: PoolEventParser{
-				Topic:               uniswapv3.SwapTopic0,
-				PossibleProtocolIds: abi.Topic2ProtocolIds[uniswapv3.SwapTopic0],
-				ethLogUnpacker: EthLogUnpacker{
-					AbiEvent:      uniswapv3.SwapEvent,
-					TopicLen:      3,
-					DataUnpackLen: 5,
-				},
-			},
-		},
+	case abi.FamilyUniswapV4:
+		switch eventName {
+		case abi.EventPoolCreated:
+			return &InitializeEventParserV4{FactoryEventParser: newFactoryEventParser(event)}
+		case abi.EventSwap:
+			return &SwapEventParserV4{PoolEventParser: newPoolEventParser(event), PoolManagerAddresses: poolManagers}
+		case abi.EventModifyLiquidity:
+			return &ModifyLiquidityEventParserV4{PoolEventParser: newPoolEventParser(event), PoolManagerAddresses: poolManagers}
+		}
 	}
-)
+	return nil
+}
+
+/*
+NewTopic2EventParser
+one parser per topic, a topic shared by dexes needs the same parser and the same indexed inputs on all of them
+*/
+func NewTopic2EventParser() (map[common.Hash]EventParser, error) {
+	// v4 pool events are emitted by the PoolManager, the only address they are trusted from
+	topic2PoolManagers := make(map[common.Hash]map[common.Address]struct{})
+	for _, dex := range abi.Dexes {
+		if dex.Family != abi.FamilyUniswapV4 {
+			continue
+		}
+		for _, eventName := range abi.FamilyEvents[dex.Family] {
+			topic := dex.Events[eventName].ID
+			if topic2PoolManagers[topic] == nil {
+				topic2PoolManagers[topic] = make(map[common.Address]struct{})
+			}
+			for _, factory := range dex.Factories {
+				topic2PoolManagers[topic][factory] = struct{}{}
+			}
+		}
+	}
+
+	topic2EventParser := make(map[common.Hash]EventParser)
+	topic2Unpacker := make(map[common.Hash]EthLogUnpacker)
+	for _, dex := range abi.Dexes {
+		for _, eventName := range abi.FamilyEvents[dex.Family] {
+			event := dex.Events[eventName]
+			eventParser := newEventParser(dex, eventName, topic2PoolManagers[event.ID])
+			if eventParser == nil {
+				return nil, fmt.Errorf("dex %s: no parser for %s event of family %s", dex.Name, eventName, dex.Family)
+			}
+
+			unpacker := newEthLogUnpacker(event)
+			existing, ok := topic2EventParser[event.ID]
+			if !ok {
+				topic2EventParser[event.ID] = eventParser
+				topic2Unpacker[event.ID] = unpacker
+				continue
+			}
+			if reflect.TypeOf(existing) != reflect.TypeOf(eventParser) {
+				return nil, fmt.Errorf("dex %s: %s topic %s already parsed by %T", dex.Name, eventName, event.ID, existing)
+			}
+			if topic2Unpacker[event.ID].TopicLen != unpacker.TopicLen || topic2Unpacker[event.ID].DataUnpackLen != unpacker.DataUnpackLen {
+				return nil, fmt.Errorf("dex %s: %s topic %s indexed differently by another dex", dex.Name, eventName, event.ID)
+			}
+		}
+	}
+	return topic2EventParser, nil
+}
@@ -0,0 +1,33 @@
+package event_parser
+
+import (
+	"abchain_scan/parser/event_parser/event"
+	"abchain_scan/types"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+type InitializeEventParserV3 struct {
+	PoolEventParser
+}
+
+func (o *InitializeEventParserV3) Parse(ethLog *ethtypes.Log) (types.Event, error) {
+	input, err := o.ethLogUnpacker.Unpack(ethLog)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &event.InitializeEventV3{
+		EventCommon:  types.EventCommonFromEthLog(ethLog),
+		SqrtPriceX96: input[0].(*big.Int),
+		Tick:         input[1].(*big.Int),
+	}
+
+	e.Pair = &types.Pair{
+		Address: ethLog.Address,
+	}
+
+	e.PossibleProtocolIds = o.PossibleProtocolIds
+
+	return e, nil
+}
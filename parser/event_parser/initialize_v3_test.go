@@ -0,0 +1,56 @@
+package event_parser
+
+import (
+	uniswapv3 "abchain_scan/abi/uniswap/v3"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestInitialize_UniswapV3(t *testing.T) {
+	// 2500 USDC per WETH, sqrt(2500 * 1e6 / 1e18) * 2^96
+	sqrtPriceX96 := new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 96), big.NewInt(20000))
+	data, err := uniswapv3.InitializeEvent.Inputs.NonIndexed().Pack(sqrtPriceX96, big.NewInt(-198080))
+	require.NoError(t, err)
+
+	poolAddress := common.HexToAddress("0xd0b53D9277642d899DF5C87A3966A349A798F224")
+	ethLog := &ethtypes.Log{
+		Address: poolAddress,
+		Topics:  []common.Hash{uniswapv3.InitializeTopic0},
+		Data:    data,
+	}
+
+	e, pErr := Topic2EventParser[ethLog.Topics[0]].Parse(ethLog)
+	require.NoError(t, pErr)
+	require.Equal(t, poolAddress, e.GetPairAddress())
+	require.ElementsMatch(t, []int{types.ProtocolIdUniswapV3, types.ProtocolIdPancakeV3}, e.GetPossibleProtocolIds())
+	require.True(t, e.CanGetPoolUpdateV3())
+
+	weth := &types.TokenCore{Address: common.HexToAddress(types.WETH), Decimals: 18}
+	usdc := &types.TokenCore{Address: common.HexToAddress(types.USDC), Decimals: 6}
+	e.SetPair(&types.Pair{
+		Address:    poolAddress,
+		Token0Core: weth,
+		Token1Core: usdc,
+		ProtocolId: types.ProtocolIdUniswapV3,
+	})
+	pu := e.GetPoolUpdateV3()
+	require.Equal(t, int64(-198080), pu.Tick)
+	require.True(t, pu.Liquidity.IsZero())
+	require.Equal(t, "2500", pu.Price.Round(6).String())
+
+	// the pair trades USDC against WETH, the price is inverted
+	e.SetPair(&types.Pair{
+		Address:        poolAddress,
+		TokensReversed: true,
+		Token0Core:     usdc,
+		Token1Core:     weth,
+		ProtocolId:     types.ProtocolIdUniswapV3,
+	})
+	pu = e.GetPoolUpdateV3()
+	require.True(t, decimal.RequireFromString("0.0004").Equal(pu.Price.Round(12)))
+}
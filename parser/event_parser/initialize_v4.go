@@ -0,0 +1,57 @@
+package event_parser
+
+import (
+	"abchain_scan/abi"
+	"abchain_scan/parser/event_parser/event"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+/*
+InitializeEventParserV4
+uniswap v4 pool creation, the pool is identified by the PoolId in topic 1
+*/
+type InitializeEventParserV4 struct {
+	FactoryEventParser
+}
+
+func (o *InitializeEventParserV4) Parse(ethLog *ethtypes.Log) (types.Event, error) {
+	pair := &types.Pair{}
+
+	_, ok := o.PossibleFactoryAddresses[ethLog.Address]
+	if !ok {
+		pair.Filtered = true
+		pair.FilterCode = types.FilterCodeWrongFactory
+		return nil, ErrWrongFactoryAddress
+	}
+
+	input, err := o.LogUnpacker.Unpack(ethLog)
+	if err != nil {
+		pair.Filtered = true
+		pair.FilterCode = types.FilterCodeUnpackDataErr
+		return nil, err
+	}
+
+	e := &event.PairCreatedEvent{
+		EventCommon: types.EventCommonFromEthLog(ethLog),
+	}
+
+	pair.PoolId = ethLog.Topics[1]
+	pair.Address = types.V4PairAddress(pair.PoolId)
+	pair.Token0Core = &types.TokenCore{
+		Address: common.BytesToAddress(ethLog.Topics[2].Bytes()[12:]),
+	}
+	pair.Token1Core = &types.TokenCore{
+		Address: common.BytesToAddress(ethLog.Topics[3].Bytes()[12:]),
+	}
+	pair.Hooks = input[2].(common.Address)
+	pair.Block = ethLog.BlockNumber
+	pair.ProtocolId = abi.FactoryAddress2ProtocolId[ethLog.Address]
+
+	pair.FilterByToken0AndToken1()
+
+	e.Pair = pair
+
+	return e, nil
+}
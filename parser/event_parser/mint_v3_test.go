@@ -1,9 +1,13 @@
 package event_parser
 
 import (
+	uniswapv3 "abchain_scan/abi/uniswap/v3"
+	"abchain_scan/parser/event_parser/event"
 	"abchain_scan/repository/orm"
 	"abchain_scan/service"
 	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 	"math/big"
@@ -95,3 +99,24 @@ func TestMint_PancakeV3(t *testing.T) {
 	}
 	require.True(t, tx.Equal(expectTx), "expect: %v, actual: %v", expectTx, tx)
 }
+
+func TestMint_V3Ticks(t *testing.T) {
+	data, err := uniswapv3.MintEvent.Inputs.NonIndexed().Pack(common.HexToAddress("0x01"), big.NewInt(1000), big.NewInt(1), big.NewInt(2))
+	require.NoError(t, err)
+
+	// indexed int24 ticks are sign extended to 32 bytes
+	tickLower := common.BigToHash(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(887220)))
+	ethLog := &ethtypes.Log{
+		Address: common.HexToAddress("0xd0b53D9277642d899DF5C87A3966A349A798F224"),
+		Topics:  []common.Hash{uniswapv3.MintTopic0, common.HexToHash("0x02"), tickLower, common.BigToHash(big.NewInt(60))},
+		Data:    data,
+	}
+
+	e, pErr := Topic2EventParser[ethLog.Topics[0]].Parse(ethLog)
+	require.NoError(t, pErr)
+	tickLow, tickUp, delta, ok := e.(*event.MintEvent).LiquidityChangeV3()
+	require.True(t, ok)
+	require.Equal(t, int64(-887220), tickLow)
+	require.Equal(t, int64(60), tickUp)
+	require.Equal(t, int64(1000), delta.Int64())
+}
@@ -0,0 +1,41 @@
+package event_parser
+
+import (
+	"abchain_scan/parser/event_parser/event"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+type ModifyLiquidityEventParserV4 struct {
+	PoolEventParser
+	PoolManagerAddresses map[common.Address]struct{}
+}
+
+func (o *ModifyLiquidityEventParserV4) Parse(ethLog *ethtypes.Log) (types.Event, error) {
+	if _, ok := o.PoolManagerAddresses[ethLog.Address]; !ok {
+		return nil, ErrWrongPoolManagerAddress
+	}
+
+	input, err := o.ethLogUnpacker.Unpack(ethLog)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &event.ModifyLiquidityEvent{
+		EventCommon:    types.EventCommonFromEthLog(ethLog),
+		TickLower:      input[0].(*big.Int),
+		TickUpper:      input[1].(*big.Int),
+		LiquidityDelta: input[2].(*big.Int),
+	}
+
+	e.Pair = &types.Pair{
+		Address: types.V4PairAddress(ethLog.Topics[1]),
+		PoolId:  ethLog.Topics[1],
+	}
+
+	e.PossibleProtocolIds = o.PossibleProtocolIds
+
+	return e, nil
+}
@@ -1,10 +1,15 @@
 package event_parser
 
 import (
+	"abchain_scan/abi/aerodrome"
+	pancakev2 "abchain_scan/abi/pancake/v2"
+	uniswapv2 "abchain_scan/abi/uniswap/v2"
 	"abchain_scan/service"
 	"abchain_scan/types"
 	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/stretchr/testify/require"
+	"math/big"
 	"testing"
 	"time"
 )
@@ -47,6 +52,46 @@ func TestPairCreated_Aerodrome(t *testing.T) {
 	require.True(t, pairWrap.Pair.Equal(expectPair), "expect: %v, actual: %v", expectPair, pairWrap.Pair)
 }
 
+func TestPairCreated_AerodromeStable(t *testing.T) {
+	token0 := common.HexToAddress("0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913")
+	token1 := common.HexToAddress("0xfde4C96c8593536E31F229EA8f37b2ADa2699bb2")
+	pool := common.HexToAddress("0x27a8Afa3Bd49406e48a074350fB7b2020c43B2bD")
+	data, err := aerodrome.PoolCreatedEvent.Inputs.NonIndexed().Pack(pool, big.NewInt(1))
+	require.NoError(t, err)
+	poolCreatedLog := func(stable bool) *ethtypes.Log {
+		stableTopic := common.Hash{}
+		if stable {
+			stableTopic = common.BigToHash(common.Big1)
+		}
+		return &ethtypes.Log{
+			Address:     aerodrome.FactoryAddress,
+			Topics:      []common.Hash{aerodrome.PoolCreatedTopic0, common.BytesToHash(token0.Bytes()), common.BytesToHash(token1.Bytes()), stableTopic},
+			Data:        data,
+			BlockNumber: 100,
+		}
+	}
+
+	event, pErr := Topic2EventParser[aerodrome.PoolCreatedTopic0].Parse(poolCreatedLog(true))
+	require.NoError(t, pErr)
+	require.True(t, event.CanGetPair())
+	pair := event.GetPair()
+	require.Equal(t, pool, pair.Address)
+	require.Equal(t, token0, pair.Token0Core.Address)
+	require.Equal(t, token1, pair.Token1Core.Address)
+	require.Equal(t, types.ProtocolIdAerodrome, pair.ProtocolId)
+	require.True(t, pair.Stable)
+	require.True(t, pair.GetOrmPair().Stable, "persisted on the pair row")
+
+	event, pErr = Topic2EventParser[aerodrome.PoolCreatedTopic0].Parse(poolCreatedLog(false))
+	require.NoError(t, pErr)
+	require.False(t, event.GetPair().Stable)
+
+	wrongFactory := poolCreatedLog(true)
+	wrongFactory.Address = common.HexToAddress("0x01")
+	_, pErr = Topic2EventParser[aerodrome.PoolCreatedTopic0].Parse(wrongFactory)
+	require.ErrorIs(t, pErr, ErrWrongFactoryAddress)
+}
+
 func TestPairCreated_UniswapV2(t *testing.T) {
 	// https://basescan.org/tx/0x2a925551ba86be62e96480791f1b152a6c9f542315c2c45643238e77be990b97#eventlog#377
 	tc := service.GetTestContext()
@@ -122,3 +167,38 @@ func TestPairCreated_PancakeV2(t *testing.T) {
 
 	require.True(t, pairWrap.Pair.Equal(expectPair), "expect: %v, actual: %v", expectPair, pairWrap.Pair)
 }
+
+func TestPairCreated_PancakeV2Factory(t *testing.T) {
+	token0 := common.HexToAddress("0x357f9404356970F6B1C7208cf966abE3e505BA60")
+	token1 := common.HexToAddress("0x4200000000000000000000000000000000000006")
+	pairAddress := common.HexToAddress("0xabd5Ba8F0945d89E48077947037455bD40f475FC")
+	data, err := pancakev2.PairCreatedEvent.Inputs.NonIndexed().Pack(pairAddress, big.NewInt(1))
+	require.NoError(t, err)
+	pairCreatedLog := func(factory common.Address) *ethtypes.Log {
+		return &ethtypes.Log{
+			Address:     factory,
+			Topics:      []common.Hash{pancakev2.PairCreatedTopic0, common.BytesToHash(token0.Bytes()), common.BytesToHash(token1.Bytes())},
+			Data:        data,
+			BlockNumber: 100,
+		}
+	}
+
+	// the topic is shared with uniswap v2, the factory tells the protocol
+	require.Equal(t, uniswapv2.PairCreatedTopic0, pancakev2.PairCreatedTopic0)
+	event, pErr := Topic2EventParser[pancakev2.PairCreatedTopic0].Parse(pairCreatedLog(pancakev2.FactoryAddress))
+	require.NoError(t, pErr)
+	require.True(t, event.CanGetPair())
+	pair := event.GetPair()
+	require.Equal(t, pairAddress, pair.Address)
+	require.Equal(t, token0, pair.Token0Core.Address)
+	require.Equal(t, token1, pair.Token1Core.Address)
+	require.Equal(t, types.ProtocolIdPancakeV2, pair.ProtocolId)
+	require.Equal(t, uint64(100), pair.Block)
+
+	event, pErr = Topic2EventParser[pancakev2.PairCreatedTopic0].Parse(pairCreatedLog(uniswapv2.FactoryAddress))
+	require.NoError(t, pErr)
+	require.Equal(t, types.ProtocolIdNewSwap, event.GetPair().ProtocolId)
+
+	_, pErr = Topic2EventParser[pancakev2.PairCreatedTopic0].Parse(pairCreatedLog(common.HexToAddress("0x01")))
+	require.ErrorIs(t, pErr, ErrWrongFactoryAddress)
+}
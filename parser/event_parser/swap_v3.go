@@ -24,9 +24,18 @@ func (o *SwapEventParserV3) Parse(ethLog *ethtypes.Log) (types.Event, error) {
 	}
 
 	e := &event.SwapEventV3{
-		EventCommon: types.EventCommonFromEthLog(ethLog),
-		Amount0Wei:  input[0].(*big.Int),
-		Amount1Wei:  input[1].(*big.Int),
+		EventCommon:  types.EventCommonFromEthLog(ethLog),
+		Amount0Wei:   input[0].(*big.Int),
+		Amount1Wei:   input[1].(*big.Int),
+		SqrtPriceX96: input[2].(*big.Int),
+		Liquidity:    input[3].(*big.Int),
+		Tick:         input[4].(*big.Int),
+	}
+
+	// pancake v3 appends the protocol fees of the pool
+	if len(input) >= 7 {
+		e.ProtocolFees0Wei = input[5].(*big.Int)
+		e.ProtocolFees1Wei = input[6].(*big.Int)
 	}
 
 	if e.Amount0Wei.Sign() == 0 {
@@ -1,9 +1,13 @@
 package event_parser
 
 import (
+	pancakev3 "abchain_scan/abi/pancake/v3"
+	uniswapv3 "abchain_scan/abi/uniswap/v3"
 	"abchain_scan/repository/orm"
 	"abchain_scan/service"
 	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 	"math/big"
@@ -95,3 +99,50 @@ func TestSwap_PancakeV3(t *testing.T) {
 	}
 	require.True(t, tx.Equal(expectTx), "expect: %v, actual: %v", expectTx, tx)
 }
+
+func TestSwap_PancakeV3ProtocolFees(t *testing.T) {
+	poolAddress := common.HexToAddress("0x5845A51630AFab7C68556CF57a7b6827Bd94d434")
+	sqrtPriceX96 := new(big.Int).Lsh(big.NewInt(1), 96)
+	// 2 weth in for 5 tokens out, the protocol fee fields follow the tick
+	data, err := pancakev3.SwapEvent.Inputs.NonIndexed().Pack(
+		new(big.Int).Mul(big.NewInt(2), big.NewInt(1e18)),
+		new(big.Int).Mul(big.NewInt(-5), big.NewInt(1e18)),
+		sqrtPriceX96, big.NewInt(1000), big.NewInt(-10), big.NewInt(7), big.NewInt(8))
+	require.NoError(t, err)
+	ethLog := &ethtypes.Log{
+		Address:     poolAddress,
+		Topics:      []common.Hash{pancakev3.SwapTopic0, common.BytesToHash(common.HexToAddress("0x02").Bytes()), common.BytesToHash(common.HexToAddress("0x03").Bytes())},
+		Data:        data,
+		BlockNumber: 100,
+		Index:       4,
+	}
+
+	require.NotEqual(t, uniswapv3.SwapTopic0, pancakev3.SwapTopic0)
+	event, pErr := Topic2EventParser[ethLog.Topics[0]].Parse(ethLog)
+	require.NoError(t, pErr)
+	require.Equal(t, []int{types.ProtocolIdPancakeV3}, event.GetPossibleProtocolIds())
+
+	token := common.HexToAddress("0x858A6594f86fafb10dC0dEdC588C7CBb8E795129")
+	event.SetPair(&types.Pair{
+		Address:        poolAddress,
+		TokensReversed: true,
+		Token0Core:     &types.TokenCore{Address: token, Symbol: "JESUS", Decimals: 18},
+		Token1Core:     &types.TokenCore{Address: types.WETHAddress, Symbol: "WETH", Decimals: 18},
+		ProtocolId:     types.ProtocolIdPancakeV3,
+	})
+
+	tx := event.GetTx(service.MockNativeTokenPrice)
+	require.Equal(t, types.Buy, tx.Event)
+	require.True(t, tx.Token0Amount.Equal(decimal.NewFromInt(5)), tx.Token0Amount.String())
+	require.True(t, tx.Token1Amount.Equal(decimal.NewFromInt(2)), tx.Token1Amount.String())
+	require.Equal(t, types.ProtocolNamePancakeV3, tx.Program)
+
+	require.True(t, event.CanGetPoolUpdateV3())
+	poolUpdate := event.GetPoolUpdateV3()
+	require.True(t, poolUpdate.SqrtPriceX96.Equal(decimal.NewFromBigInt(sqrtPriceX96, 0)), poolUpdate.SqrtPriceX96.String())
+	require.True(t, poolUpdate.Liquidity.Equal(decimal.NewFromInt(1000)), poolUpdate.Liquidity.String())
+	require.Equal(t, int64(-10), poolUpdate.Tick)
+	// in the pair's order, the pool's token0 is weth
+	require.True(t, poolUpdate.ProtocolFees0.Equal(decimal.New(8, -18)), poolUpdate.ProtocolFees0.String())
+	require.True(t, poolUpdate.ProtocolFees1.Equal(decimal.New(7, -18)), poolUpdate.ProtocolFees1.String())
+}
@@ -0,0 +1,55 @@
+package event_parser
+
+import (
+	"abchain_scan/parser/event_parser/event"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+type SwapEventParserV4 struct {
+	PoolEventParser
+	PoolManagerAddresses map[common.Address]struct{}
+}
+
+/*
+Parse
+v4 amounts are the balance deltas of the swapper, negated to the pool side so they read like v3
+*/
+func (o *SwapEventParserV4) Parse(ethLog *ethtypes.Log) (types.Event, error) {
+	if _, ok := o.PoolManagerAddresses[ethLog.Address]; !ok {
+		return nil, ErrWrongPoolManagerAddress
+	}
+
+	input, err := o.ethLogUnpacker.Unpack(ethLog)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &event.SwapEventV3{
+		EventCommon:  types.EventCommonFromEthLog(ethLog),
+		Amount0Wei:   new(big.Int).Neg(input[0].(*big.Int)),
+		Amount1Wei:   new(big.Int).Neg(input[1].(*big.Int)),
+		SqrtPriceX96: input[2].(*big.Int),
+		Liquidity:    input[3].(*big.Int),
+		Tick:         input[4].(*big.Int),
+	}
+
+	if e.Amount0Wei.Sign() == 0 {
+		return nil, errAmount0Zero
+	}
+
+	if e.Amount1Wei.Sign() == 0 {
+		return nil, errAmount1Zero
+	}
+
+	e.Pair = &types.Pair{
+		Address: types.V4PairAddress(ethLog.Topics[1]),
+		PoolId:  ethLog.Topics[1],
+	}
+
+	e.PossibleProtocolIds = o.PossibleProtocolIds
+
+	return e, nil
+}
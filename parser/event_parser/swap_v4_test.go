@@ -0,0 +1,40 @@
+package event_parser
+
+import (
+	uniswapv4 "abchain_scan/abi/uniswap/v4"
+	"abchain_scan/parser/event_parser/event"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestSwap_UniswapV4(t *testing.T) {
+	poolId := common.HexToHash("0x96d4b53a38337a5733179751781178a2613306063c511b78cd02684739288c0a")
+	// swapper pays 1 ETH and receives 2500 USDC
+	data, err := uniswapv4.SwapEvent.Inputs.NonIndexed().Pack(
+		big.NewInt(-1e18), big.NewInt(2500e6), big.NewInt(1), big.NewInt(1), big.NewInt(0), big.NewInt(500))
+	require.NoError(t, err)
+
+	ethLog := &ethtypes.Log{
+		Address: uniswapv4.PoolManagerAddress,
+		Topics:  []common.Hash{uniswapv4.SwapTopic0, poolId, common.BytesToHash(common.FromHex("0x01"))},
+		Data:    data,
+	}
+
+	e, pErr := Topic2EventParser[ethLog.Topics[0]].Parse(ethLog)
+	require.NoError(t, pErr)
+	require.Equal(t, poolId, e.GetPoolId())
+	require.Equal(t, types.V4PairAddress(poolId), e.GetPairAddress())
+	require.Equal(t, []int{types.ProtocolIdUniswapV4}, e.GetPossibleProtocolIds())
+
+	swap := e.(*event.SwapEventV3)
+	require.Equal(t, big.NewInt(1e18), swap.Amount0Wei)
+	require.Equal(t, big.NewInt(-2500e6), swap.Amount1Wei)
+
+	ethLog.Address = common.HexToAddress("0x01")
+	_, pErr = Topic2EventParser[ethLog.Topics[0]].Parse(ethLog)
+	require.ErrorIs(t, pErr, ErrWrongPoolManagerAddress)
+}
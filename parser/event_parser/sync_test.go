@@ -1,9 +1,11 @@
 package event_parser
 
 import (
+	"abchain_scan/abi/aerodrome"
 	"abchain_scan/service"
 	"abchain_scan/types"
 	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 	"math/big"
@@ -48,6 +50,35 @@ func TestSync_Aerodrome(t *testing.T) {
 	require.True(t, expectPoolUpdate.Equal(poolUpdate), "expect: %v, actual: %v", expectPoolUpdate, poolUpdate)
 }
 
+func TestSync_AerodromeStable(t *testing.T) {
+	pairAddress := common.HexToAddress("0x27a8Afa3Bd49406e48a074350fB7b2020c43B2bD")
+	data, err := aerodrome.SyncEvent.Inputs.NonIndexed().Pack(big.NewInt(1000e6), new(big.Int).Mul(big.NewInt(1100), big.NewInt(1e18)))
+	require.NoError(t, err)
+	ethLog := &ethtypes.Log{Address: pairAddress, Topics: []common.Hash{aerodrome.SyncTopic0}, Data: data, Index: 7}
+
+	event, pErr := Topic2EventParser[ethLog.Topics[0]].Parse(ethLog)
+	require.NoError(t, pErr)
+	require.Contains(t, event.GetPossibleProtocolIds(), types.ProtocolIdAerodrome)
+	event.SetPair(&types.Pair{
+		Address:    pairAddress,
+		Token0Core: &types.TokenCore{Address: types.USDCAddress, Symbol: "USDC", Decimals: 6},
+		Token1Core: &types.TokenCore{Address: common.HexToAddress("0xfde4C96c8593536E31F229EA8f37b2ADa2699bb2"), Symbol: "USDT", Decimals: 18},
+		ProtocolId: types.ProtocolIdAerodrome,
+		Stable:     true,
+	})
+
+	require.True(t, event.CanGetPoolUpdate())
+	poolUpdate := event.GetPoolUpdate()
+	require.True(t, poolUpdate.Stable)
+	require.True(t, poolUpdate.Token0Amount.Equal(decimal.NewFromInt(1000)), poolUpdate.Token0Amount.String())
+	require.True(t, poolUpdate.Token1Amount.Equal(decimal.NewFromInt(1100)), poolUpdate.Token1Amount.String())
+
+	// priced on the curve near the peg, not at the reserve ratio
+	price := poolUpdate.GetOrmPoolState(100).Price
+	require.True(t, price.GreaterThan(decimal.NewFromInt(1)), price.String())
+	require.True(t, price.LessThan(decimal.NewFromFloat(1.001)), price.String())
+}
+
 func TestSync_UniswapV2(t *testing.T) {
 	// https://basescan.org/tx/0x2a925551ba86be62e96480791f1b152a6c9f542315c2c45643238e77be990b97#eventlog#385
 	txHash := "0x2a925551ba86be62e96480791f1b152a6c9f542315c2c45643238e77be990b97"
@@ -0,0 +1,37 @@
+package event_parser
+
+import (
+	"abchain_scan/abi/bep20"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+/*
+TransferEventParser
+erc20 Transfer, not a pool event so it is kept out of Topic2EventParser,
+erc721 shares the topic but indexes the token id and fails on the topic length
+*/
+type TransferEventParser struct {
+	ethLogUnpacker EthLogUnpacker
+}
+
+var TransferParser = &TransferEventParser{
+	ethLogUnpacker: newEthLogUnpacker(bep20.TransferEvent),
+}
+
+func (o *TransferEventParser) Parse(ethLog *ethtypes.Log) (*types.Transfer, error) {
+	input, err := o.ethLogUnpacker.Unpack(ethLog)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Transfer{
+		Token:    ethLog.Address,
+		From:     common.BytesToAddress(ethLog.Topics[1].Bytes()),
+		To:       common.BytesToAddress(ethLog.Topics[2].Bytes()),
+		ValueWei: input[0].(*big.Int),
+		LogIndex: ethLog.Index,
+	}, nil
+}
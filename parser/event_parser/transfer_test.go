@@ -0,0 +1,39 @@
+package event_parser
+
+import (
+	"abchain_scan/abi/bep20"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestTransfer(t *testing.T) {
+	token := common.HexToAddress("0x940181a94A35A4569E4529A3CDfB74e38FD98631")
+	from := common.HexToAddress("0x01")
+	to := common.HexToAddress("0x02")
+	data, err := bep20.TransferEvent.Inputs.NonIndexed().Pack(big.NewInt(1e18))
+	require.NoError(t, err)
+
+	ethLog := &ethtypes.Log{
+		Address: token,
+		Topics:  []common.Hash{bep20.TransferTopic0, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:    data,
+		Index:   3,
+	}
+
+	transfer, pErr := TransferParser.Parse(ethLog)
+	require.NoError(t, pErr)
+	require.Equal(t, token, transfer.Token)
+	require.Equal(t, from, transfer.From)
+	require.Equal(t, to, transfer.To)
+	require.Equal(t, big.NewInt(1e18), transfer.ValueWei)
+	require.Equal(t, uint(3), transfer.LogIndex)
+
+	// erc721 Transfer indexes the token id
+	ethLog.Topics = append(ethLog.Topics, common.BigToHash(big.NewInt(1)))
+	ethLog.Data = nil
+	_, pErr = TransferParser.Parse(ethLog)
+	require.ErrorIs(t, pErr, ErrWrongTopicLen)
+}
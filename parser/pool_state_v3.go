@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"math/big"
+)
+
+// poolStateEventV3 the swap and initialize events of v3 pools
+type poolStateEventV3 interface {
+	PoolStateV3() (sqrtPriceX96, liquidity, tick *big.Int)
+}
+
+// liquidityEventV3 the mint and burn events, of v3 pools when ok
+type liquidityEventV3 interface {
+	LiquidityChangeV3() (tickLower, tickUpper int64, delta *big.Int, ok bool)
+	SetPoolStateV3(sqrtPriceX96, liquidity, tick *big.Int)
+}
+
+type poolStateV3 struct {
+	sqrtPriceX96 *big.Int
+	liquidity    *big.Int
+	tick         *big.Int
+}
+
+/*
+applyLiquidityChangesV3
+a mint or burn with the tick of the pool in [tickLower, tickUpper) moves its active liquidity, the event then
+carries the state of the pool from its last swap or initialize in the block with the liquidity moved,
+a pool without one before the event is read from the chain at the end of the block instead,
+that state already holds the later mints and burns of the block, only a later swap replaces it
+*/
+func (p *blockParser) applyLiquidityChangesV3(blockNumber *big.Int, results []*TxResultAndPairWrap) {
+	states := make(map[common.Address]*poolStateV3)
+	readAtEnd := make(map[common.Address]bool)
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+
+		for _, event := range result.Events {
+			pool := event.GetPairAddress()
+			if e, ok := event.(poolStateEventV3); ok {
+				sqrtPriceX96, liquidity, tick := e.PoolStateV3()
+				states[pool] = &poolStateV3{sqrtPriceX96: sqrtPriceX96, liquidity: liquidity, tick: tick}
+				continue
+			}
+
+			e, ok := event.(liquidityEventV3)
+			if !ok {
+				continue
+			}
+			tickLower, tickUpper, delta, ok := e.LiquidityChangeV3()
+			if !ok || delta.Sign() == 0 {
+				continue
+			}
+
+			state, ok := states[pool]
+			if !ok {
+				if readAtEnd[pool] {
+					continue
+				}
+				readAtEnd[pool] = true
+				sqrtPriceX96, liquidity, tick, err := p.priceService.GetPoolStateV3(pool, blockNumber)
+				if err != nil {
+					log.Logger.Error("get v3 pool state err", zap.String("pool", pool.String()), zap.Uint64("blockNumber", blockNumber.Uint64()), zap.Error(err))
+					metrics.PoolStateV3ReadErrTotal.Inc()
+					continue
+				}
+				e.SetPoolStateV3(sqrtPriceX96, liquidity, tick)
+				continue
+			}
+
+			if tick := state.tick.Int64(); tick < tickLower || tick >= tickUpper {
+				continue
+			}
+			state.liquidity = new(big.Int).Add(state.liquidity, delta)
+			e.SetPoolStateV3(state.sqrtPriceX96, state.liquidity, state.tick)
+		}
+	}
+}
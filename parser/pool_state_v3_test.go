@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"abchain_scan/parser/event_parser/event"
+	"abchain_scan/service"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+type poolStatePriceService struct {
+	service.PriceService
+	reads int
+}
+
+func (s *poolStatePriceService) GetPoolStateV3(common.Address, *big.Int) (*big.Int, *big.Int, *big.Int, error) {
+	s.reads++
+	return new(big.Int).Lsh(big.NewInt(1), 96), big.NewInt(700), big.NewInt(0), nil
+}
+
+func v3TestPair() *types.Pair {
+	return &types.Pair{
+		Address:    common.HexToAddress("0x01"),
+		Token0Core: &types.TokenCore{Address: common.HexToAddress("0xa0"), Decimals: 18},
+		Token1Core: &types.TokenCore{Address: common.HexToAddress("0xa1"), Decimals: 18},
+		ProtocolId: types.ProtocolIdUniswapV3,
+	}
+}
+
+func v3TestMint(pair *types.Pair, tickLower, tickUpper, liquidity int64) *event.MintEvent {
+	e := &event.MintEvent{
+		EventCommon:  &types.EventCommon{},
+		Amount0Wei:   big.NewInt(1),
+		Amount1Wei:   big.NewInt(1),
+		TickLower:    tickLower,
+		TickUpper:    tickUpper,
+		LiquidityWei: big.NewInt(liquidity),
+	}
+	e.SetPair(pair)
+	return e
+}
+
+func TestApplyLiquidityChangesV3_AfterSwap(t *testing.T) {
+	pair := v3TestPair()
+	swap := &event.SwapEventV3{
+		EventCommon:  &types.EventCommon{},
+		SqrtPriceX96: new(big.Int).Lsh(big.NewInt(1), 96),
+		Liquidity:    big.NewInt(1000),
+		Tick:         big.NewInt(10),
+	}
+	swap.SetPair(pair)
+	inRange := v3TestMint(pair, 0, 20, 500)
+	outOfRange := v3TestMint(pair, 20, 40, 500)
+	burn := &event.BurnEvent{
+		EventCommon:  &types.EventCommon{},
+		Amount0Wei:   big.NewInt(1),
+		Amount1Wei:   big.NewInt(1),
+		TickLower:    -10,
+		TickUpper:    11,
+		LiquidityWei: big.NewInt(200),
+	}
+	burn.SetPair(pair)
+
+	ps := &poolStatePriceService{}
+	p := &blockParser{priceService: ps}
+	p.applyLiquidityChangesV3(big.NewInt(1), []*TxResultAndPairWrap{
+		{Events: []types.Event{swap, inRange}},
+		nil,
+		{Events: []types.Event{outOfRange, burn}},
+	})
+
+	require.Zero(t, ps.reads)
+	require.True(t, inRange.CanGetPoolUpdateV3())
+	require.Equal(t, "1500", inRange.GetPoolUpdateV3().Liquidity.String())
+	require.Equal(t, int64(10), inRange.GetPoolUpdateV3().Tick)
+	require.False(t, outOfRange.CanGetPoolUpdateV3())
+	require.True(t, burn.CanGetPoolUpdateV3())
+	require.Equal(t, "1300", burn.GetPoolUpdateV3().Liquidity.String())
+}
+
+func TestApplyLiquidityChangesV3_ReadsTheStateOnce(t *testing.T) {
+	pair := v3TestPair()
+	first := v3TestMint(pair, -10, 10, 500)
+	second := v3TestMint(pair, -10, 10, 200)
+	v2 := &event.MintEvent{EventCommon: &types.EventCommon{}, Amount0Wei: big.NewInt(1), Amount1Wei: big.NewInt(1)}
+	v2.SetPair(&types.Pair{Address: common.HexToAddress("0x02")})
+
+	ps := &poolStatePriceService{}
+	p := &blockParser{priceService: ps}
+	p.applyLiquidityChangesV3(big.NewInt(1), []*TxResultAndPairWrap{{Events: []types.Event{first, v2, second}}})
+
+	// the state read at the end of the block already holds the second mint
+	require.Equal(t, 1, ps.reads)
+	require.True(t, first.CanGetPoolUpdateV3())
+	require.Equal(t, "700", first.GetPoolUpdateV3().Liquidity.String())
+	require.False(t, second.CanGetPoolUpdateV3())
+	require.False(t, v2.CanGetPoolUpdateV3())
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"abchain_scan/abi/bep20"
 	"abchain_scan/log"
 	"abchain_scan/parser/event_parser"
 	"abchain_scan/types"
@@ -16,15 +17,20 @@ var (
 
 type TopicRouter interface {
 	Parse(ethLog *ethtypes.Log) (types.Event, error)
+	ParseTransfer(ethLog *ethtypes.Log) (*types.Transfer, error)
+	Topics() []common.Hash
 }
 
 type topicRouter struct {
 	topic2EventParser map[common.Hash]event_parser.EventParser
+	indexTransfers    bool
 }
 
-func NewTopicRouter() TopicRouter {
+// NewTopicRouter with indexTransfers the erc20 Transfer topic is fetched and routed too
+func NewTopicRouter(indexTransfers bool) TopicRouter {
 	return &topicRouter{
 		topic2EventParser: event_parser.Topic2EventParser,
+		indexTransfers:    indexTransfers,
 	}
 }
 
@@ -37,3 +43,22 @@ func (p *topicRouter) Parse(ethLog *ethtypes.Log) (types.Event, error) {
 
 	return eventParser.Parse(ethLog)
 }
+
+func (p *topicRouter) ParseTransfer(ethLog *ethtypes.Log) (*types.Transfer, error) {
+	if !p.indexTransfers || ethLog.Topics[0] != bep20.TransferTopic0 {
+		return nil, ErrParserNotFound
+	}
+
+	return event_parser.TransferParser.Parse(ethLog)
+}
+
+func (p *topicRouter) Topics() []common.Hash {
+	topics := make([]common.Hash, 0, len(p.topic2EventParser)+1)
+	for topic := range p.topic2EventParser {
+		topics = append(topics, topic)
+	}
+	if p.indexTransfers {
+		topics = append(topics, bep20.TransferTopic0)
+	}
+	return topics
+}
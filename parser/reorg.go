@@ -0,0 +1,369 @@
+package parser
+
+import (
+	"abchain_scan/cache"
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"abchain_scan/repository/orm"
+	"abchain_scan/types"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// poolStateUndo the state of a pool before a block updated it, nil when the pool had none
+type poolStateUndo struct {
+	address  string
+	previous *orm.PoolState
+}
+
+type committedBlock struct {
+	pairs           []string
+	tokens          []string
+	balanceDeltas   []*types.BalanceDelta
+	mainPairChanges []*types.MainPairChange
+	poolStates      []*poolStateUndo
+}
+
+type poolStateUndoJson struct {
+	Address  string         `json:"address"`
+	Previous *orm.PoolState `json:"previous"`
+}
+
+type committedBlockJson struct {
+	Pairs           []string                `json:"pairs"`
+	Tokens          []string                `json:"tokens"`
+	BalanceDeltas   []*types.BalanceDelta   `json:"balance_deltas"`
+	MainPairChanges []*types.MainPairChange `json:"main_pair_changes"`
+	PoolStates      []*poolStateUndoJson    `json:"pool_states"`
+}
+
+func (b *committedBlock) MarshalBinary() ([]byte, error) {
+	aux := &committedBlockJson{
+		Pairs:           b.pairs,
+		Tokens:          b.tokens,
+		BalanceDeltas:   b.balanceDeltas,
+		MainPairChanges: b.mainPairChanges,
+		PoolStates:      make([]*poolStateUndoJson, 0, len(b.poolStates)),
+	}
+	for _, undo := range b.poolStates {
+		aux.PoolStates = append(aux.PoolStates, &poolStateUndoJson{Address: undo.address, Previous: undo.previous})
+	}
+	return json.Marshal(aux)
+}
+
+func (b *committedBlock) UnmarshalBinary(data []byte) error {
+	aux := &committedBlockJson{}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	b.pairs = aux.Pairs
+	b.tokens = aux.Tokens
+	b.balanceDeltas = aux.BalanceDeltas
+	b.mainPairChanges = aux.MainPairChanges
+	b.poolStates = make([]*poolStateUndo, 0, len(aux.PoolStates))
+	for _, undo := range aux.PoolStates {
+		b.poolStates = append(b.poolStates, &poolStateUndo{address: undo.Address, previous: undo.Previous})
+	}
+	return nil
+}
+
+func newCommittedBlock(blockInfo *types.BlockInfo) *committedBlock {
+	block := &committedBlock{
+		pairs:         make([]string, 0, len(blockInfo.NewPairs)),
+		tokens:        make([]string, 0, len(blockInfo.NewTokens)),
+		balanceDeltas: blockInfo.BalanceDeltas,
+	}
+	for _, pair := range blockInfo.NewPairs {
+		block.pairs = append(block.pairs, pair.Address)
+	}
+	for _, token := range blockInfo.NewTokens {
+		block.tokens = append(block.tokens, token.Address)
+	}
+	return block
+}
+
+/*
+commitState
+tracks the last committed sequence and the pairs/tokens first written by the recent
+blocks, so a reorg can wait for the orphaned blocks to be committed and then undo them,
+the undo data of each block is also written to the undo cache and read back from it
+for the orphaned blocks committed before a restart
+*/
+type commitState struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	startOnce sync.Once
+	sequence  uint64
+	depth     uint64
+	blocks    map[uint64]*committedBlock
+	undoCache cache.UndoCache
+}
+
+func newCommitState(depth uint64, undoCache cache.UndoCache) *commitState {
+	s := &commitState{
+		depth:     depth,
+		blocks:    make(map[uint64]*committedBlock, depth+1),
+		undoCache: undoCache,
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *commitState) record(height uint64, block *committedBlock) {
+	undo, err := block.MarshalBinary()
+	if err != nil {
+		log.Logger.Error("marshal block undo err", zap.Uint64("height", height), zap.Error(err))
+	} else {
+		s.undoCache.SetBlockUndo(height, undo)
+	}
+
+	s.mu.Lock()
+	s.blocks[height] = block
+	if height >= s.depth {
+		delete(s.blocks, height-s.depth)
+		s.undoCache.DelBlockUndo(height - s.depth)
+	}
+	s.mu.Unlock()
+}
+
+// start the blocks before the first one handed to the parser were committed before the restart
+func (s *commitState) start(sequence uint64) {
+	s.startOnce.Do(func() {
+		if sequence > 0 {
+			s.markCommitted(sequence - 1)
+		}
+	})
+}
+
+func (s *commitState) markCommitted(sequence uint64) {
+	s.mu.Lock()
+	if sequence > s.sequence {
+		s.sequence = sequence
+	}
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+func (s *commitState) waitCommitted(sequence uint64) {
+	s.mu.Lock()
+	for s.sequence < sequence {
+		s.cond.Wait()
+	}
+	s.mu.Unlock()
+}
+
+// load the undo data of a block committed before a restart
+func (s *commitState) load(height uint64) (*committedBlock, bool) {
+	undo, ok := s.undoCache.GetBlockUndo(height)
+	if !ok {
+		return nil, false
+	}
+	block := &committedBlock{}
+	if err := block.UnmarshalBinary(undo); err != nil {
+		log.Logger.Error("unmarshal block undo err", zap.Uint64("height", height), zap.Error(err))
+		return nil, false
+	}
+	return block, true
+}
+
+/*
+popFrom
+the blocks from height up to the last orphaned height merged in height order, the first undo of a pool
+or a token's main pair holds its value before the orphaned blocks, a block without undo data in memory
+or in the undo cache can't be undone and is logged
+*/
+func (s *commitState) popFrom(height, lastHeight uint64) *committedBlock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for h := range s.blocks {
+		if h > lastHeight {
+			lastHeight = h
+		}
+	}
+
+	merged := &committedBlock{}
+	for h := height; h <= lastHeight; h++ {
+		block, ok := s.blocks[h]
+		if !ok {
+			block, ok = s.load(h)
+		}
+		if !ok {
+			metrics.ReorgMissingUndoTotal.Inc()
+			log.Logger.Error("no undo data for an orphaned block, its pairs, tokens, holders, pool states and main pairs stay in the database",
+				zap.Uint64("height", h))
+			continue
+		}
+		merged.pairs = append(merged.pairs, block.pairs...)
+		merged.tokens = append(merged.tokens, block.tokens...)
+		merged.balanceDeltas = append(merged.balanceDeltas, block.balanceDeltas...)
+		merged.mainPairChanges = append(merged.mainPairChanges, block.mainPairChanges...)
+		merged.poolStates = append(merged.poolStates, block.poolStates...)
+		delete(s.blocks, h)
+		s.undoCache.DelBlockUndo(h)
+	}
+	return merged
+}
+
+// poolStateUndos reads the stored states of the pools about to be updated
+func (p *blockParser) poolStateUndos(poolStates []*orm.PoolState) ([]*poolStateUndo, error) {
+	addresses := make([]string, 0, len(poolStates))
+	for _, state := range poolStates {
+		addresses = append(addresses, state.Address)
+	}
+	previous, err := p.dbService.GetPoolStates(addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	undos := make([]*poolStateUndo, 0, len(poolStates))
+	for _, address := range addresses {
+		undos = append(undos, &poolStateUndo{address: address, previous: previous[address]})
+	}
+	return undos, nil
+}
+
+// restorePoolStates the state of each pool before its first orphaned update, or its removal when it had none
+func restorePoolStates(undos []*poolStateUndo) (states []*orm.PoolState, removed []string) {
+	seen := make(map[string]struct{}, len(undos))
+	for _, undo := range undos {
+		if _, ok := seen[undo.address]; ok {
+			continue
+		}
+		seen[undo.address] = struct{}{}
+		if undo.previous == nil {
+			removed = append(removed, undo.address)
+			continue
+		}
+		states = append(states, undo.previous)
+	}
+	return states, removed
+}
+
+// restoreMainPairs the changes putting back the main pair of each token before its first orphaned change
+func restoreMainPairs(mainPairChanges []*types.MainPairChange) []*types.MainPairChange {
+	token2Restored := make(map[string]*types.MainPairChange, len(mainPairChanges))
+	restored := make([]*types.MainPairChange, 0, len(mainPairChanges))
+	for _, change := range mainPairChanges {
+		if r, ok := token2Restored[change.Token]; ok {
+			r.Previous = change.MainPair
+			continue
+		}
+		r := &types.MainPairChange{
+			Token:    change.Token,
+			MainPair: change.Previous,
+			Previous: change.MainPair,
+		}
+		token2Restored[change.Token] = r
+		restored = append(restored, r)
+	}
+	return restored
+}
+
+/*
+rollback
+undoes everything committed above the common ancestor, the caller must make sure
+all orphaned blocks are committed and nothing of the canonical branch is in flight
+*/
+func (p *blockParser) rollback(reorg *types.Reorg) {
+	fromHeight := reorg.AncestorHeight + 1
+	lastHeight := fromHeight
+	for _, orphaned := range reorg.OrphanedBlocks {
+		lastHeight = max(lastHeight, orphaned.Height)
+	}
+	undo := p.commitState.popFrom(fromHeight, lastHeight)
+	pairs, tokens := undo.pairs, undo.tokens
+
+	err := p.dbService.DeleteTxsFromBlock(fromHeight)
+	if err != nil {
+		log.Logger.Fatal("delete txs err", zap.Uint64("from height", fromHeight), zap.Error(err))
+	}
+
+	if p.candles != nil {
+		err = p.candles.Rollback(fromHeight)
+		if err != nil {
+			log.Logger.Fatal("rollback candles err", zap.Uint64("from height", fromHeight), zap.Error(err))
+		}
+	}
+
+	p.priceService.Rollback(fromHeight)
+	if p.priceGraph != nil {
+		p.priceGraph.Rollback(fromHeight)
+	}
+
+	// the holders of the orphaned blocks go back to the ancestor, the canonical blocks are added again above it
+	balanceDeltas := types.MergeBalanceDeltas(undo.balanceDeltas)
+	holders := make([]*orm.TokenHolder, 0, len(balanceDeltas))
+	for _, delta := range balanceDeltas {
+		holders = append(holders, delta.GetOrmTokenHolder(reorg.AncestorHeight))
+	}
+	err = p.dbService.SubTokenHolders(holders)
+	if err != nil {
+		log.Logger.Fatal("sub token holders err", zap.Uint64("from height", fromHeight), zap.Error(err))
+	}
+
+	err = p.dbService.RestorePoolStates(restorePoolStates(undo.poolStates))
+	if err != nil {
+		log.Logger.Fatal("restore pool states err", zap.Uint64("from height", fromHeight), zap.Error(err))
+	}
+
+	restored := restoreMainPairs(undo.mainPairChanges)
+	err = p.dbService.UpdateMainPairs(restored)
+	if err != nil {
+		log.Logger.Fatal("restore main pairs err", zap.Uint64("from height", fromHeight), zap.Error(err))
+	}
+	if p.mainPairs != nil {
+		p.mainPairs.Reset()
+	}
+
+	err = p.dbService.DeletePairs(pairs)
+	if err != nil {
+		log.Logger.Fatal("delete pairs err", zap.Uint64("from height", fromHeight), zap.Error(err))
+	}
+
+	err = p.dbService.DeleteTokens(tokens)
+	if err != nil {
+		log.Logger.Fatal("delete tokens err", zap.Uint64("from height", fromHeight), zap.Error(err))
+	}
+
+	for _, pair := range pairs {
+		p.cache.DelPair(common.HexToAddress(pair))
+	}
+	for _, token := range tokens {
+		p.cache.DelToken(common.HexToAddress(token))
+	}
+
+	for i := len(reorg.OrphanedBlocks) - 1; i >= 0; i-- {
+		orphaned := reorg.OrphanedBlocks[i]
+		err = p.kafkaSender.SendRevert(&types.RevertInfo{
+			Revert: true,
+			Height: orphaned.Height,
+			Hash:   orphaned.Hash.String(),
+		})
+		if err != nil {
+			log.Logger.Fatal("kafka send revert msg err", zap.Error(err), zap.Any("block", orphaned.Height))
+		}
+	}
+
+	if len(restored) > 0 {
+		err = p.kafkaSender.SendMainPairChange(&types.MainPairChangeInfo{
+			MainPairChanged: true,
+			Height:          reorg.AncestorHeight,
+			Changes:         restored,
+		})
+		if err != nil {
+			log.Logger.Fatal("kafka send main pair change msg err", zap.Error(err), zap.Any("block", reorg.AncestorHeight))
+		}
+	}
+
+	p.cache.SetFinishedBlock(reorg.AncestorHeight)
+	metrics.CurrentHeight.Set(float64(reorg.AncestorHeight))
+
+	log.Logger.Warn("rollback finished",
+		zap.Uint64("ancestor", reorg.AncestorHeight),
+		zap.Int("depth", reorg.Depth()),
+		zap.Int("pairs", len(pairs)),
+		zap.Int("tokens", len(tokens)))
+}
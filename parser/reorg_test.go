@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"abchain_scan/cache"
+	"abchain_scan/repository/orm"
+	"abchain_scan/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCommitState_PopFromRestores(t *testing.T) {
+	s := newCommitState(10, cache.NewMockCache())
+	s.record(11, &committedBlock{
+		poolStates:      []*poolStateUndo{{address: "0x01", previous: &orm.PoolState{Address: "0x01", Block: 9}}, {address: "0x02"}},
+		mainPairChanges: []*types.MainPairChange{{Token: "0xa1", MainPair: "0x02", Previous: "0x01"}},
+	})
+	s.record(12, &committedBlock{
+		poolStates:      []*poolStateUndo{{address: "0x01", previous: &orm.PoolState{Address: "0x01", Block: 11}}},
+		mainPairChanges: []*types.MainPairChange{{Token: "0xa1", MainPair: "0x03", Previous: "0x02"}},
+	})
+	s.record(10, &committedBlock{pairs: []string{"0x10"}})
+
+	undo := s.popFrom(11, 12)
+	require.Empty(t, undo.pairs)
+
+	states, removed := restorePoolStates(undo.poolStates)
+	require.Len(t, states, 1)
+	require.Equal(t, uint64(9), states[0].Block)
+	require.Equal(t, []string{"0x02"}, removed)
+
+	restored := restoreMainPairs(undo.mainPairChanges)
+	require.Equal(t, []*types.MainPairChange{{Token: "0xa1", MainPair: "0x01", Previous: "0x03"}}, restored)
+
+	require.Len(t, s.blocks, 1)
+}
+
+func TestCommitState_PopFromAfterRestart(t *testing.T) {
+	undoCache := cache.NewMockCache()
+	s := newCommitState(10, undoCache)
+	s.record(11, &committedBlock{
+		pairs:         []string{"0x11"},
+		tokens:        []string{"0xa1"},
+		balanceDeltas: []*types.BalanceDelta{{Token: "0xa1", Holder: "0xb1", Delta: decimal.NewFromInt(5)}},
+		poolStates:    []*poolStateUndo{{address: "0x01", previous: &orm.PoolState{Address: "0x01", Reserve0: decimal.NewFromInt(7), Block: 9}}},
+	})
+	s.record(12, &committedBlock{pairs: []string{"0x12"}})
+
+	// the undo data of the blocks committed before the restart come from the undo cache
+	restarted := newCommitState(10, undoCache)
+	undo := restarted.popFrom(11, 13)
+	require.Equal(t, []string{"0x11", "0x12"}, undo.pairs)
+	require.Equal(t, []string{"0xa1"}, undo.tokens)
+	require.Len(t, undo.balanceDeltas, 1)
+	require.True(t, undo.balanceDeltas[0].Delta.Equal(decimal.NewFromInt(5)))
+	states, removed := restorePoolStates(undo.poolStates)
+	require.Empty(t, removed)
+	require.Len(t, states, 1)
+	require.True(t, states[0].Reserve0.Equal(decimal.NewFromInt(7)))
+
+	_, ok := undoCache.GetBlockUndo(11)
+	require.False(t, ok, "popped")
+}
+
+func TestCommitState_StartAfterRestart(t *testing.T) {
+	s := newCommitState(10, cache.NewMockCache())
+	s.start(100)
+	s.start(120)
+	require.Equal(t, uint64(99), s.sequence)
+
+	// a reorg on the first block after the restart doesn't wait for the blocks of the previous run
+	s.waitCommitted(99)
+}
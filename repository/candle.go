@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"abchain_scan/chain"
+	"abchain_scan/repository/orm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CandleRepository struct {
+	*BaseRepository[orm.Candle]
+}
+
+func NewCandleRepository(db *gorm.DB) *CandleRepository {
+	baseRepo := NewBaseRepository[orm.Candle](db)
+	return &CandleRepository{BaseRepository: baseRepo}
+}
+
+var candleColumns = []clause.Column{{Name: "pair"}, {Name: "chain_id"}, {Name: "interval"}, {Name: "open_at"}}
+
+// UpsertBatch a candle closed again after a restart or a reorg replaces the stored one
+func (r *CandleRepository) UpsertBatch(candles []*orm.Candle) error {
+	maxBatchSize := 200
+
+	onConflict := clause.OnConflict{
+		Columns: candleColumns,
+		DoUpdates: clause.AssignmentColumns([]string{
+			"open", "high", "low", "close", "volume_base", "volume_usd", "trades", "first_block", "last_block", "updated_at",
+		}),
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(candles); start += maxBatchSize {
+			end := start + maxBatchSize
+			if end > len(candles) {
+				end = len(candles)
+			}
+			if err := tx.Clauses(onConflict).Create(candles[start:end]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *CandleRepository) GetByPair(pair, interval string) ([]*orm.Candle, error) {
+	var candles []*orm.Candle
+	err := r.db.Where("pair = ? AND chain_id = ? AND interval = ?", pair, chain.Id, interval).Order("open_at").Find(&candles).Error
+	if err != nil {
+		return nil, err
+	}
+	return candles, nil
+}
+
+// DeleteFromBlock the candles of the chain with a swap of an orphaned block
+func (r *CandleRepository) DeleteFromBlock(block uint64) error {
+	return r.db.Where("last_block >= ? AND chain_id = ?", block, chain.Id).Delete(&orm.Candle{}).Error
+}
+
+func (r *CandleRepository) DeleteByPair(pair string) error {
+	return r.db.Where("pair = ? AND chain_id = ?", pair, chain.Id).Delete(&orm.Candle{}).Error
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"abchain_scan/chain"
+	"abchain_scan/repository/orm"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func prepareCandleTest() *CandleRepository {
+	dsn := "host=localhost user=postgres password=12345678 dbname=test port=5432 sslmode=disable"
+	db, err := gorm.Open(postgres.Open(dsn))
+	if err != nil {
+		panic(err)
+	}
+	return NewCandleRepository(db)
+}
+
+func TestCandleRepository_UpsertAndDelete(t *testing.T) {
+	candleRepository := prepareCandleTest()
+	pair := "0x01"
+	defer candleRepository.DeleteByPair(pair)
+
+	openAt := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	candleAt := func(openAt time.Time, close int64, lastBlock uint64) *orm.Candle {
+		price := decimal.NewFromInt(close)
+		return &orm.Candle{Pair: pair, ChainId: chain.Id, Interval: "1m", OpenAt: openAt,
+			Open: price, High: price, Low: price, Close: price, Trades: 1, FirstBlock: lastBlock, LastBlock: lastBlock}
+	}
+
+	require.Nil(t, candleRepository.UpsertBatch([]*orm.Candle{candleAt(openAt, 1, 10), candleAt(openAt.Add(time.Minute), 2, 11)}))
+	// closed again after a restart
+	require.Nil(t, candleRepository.UpsertBatch([]*orm.Candle{candleAt(openAt.Add(time.Minute), 3, 12)}))
+
+	candles, err := candleRepository.GetByPair(pair, "1m")
+	require.Nil(t, err)
+	require.Len(t, candles, 2)
+	require.True(t, decimal.NewFromInt(3).Equal(candles[1].Close))
+
+	// the same pair on another chain is out of the reorg
+	otherChain := candleAt(openAt.Add(time.Minute), 4, 12)
+	otherChain.ChainId = chain.Id + 1
+	require.Nil(t, candleRepository.UpsertBatch([]*orm.Candle{otherChain}))
+	defer candleRepository.db.Where("pair = ? AND chain_id = ?", pair, otherChain.ChainId).Delete(&orm.Candle{})
+
+	require.Nil(t, candleRepository.DeleteFromBlock(11))
+	candles, err = candleRepository.GetByPair(pair, "1m")
+	require.Nil(t, err)
+	require.Len(t, candles, 1)
+	require.Equal(t, uint64(10), candles[0].LastBlock)
+
+	var otherChainCandles int64
+	require.Nil(t, candleRepository.db.Model(&orm.Candle{}).Where("pair = ? AND chain_id = ?", pair, otherChain.ChainId).Count(&otherChainCandles).Error)
+	require.Equal(t, int64(1), otherChainCandles)
+}
@@ -0,0 +1,4 @@
+-- token_pair database, the files of a directory are applied in order
+
+-- solidly stable pools, priced on the x3y+y3x curve
+ALTER TABLE pair ADD COLUMN IF NOT EXISTS stable boolean NOT NULL DEFAULT false;
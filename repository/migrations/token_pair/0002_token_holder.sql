@@ -0,0 +1,12 @@
+-- balances of the token holders, TokenHolderRepository upserts on (token, holder, chain_id)
+CREATE TABLE IF NOT EXISTS token_holder (
+    token      varchar(42) NOT NULL,
+    holder     varchar(42) NOT NULL,
+    chain_id   bigint      NOT NULL,
+    balance    numeric     NOT NULL DEFAULT 0,
+    block      bigint      NOT NULL DEFAULT 0,
+    updated_at timestamptz,
+    CONSTRAINT token_holder_token_holder_chain_id_key UNIQUE (token, holder, chain_id)
+);
+
+ALTER TABLE token ADD COLUMN IF NOT EXISTS holders bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- the hash of the tx that deployed the token, empty when the creation was not found
+ALTER TABLE token ADD COLUMN IF NOT EXISTS create_tx varchar(66) NOT NULL DEFAULT '';
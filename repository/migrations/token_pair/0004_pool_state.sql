@@ -0,0 +1,22 @@
+-- the last state of each pool, PoolStateRepository upserts on (address, chain_id),
+-- address holds the 66 char pool id of uniswap v4 pools
+CREATE TABLE IF NOT EXISTS pool_state (
+    address        varchar(66) NOT NULL,
+    chain_id       bigint      NOT NULL,
+    program        varchar(32) NOT NULL DEFAULT '',
+    token0         varchar(42) NOT NULL DEFAULT '',
+    token1         varchar(42) NOT NULL DEFAULT '',
+    reserve0       numeric     NOT NULL DEFAULT 0,
+    reserve1       numeric     NOT NULL DEFAULT 0,
+    sqrt_price_x96 numeric     NOT NULL DEFAULT 0,
+    liquidity      numeric     NOT NULL DEFAULT 0,
+    tick           bigint      NOT NULL DEFAULT 0,
+    price          numeric     NOT NULL DEFAULT 0,
+    liquidity_usd  numeric     NOT NULL DEFAULT 0,
+    block          bigint      NOT NULL DEFAULT 0,
+    updated_at     timestamptz,
+    CONSTRAINT pool_state_address_chain_id_key UNIQUE (address, chain_id)
+);
+
+-- the main pair of a token is chosen among the pools it is token0 of
+CREATE INDEX IF NOT EXISTS pool_state_token0_chain_id_idx ON pool_state (token0, chain_id);
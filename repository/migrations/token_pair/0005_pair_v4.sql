@@ -0,0 +1,3 @@
+-- uniswap v4 pools are stored under their 66 char pool id instead of a 42 char address
+ALTER TABLE pair ALTER COLUMN address TYPE varchar(66);
+ALTER TABLE pair ADD COLUMN IF NOT EXISTS hooks varchar(42) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- TokenRepository.UpdateHolders counts the rows with balance > 0 of every token touched by a block
+CREATE INDEX IF NOT EXISTS token_holder_token_chain_id_balance_idx ON token_holder (token, chain_id, balance);
@@ -0,0 +1,23 @@
+-- tx database, the files of a directory are applied in order
+
+-- closed usd candles of the pairs, CandleRepository upserts on (pair, chain_id, interval, open_at)
+CREATE TABLE IF NOT EXISTS candle (
+    pair        varchar(66) NOT NULL,
+    chain_id    bigint      NOT NULL,
+    "interval"  varchar(8)  NOT NULL,
+    open_at     timestamptz NOT NULL,
+    open        numeric     NOT NULL DEFAULT 0,
+    high        numeric     NOT NULL DEFAULT 0,
+    low         numeric     NOT NULL DEFAULT 0,
+    close       numeric     NOT NULL DEFAULT 0,
+    volume_base numeric     NOT NULL DEFAULT 0,
+    volume_usd  numeric     NOT NULL DEFAULT 0,
+    trades      bigint      NOT NULL DEFAULT 0,
+    first_block bigint      NOT NULL DEFAULT 0,
+    last_block  bigint      NOT NULL DEFAULT 0,
+    updated_at  timestamptz,
+    CONSTRAINT candle_pair_chain_id_interval_open_at_key UNIQUE (pair, chain_id, "interval", open_at)
+);
+
+-- a reorg drops the candles from the first orphaned block on
+CREATE INDEX IF NOT EXISTS candle_chain_id_last_block_idx ON candle (chain_id, last_block);
@@ -0,0 +1,2 @@
+-- the txs of uniswap v4 pools carry the 66 char pool id as pair address
+ALTER TABLE tx ALTER COLUMN pair_address TYPE varchar(66);
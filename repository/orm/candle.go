@@ -0,0 +1,34 @@
+package orm
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+/*
+Candle
+usd ohlcv of the swaps of a pair over an interval starting at OpenAt, the prices are the usd price of token0
+and VolumeBase the token0 amount, LastBlock is the last block with a swap in the candle so a reorg can drop it,
+Closed is only set in the kafka message, the table only keeps closed candles
+*/
+type Candle struct {
+	Pair       string
+	ChainId    int
+	Interval   string
+	OpenAt     time.Time
+	Open       decimal.Decimal
+	High       decimal.Decimal
+	Low        decimal.Decimal
+	Close      decimal.Decimal
+	VolumeBase decimal.Decimal
+	VolumeUsd  decimal.Decimal
+	Trades     int
+	FirstBlock uint64
+	LastBlock  uint64
+	Closed     bool      `gorm:"-"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+func (c *Candle) TableName() string {
+	return "candle"
+}
@@ -7,7 +7,7 @@ import (
 
 type Pair struct {
 	Name      string
-	Address   string
+	Address   string // the 66 char pool id for uniswap v4 pools
 	Token0    string
 	Token1    string
 	ChainId   int
@@ -16,6 +16,8 @@ type Pair struct {
 	Block     uint64
 	BlockAt   time.Time
 	Program   string
+	Stable    bool      // solidly stable pools, priced on the x³y+y³x curve
+	Hooks     string    // the hooks contract of uniswap v4 pools, empty otherwise
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 }
 
@@ -45,5 +47,11 @@ func (p *Pair) Equal(p2 *Pair) bool {
 	if !p.Reserve1.Equal(p2.Reserve1) {
 		return false
 	}
+	if p.Stable != p2.Stable {
+		return false
+	}
+	if p.Hooks != p2.Hooks {
+		return false
+	}
 	return true
 }
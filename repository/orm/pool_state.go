@@ -0,0 +1,34 @@
+package orm
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+/*
+PoolState
+the state of a pool at the end of Block, the last block with an update of it, Address is the pool id for uniswap v4,
+the reserves are in the order of the pair with the decimals applied, virtual reserves of the active liquidity for
+concentrated liquidity pools, Price is token1 per token0 of the pair while SqrtPriceX96 and Tick stay in the pool's
+own order, SqrtPriceX96, Liquidity and Tick are zero for v2 pools
+*/
+type PoolState struct {
+	Address      string
+	ChainId      int
+	Program      string
+	Token0       string
+	Token1       string
+	Reserve0     decimal.Decimal
+	Reserve1     decimal.Decimal
+	SqrtPriceX96 decimal.Decimal
+	Liquidity    decimal.Decimal
+	Tick         int64
+	Price        decimal.Decimal
+	LiquidityUsd decimal.Decimal
+	Block        uint64
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
+
+func (s *PoolState) TableName() string {
+	return "pool_state"
+}
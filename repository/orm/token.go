@@ -16,9 +16,11 @@ type Token struct {
 	ChainId     int
 	Block       uint64
 	BlockAt     time.Time
+	CreateTx    string
 	Program     string
 	CreatedAt   time.Time `gorm:"autoCreateTime"`
 	MainPair    string
+	Holders     int64 // holders with a positive balance, kept by TokenRepository.UpdateHolders
 }
 
 func (t *Token) TableName() string {
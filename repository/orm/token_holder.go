@@ -0,0 +1,25 @@
+package orm
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+/*
+TokenHolder
+balance of a holder, Block is the last block applied to the row so a block written twice is only counted once,
+the balance can go below zero for holders who got the token before it was discovered,
+it is kept signed so a rollback can take the deltas off again, the holder count skips those rows
+*/
+type TokenHolder struct {
+	Token     string
+	Holder    string
+	ChainId   int
+	Balance   decimal.Decimal
+	Block     uint64
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (h *TokenHolder) TableName() string {
+	return "token_holder"
+}
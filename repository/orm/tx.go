@@ -22,7 +22,7 @@ type Tx struct {
 	BlockAt       time.Time
 	BlockIndex    uint
 	TxIndex       uint
-	PairAddress   string
+	PairAddress   string // the 66 char pool id for uniswap v4 pools
 	Program       string
 	CreatedAt     time.Time `gorm:"autoCreateTime"`
 }
@@ -27,3 +27,10 @@ func (r *PairRepository) GetByAddressAndChainId(address string) (*orm.Pair, erro
 func (r *PairRepository) DeleteByAddressAndChainId(address string) error {
 	return r.db.Where("address = ? AND chain_id = ?", address, chain.Id).Delete(&orm.Pair{}).Error
 }
+
+func (r *PairRepository) DeleteByAddressesAndChainId(addresses []string) error {
+	if len(addresses) == 0 {
+		return nil
+	}
+	return r.db.Where("address IN ? AND chain_id = ?", addresses, chain.Id).Delete(&orm.Pair{}).Error
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"abchain_scan/chain"
+	"abchain_scan/repository/orm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PoolStateRepository struct {
+	*BaseRepository[orm.PoolState]
+}
+
+func NewPoolStateRepository(db *gorm.DB) *PoolStateRepository {
+	baseRepo := NewBaseRepository[orm.PoolState](db)
+	return &PoolStateRepository{BaseRepository: baseRepo}
+}
+
+var poolStateColumns = []clause.Column{{Name: "address"}, {Name: "chain_id"}}
+
+/*
+UpsertBatch
+replaces the stored state of each pool, a state of an older block than the stored one is skipped
+so the blocks committed again after a restart do not move a pool back
+*/
+func (r *PoolStateRepository) UpsertBatch(states []*orm.PoolState) error {
+	return r.upsertBatch(states, clause.OnConflict{
+		Columns: poolStateColumns,
+		DoUpdates: clause.AssignmentColumns([]string{
+			"reserve0", "reserve1", "sqrt_price_x96", "liquidity", "tick", "price", "liquidity_usd", "block", "updated_at",
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{gorm.Expr("pool_state.block <= excluded.block")}},
+	})
+}
+
+func (r *PoolStateRepository) upsertBatch(states []*orm.PoolState, onConflict clause.OnConflict) error {
+	maxBatchSize := 200
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(states); start += maxBatchSize {
+			end := start + maxBatchSize
+			if end > len(states) {
+				end = len(states)
+			}
+			if err := tx.Clauses(onConflict).Create(states[start:end]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *PoolStateRepository) GetByAddress(address string) (*orm.PoolState, error) {
+	var state orm.PoolState
+	err := r.db.Where("address = ? AND chain_id = ?", address, chain.Id).First(&state).Error
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *PoolStateRepository) GetByToken0(tokens []string) ([]*orm.PoolState, error) {
+	var states []*orm.PoolState
+	if len(tokens) == 0 {
+		return states, nil
+	}
+	err := r.db.Where("token0 IN ? AND chain_id = ?", tokens, chain.Id).Find(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func (r *PoolStateRepository) GetByAddresses(addresses []string) ([]*orm.PoolState, error) {
+	var states []*orm.PoolState
+	if len(addresses) == 0 {
+		return states, nil
+	}
+	err := r.db.Where("address IN ? AND chain_id = ?", addresses, chain.Id).Find(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// RestoreBatch puts back the states of the pools before the orphaned blocks, whatever the stored block
+func (r *PoolStateRepository) RestoreBatch(states []*orm.PoolState) error {
+	return r.upsertBatch(states, clause.OnConflict{
+		Columns:   poolStateColumns,
+		UpdateAll: true,
+	})
+}
+
+func (r *PoolStateRepository) DeleteByAddresses(addresses []string) error {
+	if len(addresses) == 0 {
+		return nil
+	}
+	return r.db.Where("address IN ? AND chain_id = ?", addresses, chain.Id).Delete(&orm.PoolState{}).Error
+}
+
+func (r *PoolStateRepository) DeleteByAddress(address string) error {
+	return r.db.Where("address = ? AND chain_id = ?", address, chain.Id).Delete(&orm.PoolState{}).Error
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"abchain_scan/chain"
+	"abchain_scan/repository/orm"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func preparePoolStateTest() *PoolStateRepository {
+	dsn := "host=localhost user=postgres password=12345678 dbname=test port=5432 sslmode=disable"
+	db, err := gorm.Open(postgres.Open(dsn))
+	if err != nil {
+		panic(err)
+	}
+	return NewPoolStateRepository(db)
+}
+
+func TestPoolStateRepository_UpsertAndDelete(t *testing.T) {
+	poolStateRepository := preparePoolStateTest()
+	address := "0x01"
+	defer poolStateRepository.DeleteByAddress(address)
+
+	stateAt := func(block uint64, reserve0 int64) []*orm.PoolState {
+		return []*orm.PoolState{{Address: address, ChainId: chain.Id, Token0: "0xa1", Reserve0: decimal.NewFromInt(reserve0), Reserve1: decimal.NewFromInt(1), Block: block}}
+	}
+
+	require.Nil(t, poolStateRepository.UpsertBatch(stateAt(10, 5)))
+	require.Nil(t, poolStateRepository.UpsertBatch(stateAt(11, 3)))
+	// block 10 written again after a restart
+	require.Nil(t, poolStateRepository.UpsertBatch(stateAt(10, 5)))
+
+	state, err := poolStateRepository.GetByAddress(address)
+	require.Nil(t, err)
+	require.True(t, decimal.NewFromInt(3).Equal(state.Reserve0))
+	require.Equal(t, uint64(11), state.Block)
+
+	states, err := poolStateRepository.GetByToken0([]string{"0xa1"})
+	require.Nil(t, err)
+	require.Len(t, states, 1)
+
+	// block 11 orphaned, the state of block 10 is put back
+	require.Nil(t, poolStateRepository.RestoreBatch(stateAt(10, 5)))
+	states, err = poolStateRepository.GetByAddresses([]string{address})
+	require.Nil(t, err)
+	require.Len(t, states, 1)
+	require.True(t, decimal.NewFromInt(5).Equal(states[0].Reserve0))
+	require.Equal(t, uint64(10), states[0].Block)
+
+	// a pool first updated by an orphaned block
+	require.Nil(t, poolStateRepository.DeleteByAddresses([]string{address}))
+	_, err = poolStateRepository.GetByAddress(address)
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -31,6 +31,61 @@ func (r *TokenRepository) UpdateMainPair(address string, mainPair string) error
 		Update("main_pair", mainPair).Error
 }
 
+// UpdateCreation sets the creator, creation block and creation tx found after the token was added
+func (r *TokenRepository) UpdateCreation(token *orm.Token) error {
+	return r.db.Model(&orm.Token{}).
+		Where("address = ? AND chain_id = ?", token.Address, chain.Id).
+		Updates(map[string]interface{}{
+			"creator":   token.Creator,
+			"block":     token.Block,
+			"block_at":  token.BlockAt,
+			"create_tx": token.CreateTx,
+		}).Error
+}
+
+// GetMainPairs the main pair of each token of the addresses found, empty when not chosen yet
+func (r *TokenRepository) GetMainPairs(addresses []string) (map[string]string, error) {
+	mainPairs := make(map[string]string, len(addresses))
+	if len(addresses) == 0 {
+		return mainPairs, nil
+	}
+
+	var tokens []*orm.Token
+	err := r.db.Select("address", "main_pair").
+		Where("address IN ? AND chain_id = ?", addresses, chain.Id).
+		Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		mainPairs[token.Address] = token.MainPair
+	}
+	return mainPairs, nil
+}
+
+/*
+UpdateHolders
+recounts the holders of the tokens from the token_holder table of the same database,
+balances only start at the token discovery so rows at or below zero are not counted,
+a holder who got the token before it was discovered is missing until it receives it again
+*/
+func (r *TokenRepository) UpdateHolders(addresses []string) error {
+	if len(addresses) == 0 {
+		return nil
+	}
+	return r.db.Model(&orm.Token{}).
+		Where("address IN ? AND chain_id = ?", addresses, chain.Id).
+		Update("holders", gorm.Expr(
+			"(SELECT count(*) FROM token_holder WHERE token_holder.token = token.address AND token_holder.chain_id = token.chain_id AND token_holder.balance > 0)")).Error
+}
+
 func (r *TokenRepository) DeleteByAddressAndChainId(address string) error {
 	return r.db.Where("address = ? AND chain_id = ?", address, chain.Id).Delete(&orm.Token{}).Error
 }
+
+func (r *TokenRepository) DeleteByAddressesAndChainId(addresses []string) error {
+	if len(addresses) == 0 {
+		return nil
+	}
+	return r.db.Where("address IN ? AND chain_id = ?", addresses, chain.Id).Delete(&orm.Token{}).Error
+}
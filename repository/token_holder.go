@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"abchain_scan/chain"
+	"abchain_scan/repository/orm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TokenHolderRepository struct {
+	*BaseRepository[orm.TokenHolder]
+}
+
+func NewTokenHolderRepository(db *gorm.DB) *TokenHolderRepository {
+	baseRepo := NewBaseRepository[orm.TokenHolder](db)
+	return &TokenHolderRepository{BaseRepository: baseRepo}
+}
+
+var tokenHolderColumns = []clause.Column{{Name: "token"}, {Name: "holder"}, {Name: "chain_id"}}
+
+func (r *TokenHolderRepository) upsertBatch(holders []*orm.TokenHolder, onConflict clause.OnConflict) error {
+	maxBatchSize := 200
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(holders); start += maxBatchSize {
+			end := start + maxBatchSize
+			if end > len(holders) {
+				end = len(holders)
+			}
+			if err := tx.Clauses(onConflict).Create(holders[start:end]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+var addBalance = clause.Assignments(map[string]interface{}{
+	"balance":    gorm.Expr("token_holder.balance + excluded.balance"),
+	"block":      gorm.Expr("excluded.block"),
+	"updated_at": gorm.Expr("excluded.updated_at"),
+})
+
+/*
+AddBalances
+adds the balance of each row to the stored one, rows whose block is not above the stored block are skipped,
+so a block committed again after a restart is not counted twice
+*/
+func (r *TokenHolderRepository) AddBalances(holders []*orm.TokenHolder) error {
+	return r.upsertBatch(holders, clause.OnConflict{
+		Columns:   tokenHolderColumns,
+		DoUpdates: addBalance,
+		Where:     clause.Where{Exprs: []clause.Expression{gorm.Expr("token_holder.block < excluded.block")}},
+	})
+}
+
+/*
+SubBalances
+takes the balance of each row off the stored one whatever the stored block, used by a rollback
+with the block set to the common ancestor so the canonical blocks are added again
+*/
+func (r *TokenHolderRepository) SubBalances(holders []*orm.TokenHolder) error {
+	negated := make([]*orm.TokenHolder, 0, len(holders))
+	for _, holder := range holders {
+		h := *holder
+		h.Balance = holder.Balance.Neg()
+		negated = append(negated, &h)
+	}
+	return r.upsertBatch(negated, clause.OnConflict{
+		Columns:   tokenHolderColumns,
+		DoUpdates: addBalance,
+	})
+}
+
+func (r *TokenHolderRepository) GetByTokenAndHolder(token, holder string) (*orm.TokenHolder, error) {
+	var tokenHolder orm.TokenHolder
+	err := r.db.Where("token = ? AND holder = ? AND chain_id = ?", token, holder, chain.Id).First(&tokenHolder).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tokenHolder, nil
+}
+
+func (r *TokenHolderRepository) CountHolders(token string) (int64, error) {
+	var count int64
+	err := r.db.Model(&orm.TokenHolder{}).
+		Where("token = ? AND chain_id = ? AND balance > 0", token, chain.Id).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *TokenHolderRepository) DeleteByToken(token string) error {
+	return r.db.Where("token = ? AND chain_id = ?", token, chain.Id).Delete(&orm.TokenHolder{}).Error
+}
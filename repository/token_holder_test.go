@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"abchain_scan/chain"
+	"abchain_scan/repository/orm"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func prepareTokenHolderTest() *TokenHolderRepository {
+	dsn := "host=localhost user=postgres password=12345678 dbname=test port=5432 sslmode=disable"
+	db, err := gorm.Open(postgres.Open(dsn))
+	if err != nil {
+		panic(err)
+	}
+	return NewTokenHolderRepository(db)
+}
+
+func TestTokenHolderRepository_AddAndSubBalances(t *testing.T) {
+	tokenHolderRepository := prepareTokenHolderTest()
+	token := "0x01"
+	holder := "0x02"
+	defer tokenHolderRepository.DeleteByToken(token)
+
+	holderAt := func(block uint64, balance int64) []*orm.TokenHolder {
+		return []*orm.TokenHolder{{Token: token, Holder: holder, ChainId: chain.Id, Balance: decimal.NewFromInt(balance), Block: block}}
+	}
+
+	require.Nil(t, tokenHolderRepository.AddBalances(holderAt(10, 5)))
+	require.Nil(t, tokenHolderRepository.AddBalances(holderAt(11, 3)))
+	// block 11 written again after a restart
+	require.Nil(t, tokenHolderRepository.AddBalances(holderAt(11, 3)))
+
+	tokenHolder, err := tokenHolderRepository.GetByTokenAndHolder(token, holder)
+	require.Nil(t, err)
+	require.True(t, decimal.NewFromInt(8).Equal(tokenHolder.Balance))
+
+	count, err := tokenHolderRepository.CountHolders(token)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), count)
+
+	// block 11 orphaned
+	require.Nil(t, tokenHolderRepository.SubBalances(holderAt(10, 3)))
+	tokenHolder, err = tokenHolderRepository.GetByTokenAndHolder(token, holder)
+	require.Nil(t, err)
+	require.True(t, decimal.NewFromInt(5).Equal(tokenHolder.Balance))
+	require.Equal(t, uint64(10), tokenHolder.Block)
+}
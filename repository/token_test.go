@@ -141,5 +141,9 @@ func TestTokenRepository_UpdateMainPair(t *testing.T) {
 	require.Nil(t, err)
 	require.True(t, token.Equal(tokenQueried))
 	require.Equal(t, "0x06", tokenQueried.MainPair)
+
+	mainPairs, err := tokenRepository.GetMainPairs([]string{token.Address, "0x02"})
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{token.Address: "0x06"}, mainPairs)
 	cleanupTokenTest(tokenRepository, token.Address)
 }
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"abchain_scan/repository/orm"
+	"abchain_scan/types"
+	"errors"
 	"gorm.io/gorm"
+	"time"
 )
 
 type TxRepository struct {
@@ -35,3 +38,46 @@ func (r *TxRepository) DeleteById(id string) error {
 	}
 	return nil
 }
+
+func (r *TxRepository) DeleteFromBlock(block uint64) error {
+	return r.db.Where("block >= ?", block).Delete(&orm.Tx{}).Error
+}
+
+// GetLastSwapAt the time of the last swap up to the block, false when there is none
+func (r *TxRepository) GetLastSwapAt(toBlock uint64) (time.Time, bool, error) {
+	tx := &orm.Tx{}
+	err := r.db.Where("block <= ? AND event IN ?", toBlock, []string{types.Buy, types.Sell}).
+		Order("block DESC").First(tx).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return tx.BlockAt, true, nil
+}
+
+// FindSwapsInBatches the swaps from a time up to the block in chain order
+func (r *TxRepository) FindSwapsInBatches(from time.Time, toBlock uint64, fn func(txs []*orm.Tx) error) error {
+	maxBatchSize := 1000
+
+	for offset := 0; ; offset += maxBatchSize {
+		var txs []*orm.Tx
+		err := r.db.Where("block_at >= ? AND block <= ? AND event IN ?", from, toBlock, []string{types.Buy, types.Sell}).
+			Order("block, block_index, tx_index").
+			Offset(offset).Limit(maxBatchSize).
+			Find(&txs).Error
+		if err != nil {
+			return err
+		}
+		if len(txs) == 0 {
+			return nil
+		}
+		if err = fn(txs); err != nil {
+			return err
+		}
+		if len(txs) < maxBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package rpc_pool
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"errors"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.uber.org/zap"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errNotConnected = errors.New("rpc endpoint not connected")
+
+// weight of the newest sample in the latency moving average
+const latencyEwmaAlpha = 0.2
+
+type endpoint struct {
+	name    string
+	url     string
+	weight  float64
+	roles   map[Role]bool
+	limiter *tokenBucket
+
+	connections int
+	next        atomic.Uint32
+
+	mu                sync.Mutex
+	clients           []*ethclient.Client
+	latencyMs         float64
+	consecutiveErrors int
+	ejected           bool
+}
+
+func newEndpoint(conf *config.RpcEndpointConf) *endpoint {
+	e := &endpoint{
+		name:        conf.Name,
+		url:         conf.Url,
+		weight:      float64(conf.Weight),
+		roles:       make(map[Role]bool, len(conf.Roles)),
+		connections: conf.Connections,
+	}
+
+	if e.name == "" {
+		e.name = endpointName(conf.Url)
+	}
+	if e.weight <= 0 {
+		e.weight = 1
+	}
+	if conf.RateLimit > 0 {
+		e.limiter = newTokenBucket(conf.RateLimit, conf.RateLimit)
+	}
+	for _, role := range conf.Roles {
+		e.roles[Role(role)] = true
+	}
+
+	if e.connections <= 0 {
+		e.connections = 1
+	}
+
+	err := e.connect()
+	if err != nil {
+		log.Logger.Error("dial rpc endpoint err", zap.String("endpoint", e.name), zap.Error(err))
+		e.ejected = true
+	}
+	e.setHealthyMetric()
+
+	return e
+}
+
+func (e *endpoint) connect() error {
+	clients := make([]*ethclient.Client, 0, e.connections)
+	for i := 0; i < e.connections; i++ {
+		client, err := ethclient.Dial(e.url)
+		if err != nil {
+			for _, c := range clients {
+				c.Close()
+			}
+			return err
+		}
+		clients = append(clients, client)
+	}
+
+	e.mu.Lock()
+	e.clients = clients
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *endpoint) isConnected() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.clients) > 0
+}
+
+func (e *endpoint) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, c := range e.clients {
+		c.Close()
+	}
+	e.clients = nil
+}
+
+// endpointName keeps only the host, urls of paid providers often carry the api key in the path
+func endpointName(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Host == "" {
+		return rawUrl
+	}
+	return u.Host
+}
+
+func (e *endpoint) hasRole(role Role) bool {
+	return e.roles[role]
+}
+
+// client round robin over the connections, nil once the endpoint is closed or before it is connected
+func (e *endpoint) client() *ethclient.Client {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.clients) == 0 {
+		return nil
+	}
+	return e.clients[int(e.next.Add(1))%len(e.clients)]
+}
+
+func (e *endpoint) isEjected() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.ejected
+}
+
+// score higher is better, weight shared by latency
+func (e *endpoint) score() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.weight / (e.latencyMs + 1)
+}
+
+func (e *endpoint) setHealthyMetric() {
+	healthy := 1.0
+	if e.ejected {
+		healthy = 0
+	}
+	metrics.RpcEndpointHealthy.WithLabelValues(e.name).Set(healthy)
+}
+
+func (e *endpoint) observeLatency(duration time.Duration) {
+	ms := float64(duration.Milliseconds())
+	metrics.RpcRequestDurationMs.WithLabelValues(e.name).Observe(ms)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.latencyMs == 0 {
+		e.latencyMs = ms
+	} else {
+		e.latencyMs = latencyEwmaAlpha*ms + (1-latencyEwmaAlpha)*e.latencyMs
+	}
+}
+
+func (e *endpoint) report(duration time.Duration, err error, maxConsecutiveErrors int) {
+	if err == nil || !isEndpointErr(err) {
+		e.observeLatency(duration)
+		e.mu.Lock()
+		e.consecutiveErrors = 0
+		e.mu.Unlock()
+		return
+	}
+
+	metrics.RpcRequestErrors.WithLabelValues(e.name).Inc()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.consecutiveErrors++
+	if !e.ejected && e.consecutiveErrors >= maxConsecutiveErrors {
+		log.Logger.Warn("eject rpc endpoint",
+			zap.String("endpoint", e.name),
+			zap.Int("consecutive errors", e.consecutiveErrors),
+			zap.Error(err))
+		e.ejected = true
+		e.setHealthyMetric()
+	}
+}
+
+func (e *endpoint) setEjected(ejected bool, reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ejected == ejected {
+		return
+	}
+
+	if ejected {
+		log.Logger.Warn("eject rpc endpoint", zap.String("endpoint", e.name), zap.String("reason", reason))
+	} else {
+		log.Logger.Info("re-admit rpc endpoint", zap.String("endpoint", e.name))
+		e.consecutiveErrors = 0
+	}
+	e.ejected = ejected
+	e.setHealthyMetric()
+}
+
+/*
+isEndpointErr
+json-rpc errors such as execution reverted are answers of a healthy node,
+transport errors, http errors (429 included) and rate limit codes are blamed on the endpoint
+*/
+func isEndpointErr(err error) bool {
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return true
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return IsRateLimitErr(err)
+	}
+
+	return true
+}
+
+// IsRateLimitErr http 429 or json-rpc -32005 limit exceeded
+func IsRateLimitErr(err error) bool {
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == 429
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.ErrorCode() == -32005
+	}
+
+	return false
+}
@@ -0,0 +1,45 @@
+package rpc_pool
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type jsonRpcErr struct {
+	code int
+}
+
+func (e *jsonRpcErr) Error() string  { return "json rpc err" }
+func (e *jsonRpcErr) ErrorCode() int { return e.code }
+
+func TestIsEndpointErr(t *testing.T) {
+	require.True(t, isEndpointErr(errors.New("connection reset by peer")))
+	require.True(t, isEndpointErr(rpc.HTTPError{StatusCode: 429, Status: "429 Too Many Requests"}))
+	require.True(t, isEndpointErr(&jsonRpcErr{code: -32005}))
+	require.False(t, isEndpointErr(&jsonRpcErr{code: 3}))
+}
+
+func TestIsRateLimitErr(t *testing.T) {
+	require.True(t, IsRateLimitErr(rpc.HTTPError{StatusCode: 429, Status: "429 Too Many Requests"}))
+	require.True(t, IsRateLimitErr(&jsonRpcErr{code: -32005}))
+	require.False(t, IsRateLimitErr(rpc.HTTPError{StatusCode: 502, Status: "502 Bad Gateway"}))
+	require.False(t, IsRateLimitErr(errors.New("connection reset by peer")))
+}
+
+func TestEndpointName(t *testing.T) {
+	require.Equal(t, "base-mainnet.g.alchemy.com", endpointName("https://base-mainnet.g.alchemy.com/v2/secret-key"))
+	require.Equal(t, "localhost:8545", endpointName("ws://localhost:8545"))
+}
+
+func TestEndpoint_Close(t *testing.T) {
+	e := &endpoint{name: "closed", clients: []*ethclient.Client{ethclient.NewClient(rpc.DialInProc(rpc.NewServer()))}}
+	require.True(t, e.isConnected())
+	require.NotNil(t, e.client())
+
+	e.close()
+	require.False(t, e.isConnected())
+	require.Nil(t, e.client())
+}
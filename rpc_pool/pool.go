@@ -0,0 +1,314 @@
+package rpc_pool
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"context"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"go.uber.org/zap"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleBlocks  Role = "blocks"
+	RoleCalls   Role = "calls"
+	RoleArchive Role = "archive"
+)
+
+var Roles = []Role{RoleBlocks, RoleCalls, RoleArchive}
+
+const probeTimeout = 5 * time.Second
+
+type Pool interface {
+	// Get picks a client for the role, done must be called with the result of the request
+	Get(role Role) (client *ethclient.Client, done func(err error))
+	Close()
+}
+
+/*
+Do
+runs fn on a client of the role and reports the result back to the pool
+*/
+func Do[T any](p Pool, role Role, fn func(client *ethclient.Client) (T, error)) (T, error) {
+	client, done := p.Get(role)
+	v, err := fn(client)
+	done(err)
+	return v, err
+}
+
+type pool struct {
+	ctx                  context.Context
+	endpoints            []*endpoint
+	maxHeadLag           uint64
+	maxConsecutiveErrors int
+	probeInterval        time.Duration
+	stopChan             chan struct{}
+}
+
+/*
+EndpointsFromChainConf
+when rpc_pool.endpoints is not configured, the endpoints of chain are used,
+blocks over ws_endpoint (or endpoint), calls over endpoint and archive over endpoint_archive,
+the ws endpoint keeps one connection per concurrent block fetch
+*/
+func EndpointsFromChainConf(chainConf *config.ChainConf) []*config.RpcEndpointConf {
+	blocksEndpoint := &config.RpcEndpointConf{
+		Name:        "blocks:" + endpointName(chainConf.WsEndpoint),
+		Url:         chainConf.WsEndpoint,
+		Connections: config.G.BlockGetter.MaxPoolSize(),
+		Roles:       []string{string(RoleBlocks)},
+	}
+	if chainConf.WsEndpoint == "" {
+		blocksEndpoint.Name = "blocks:" + endpointName(chainConf.Endpoint)
+		blocksEndpoint.Url = chainConf.Endpoint
+		blocksEndpoint.Connections = 1
+	}
+
+	return []*config.RpcEndpointConf{
+		blocksEndpoint,
+		{
+			Name:  "calls:" + endpointName(chainConf.Endpoint),
+			Url:   chainConf.Endpoint,
+			Roles: []string{string(RoleCalls)},
+		},
+		{
+			Name:  "archive:" + endpointName(chainConf.EndpointArchive),
+			Url:   chainConf.EndpointArchive,
+			Roles: []string{string(RoleArchive)},
+		},
+	}
+}
+
+func NewPool(conf *config.RpcPoolConf, chainConf *config.ChainConf) Pool {
+	endpointConfs := conf.Endpoints
+	if len(endpointConfs) == 0 {
+		endpointConfs = EndpointsFromChainConf(chainConf)
+	}
+
+	p := &pool{
+		ctx:                  context.Background(),
+		endpoints:            make([]*endpoint, 0, len(endpointConfs)),
+		maxHeadLag:           conf.MaxHeadLag,
+		maxConsecutiveErrors: conf.MaxConsecutiveErrors,
+		probeInterval:        time.Duration(conf.ProbeIntervalMs) * time.Millisecond,
+		stopChan:             make(chan struct{}),
+	}
+	if p.maxConsecutiveErrors <= 0 {
+		p.maxConsecutiveErrors = 1
+	}
+	if p.probeInterval <= 0 {
+		p.probeInterval = 5 * time.Second
+	}
+
+	for _, endpointConf := range endpointConfs {
+		p.endpoints = append(p.endpoints, newEndpoint(endpointConf))
+	}
+
+	for _, role := range Roles {
+		if len(p.endpointsOf(role)) == 0 {
+			log.Logger.Fatal("no rpc endpoint for role", zap.String("role", string(role)))
+		}
+	}
+
+	go p.probeLoop()
+	return p
+}
+
+func (p *pool) endpointsOf(role Role) []*endpoint {
+	endpoints := make([]*endpoint, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		if e.hasRole(role) {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints
+}
+
+/*
+pick
+weighted random over the healthy endpoints by score, endpoints out of rate limit are skipped,
+when all are ejected the connected ones are still tried rather than failing every request
+*/
+func (p *pool) pick(role Role) *endpoint {
+	for {
+		candidates := make([]*endpoint, 0, len(p.endpoints))
+		for _, e := range p.endpointsOf(role) {
+			if !e.isEjected() && e.isConnected() {
+				candidates = append(candidates, e)
+			}
+		}
+		if len(candidates) == 0 {
+			for _, e := range p.endpointsOf(role) {
+				if e.isConnected() {
+					candidates = append(candidates, e)
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			log.Logger.Error("no connected rpc endpoint, wait for probe", zap.String("role", string(role)))
+			time.Sleep(p.probeInterval)
+			continue
+		}
+
+		scores := make([]float64, len(candidates))
+		total := 0.0
+		for i, e := range candidates {
+			scores[i] = e.score()
+			total += scores[i]
+		}
+
+		// try in weighted random order, the first one with a free token wins,
+		// the last one left is waited on
+		for {
+			if len(candidates) == 1 {
+				e := candidates[0]
+				if e.limiter != nil {
+					e.limiter.take()
+				}
+				return e
+			}
+
+			r := rand.Float64() * total
+			i := 0
+			for ; i < len(candidates)-1; i++ {
+				r -= scores[i]
+				if r < 0 {
+					break
+				}
+			}
+
+			e := candidates[i]
+			if e.limiter == nil || e.limiter.tryTake() {
+				return e
+			}
+
+			total -= scores[i]
+			candidates = append(candidates[:i], candidates[i+1:]...)
+			scores = append(scores[:i], scores[i+1:]...)
+		}
+	}
+}
+
+func (p *pool) Get(role Role) (*ethclient.Client, func(err error)) {
+	for {
+		e := p.pick(role)
+		client := e.client()
+		if client == nil {
+			// closed between the pick and now
+			continue
+		}
+
+		start := time.Now()
+		return client, func(err error) {
+			e.report(time.Since(start), err, p.maxConsecutiveErrors)
+		}
+	}
+}
+
+func (p *pool) probeLoop() {
+	ticker := time.NewTicker(p.probeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case <-ticker.C:
+			p.probe()
+		}
+	}
+}
+
+/*
+probe
+asks every endpoint for its head, ejects the ones lagging behind the best head
+by more than max_head_lag and re-admits the recovered ones
+*/
+func (p *pool) probe() {
+	heads := make([]uint64, len(p.endpoints))
+	errs := make([]error, len(p.endpoints))
+
+	wg := &sync.WaitGroup{}
+	for i, e := range p.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if !e.isConnected() {
+				if errs[i] = e.connect(); errs[i] != nil {
+					return
+				}
+			}
+
+			client := e.client()
+			if client == nil {
+				errs[i] = errNotConnected
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(p.ctx, probeTimeout)
+			defer cancel()
+			start := time.Now()
+			heads[i], errs[i] = client.BlockNumber(ctx)
+			if errs[i] == nil {
+				e.observeLatency(time.Since(start))
+			}
+		}()
+	}
+	wg.Wait()
+
+	var bestHead uint64
+	for i := range p.endpoints {
+		if errs[i] == nil && heads[i] > bestHead {
+			bestHead = heads[i]
+		}
+	}
+
+	for i, e := range p.endpoints {
+		if errs[i] != nil {
+			metrics.RpcRequestErrors.WithLabelValues(e.name).Inc()
+			e.setEjected(true, "probe failed: "+errs[i].Error())
+			continue
+		}
+
+		lag := bestHead - heads[i]
+		metrics.RpcEndpointHeadLag.WithLabelValues(e.name).Set(float64(lag))
+		if p.maxHeadLag > 0 && lag > p.maxHeadLag {
+			e.setEjected(true, "head lag")
+			continue
+		}
+		e.setEjected(false, "")
+	}
+}
+
+func (p *pool) Close() {
+	close(p.stopChan)
+	for _, e := range p.endpoints {
+		e.close()
+	}
+}
+
+type staticPool struct {
+	client *ethclient.Client
+}
+
+/*
+NewStaticPool
+a pool over a single client without any scoring, for tests and tools
+*/
+func NewStaticPool(client *ethclient.Client) Pool {
+	return &staticPool{client: client}
+}
+
+func (p *staticPool) Get(Role) (*ethclient.Client, func(err error)) {
+	return p.client, func(error) {}
+}
+
+func (p *staticPool) Close() {
+	p.client.Close()
+}
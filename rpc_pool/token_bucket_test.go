@@ -0,0 +1,23 @@
+package rpc_pool
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(10, 2)
+	require.True(t, b.tryTake())
+	require.True(t, b.tryTake())
+	require.False(t, b.tryTake())
+
+	now := time.Now()
+	b.take()
+	require.True(t, time.Since(now) >= 50*time.Millisecond)
+
+	time.Sleep(250 * time.Millisecond)
+	require.True(t, b.tryTake())
+	require.True(t, b.tryTake())
+	require.False(t, b.tryTake())
+}
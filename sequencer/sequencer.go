@@ -3,8 +3,11 @@ package sequencer
 import (
 	"abchain_scan/config"
 	"abchain_scan/log"
+	"abchain_scan/metrics"
 	"go.uber.org/zap"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Sequenceable interface {
@@ -18,32 +21,121 @@ type Committable interface {
 type Sequencer interface {
 	Init(height uint64)
 	CommitWithSequence(value Sequenceable, output Committable)
+	// WaitForWindow blocks the producer while sequence is too far ahead of WaitingOn
+	WaitForWindow(sequence uint64)
+	WaitingOn() uint64
+	Pending() int
+}
+
+// Checkpoint keeps the last committed sequence of a sequencer across restarts
+type Checkpoint interface {
+	SetSequencerCheckpoint(name string, sequence uint64)
+	GetSequencerCheckpoint(name string) (uint64, bool)
 }
 
 type sequencer struct {
-	active   bool
-	mu       sync.Mutex
-	cond     *sync.Cond
-	sequence uint64
+	name          string
+	checkpoint    Checkpoint // optional
+	active        bool
+	stallTimeout  time.Duration
+	reorderWindow uint64
+	mu            sync.Mutex
+	cond          *sync.Cond
+	sequence      atomic.Uint64
+	initialized   bool
+	pending       atomic.Int64
+	lastCommitAt  atomic.Int64
+	stallOnce     sync.Once
 }
 
-func NewSequencer() Sequencer {
+/*
+NewSequencer
+name labels the metrics and logs of the sequencer
+*/
+func NewSequencer(name string) Sequencer {
 	s := &sequencer{
-		active: config.G.EnableSequencer,
+		name:          name,
+		active:        config.G.EnableSequencer,
+		stallTimeout:  time.Duration(config.G.Sequencer.StallTimeoutMs) * time.Millisecond,
+		reorderWindow: config.G.Sequencer.ReorderWindow,
 	}
 	s.cond = sync.NewCond(&s.mu)
 	return s
 }
 
+/*
+NewCheckpointedSequencer
+saves every committed sequence to the checkpoint, the first Init resumes after the saved one,
+the checkpoint is only kept while the sequencer is enabled
+*/
+func NewCheckpointedSequencer(name string, checkpoint Checkpoint) Sequencer {
+	s := NewSequencer(name).(*sequencer)
+	s.checkpoint = checkpoint
+	return s
+}
+
+/*
+Init
+sets the next sequence to commit, it can be called again to restart from a checkpoint
+as long as nothing is parked, the first call of a checkpointed sequencer resumes after the saved sequence instead
+*/
 func (s *sequencer) Init(sequence uint64) {
-	log.Logger.Info("init sequencer", zap.Uint64("sequence", sequence))
-	if s.sequence == 0 {
-		s.sequence = sequence - 1
-	} else {
-		log.Logger.Fatal("sequencer init err", zap.Uint64("sequence", sequence), zap.Uint64("old sequence", s.sequence))
+	s.mu.Lock()
+	if !s.initialized && s.active && s.checkpoint != nil {
+		if saved, ok := s.checkpoint.GetSequencerCheckpoint(s.name); ok {
+			sequence = saved + 1
+		}
+	}
+	log.Logger.Info("init sequencer", zap.String("sequencer", s.name), zap.Uint64("sequence", sequence))
+
+	if s.initialized && s.pending.Load() > 0 {
+		log.Logger.Fatal("sequencer init err, items pending",
+			zap.String("sequencer", s.name),
+			zap.Uint64("sequence", sequence),
+			zap.Uint64("old sequence", s.sequence.Load()),
+			zap.Int64("pending", s.pending.Load()))
+	}
+	s.initialized = true
+	s.sequence.Store(sequence - 1)
+	s.lastCommitAt.Store(time.Now().UnixNano())
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	metrics.SequencerWaitingOn.WithLabelValues(s.name).Set(float64(sequence))
+	if s.active && s.stallTimeout > 0 {
+		s.stallOnce.Do(func() { go s.watchStall() })
 	}
 }
 
+/*
+WaitingOn
+the next sequence to be committed, readable without blocking on a slow output
+*/
+func (s *sequencer) WaitingOn() uint64 {
+	return s.sequence.Load() + 1
+}
+
+// Pending items committed but parked until their sequence comes
+func (s *sequencer) Pending() int {
+	return int(s.pending.Load())
+}
+
+func (s *sequencer) inWindow(sequence uint64) bool {
+	return s.reorderWindow == 0 || sequence < s.WaitingOn()+s.reorderWindow
+}
+
+func (s *sequencer) WaitForWindow(sequence uint64) {
+	if !s.active {
+		return
+	}
+
+	s.mu.Lock()
+	for !s.inWindow(sequence) {
+		s.cond.Wait()
+	}
+	s.mu.Unlock()
+}
+
 func (s *sequencer) CommitWithSequence(value Sequenceable, output Committable) {
 	if !s.active {
 		output.Commit(value)
@@ -53,13 +145,46 @@ func (s *sequencer) CommitWithSequence(value Sequenceable, output Committable) {
 	sequence := value.GetSequence()
 
 	s.mu.Lock()
-	for s.sequence+1 != sequence {
+	metrics.SequencerPending.WithLabelValues(s.name).Set(float64(s.pending.Add(1)))
+	for s.sequence.Load()+1 != sequence {
 		s.cond.Wait()
 	}
+	metrics.SequencerPending.WithLabelValues(s.name).Set(float64(s.pending.Add(-1)))
 
 	output.Commit(value)
-	s.sequence = sequence
+	s.sequence.Store(sequence)
+	if s.checkpoint != nil {
+		s.checkpoint.SetSequencerCheckpoint(s.name, sequence)
+	}
+	s.lastCommitAt.Store(time.Now().UnixNano())
+	metrics.SequencerWaitingOn.WithLabelValues(s.name).Set(float64(sequence + 1))
 
 	s.cond.Broadcast()
 	s.mu.Unlock()
 }
+
+/*
+watchStall
+reports the missing sequence when items are parked but nothing was committed for stall_timeout_ms
+*/
+func (s *sequencer) watchStall() {
+	ticker := time.NewTicker(s.stallTimeout / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pending := s.Pending()
+		idle := time.Since(time.Unix(0, s.lastCommitAt.Load()))
+
+		if pending == 0 || idle < s.stallTimeout {
+			metrics.SequencerStalled.WithLabelValues(s.name).Set(0)
+			continue
+		}
+
+		metrics.SequencerStalled.WithLabelValues(s.name).Set(1)
+		log.Logger.Warn("sequencer stalled",
+			zap.String("sequencer", s.name),
+			zap.Uint64("missing sequence", s.WaitingOn()),
+			zap.Int("pending", pending),
+			zap.Duration("idle", idle.Round(time.Second)))
+	}
+}
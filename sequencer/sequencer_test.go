@@ -0,0 +1,109 @@
+package sequencer
+
+import (
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type item uint64
+
+func (i item) GetSequence() uint64 {
+	return uint64(i)
+}
+
+type collector struct {
+	mu    sync.Mutex
+	items []uint64
+}
+
+func (c *collector) Commit(value Sequenceable) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = append(c.items, value.GetSequence())
+}
+
+func TestCommitWithSequence(t *testing.T) {
+	s := NewSequencer("test")
+	s.Init(10)
+	out := &collector{}
+
+	wg := &sync.WaitGroup{}
+	for _, i := range []uint64{12, 11} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.CommitWithSequence(item(i), out)
+		}()
+	}
+	require.Eventually(t, func() bool { return s.Pending() == 2 }, time.Second, time.Millisecond)
+	require.Equal(t, uint64(10), s.WaitingOn())
+
+	s.CommitWithSequence(item(10), out)
+	wg.Wait()
+	require.Equal(t, []uint64{10, 11, 12}, out.items)
+	require.Equal(t, 0, s.Pending())
+	require.Equal(t, uint64(13), s.WaitingOn())
+
+	// re-init from a checkpoint while idle
+	s.Init(20)
+	require.Equal(t, uint64(20), s.WaitingOn())
+}
+
+func TestWaitForWindow(t *testing.T) {
+	s := NewSequencer("test").(*sequencer)
+	s.reorderWindow = 2
+	s.Init(1)
+
+	s.WaitForWindow(2)
+
+	released := make(chan struct{})
+	go func() {
+		s.WaitForWindow(3)
+		close(released)
+	}()
+
+	select {
+	case <-released:
+		t.Fatal("sequence 3 is out of the window")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.CommitWithSequence(item(1), &collector{})
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("sequence 3 is in the window after 1 is committed")
+	}
+}
+
+type memoryCheckpoint map[string]uint64
+
+func (c memoryCheckpoint) SetSequencerCheckpoint(name string, sequence uint64) {
+	c[name] = sequence
+}
+
+func (c memoryCheckpoint) GetSequencerCheckpoint(name string) (uint64, bool) {
+	sequence, ok := c[name]
+	return sequence, ok
+}
+
+func TestCheckpointedSequencer(t *testing.T) {
+	checkpoint := memoryCheckpoint{}
+	s := NewCheckpointedSequencer("test", checkpoint)
+	s.Init(10)
+	out := &collector{}
+	s.CommitWithSequence(item(10), out)
+	s.CommitWithSequence(item(11), out)
+	require.Equal(t, uint64(11), checkpoint["test"])
+
+	// restarted, resumes after the saved sequence whatever the start height
+	restarted := NewCheckpointedSequencer("test", checkpoint)
+	restarted.Init(5)
+	require.Equal(t, uint64(12), restarted.WaitingOn())
+
+	// a later init is a restart from the given checkpoint
+	restarted.Init(30)
+	require.Equal(t, uint64(30), restarted.WaitingOn())
+}
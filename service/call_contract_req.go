@@ -1,6 +1,7 @@
 package service
 
 import (
+	"abchain_scan/abi/aerodrome"
 	"abchain_scan/abi/bep20"
 	uniswapv2 "abchain_scan/abi/uniswap/v2"
 	uniswapv3 "abchain_scan/abi/uniswap/v3"
@@ -54,6 +55,10 @@ var (
 			Abi:   uniswapv3.PoolAbi,
 			Names: []string{"fee"},
 		},
+		{
+			Abi:   aerodrome.PoolAbi,
+			Names: []string{"stable"},
+		},
 	}
 
 	Name2Data map[string][]byte
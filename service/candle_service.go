@@ -0,0 +1,266 @@
+package service
+
+import (
+	"abchain_scan/chain"
+	"abchain_scan/config"
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"abchain_scan/repository/orm"
+	"abchain_scan/types"
+	"fmt"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"time"
+)
+
+var candleIntervals = map[string]time.Duration{
+	"1s": time.Second,
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+type CandleService interface {
+	Apply(blockInfo *types.BlockInfo) ([]*orm.Candle, error)
+	Rollback(fromHeight uint64) error
+}
+
+// intervalCandles the open candle of each pair in one interval, indexed by open time to close them in bulk
+type intervalCandles struct {
+	name     string
+	duration time.Duration
+	byPair   map[string]*orm.Candle
+	byOpenAt map[int64]map[string]struct{}
+}
+
+func newIntervalCandles(name string, duration time.Duration) *intervalCandles {
+	return &intervalCandles{
+		name:     name,
+		duration: duration,
+		byPair:   make(map[string]*orm.Candle),
+		byOpenAt: make(map[int64]map[string]struct{}),
+	}
+}
+
+func (ic *intervalCandles) remove(candle *orm.Candle) {
+	delete(ic.byPair, candle.Pair)
+	pairs := ic.byOpenAt[candle.OpenAt.Unix()]
+	delete(pairs, candle.Pair)
+	if len(pairs) == 0 {
+		delete(ic.byOpenAt, candle.OpenAt.Unix())
+	}
+}
+
+/*
+add
+a swap of a later interval replaces the open candle of the pair, on a rebuild that candle
+was closed by a committed block, a swap older than the open candle is ignored
+*/
+func (ic *intervalCandles) add(tx *orm.Tx) *orm.Candle {
+	openAt := tx.BlockAt.Truncate(ic.duration)
+	candle, ok := ic.byPair[tx.PairAddress]
+	if ok && candle.OpenAt.After(openAt) {
+		return nil
+	}
+	if ok && candle.OpenAt.Before(openAt) {
+		ic.remove(candle)
+		ok = false
+	}
+
+	if !ok {
+		candle = &orm.Candle{
+			Pair:       tx.PairAddress,
+			ChainId:    chain.Id,
+			Interval:   ic.name,
+			OpenAt:     openAt,
+			Open:       tx.PriceUsd,
+			High:       tx.PriceUsd,
+			Low:        tx.PriceUsd,
+			FirstBlock: tx.Block,
+		}
+		ic.byPair[tx.PairAddress] = candle
+		if ic.byOpenAt[openAt.Unix()] == nil {
+			ic.byOpenAt[openAt.Unix()] = make(map[string]struct{})
+		}
+		ic.byOpenAt[openAt.Unix()][tx.PairAddress] = struct{}{}
+	}
+
+	if tx.PriceUsd.GreaterThan(candle.High) {
+		candle.High = tx.PriceUsd
+	}
+	if tx.PriceUsd.LessThan(candle.Low) {
+		candle.Low = tx.PriceUsd
+	}
+	candle.Close = tx.PriceUsd
+	candle.VolumeBase = candle.VolumeBase.Add(tx.Token0Amount.Abs())
+	candle.VolumeUsd = candle.VolumeUsd.Add(tx.AmountUsd.Abs())
+	candle.Trades++
+	candle.LastBlock = tx.Block
+	return candle
+}
+
+// closeBefore the candles ended at the time
+func (ic *intervalCandles) closeBefore(at time.Time) []*orm.Candle {
+	var closed []*orm.Candle
+	for openAtUnix, pairs := range ic.byOpenAt {
+		if !time.Unix(openAtUnix, 0).Add(ic.duration).After(at) {
+			for pair := range pairs {
+				candle := ic.byPair[pair]
+				candle.Closed = true
+				closed = append(closed, candle)
+				delete(ic.byPair, pair)
+			}
+			delete(ic.byOpenAt, openAtUnix)
+		}
+	}
+	return closed
+}
+
+/*
+candleService
+the candles are built from the priced swaps of the committed blocks, a candle is closed by the first block at or after its end
+and written then, the open candles only live in memory: after a restart or a reorg they are rebuilt from the swaps
+of the tx database since the start of the longest interval holding the last swap
+*/
+type candleService struct {
+	mu        sync.Mutex
+	dbService DBService
+	intervals []*intervalCandles
+	longest   time.Duration
+	rebuilt   bool
+}
+
+func NewCandleService(dbService DBService, conf *config.CandleConf) CandleService {
+	s := &candleService{dbService: dbService}
+	for _, name := range conf.Intervals {
+		duration, ok := candleIntervals[name]
+		if !ok {
+			log.Logger.Fatal("unknown candle interval", zap.String("interval", name))
+		}
+		s.intervals = append(s.intervals, newIntervalCandles(name, duration))
+		if duration > s.longest {
+			s.longest = duration
+		}
+	}
+	return s
+}
+
+func isCandleSwap(tx *orm.Tx) bool {
+	return (tx.Event == types.Buy || tx.Event == types.Sell) && tx.PriceUsd.Sign() > 0
+}
+
+// swapsInChainOrder the priced swaps of the block by tx and log index
+func swapsInChainOrder(txs []*orm.Tx) []*orm.Tx {
+	swaps := make([]*orm.Tx, 0, len(txs))
+	for _, tx := range txs {
+		if isCandleSwap(tx) {
+			swaps = append(swaps, tx)
+		}
+	}
+	sort.SliceStable(swaps, func(i, j int) bool {
+		if swaps[i].BlockIndex != swaps[j].BlockIndex {
+			return swaps[i].BlockIndex < swaps[j].BlockIndex
+		}
+		return swaps[i].TxIndex < swaps[j].TxIndex
+	})
+	return swaps
+}
+
+/*
+Apply
+closes the candles ended at the block time, adds the swaps of the block and writes the closed candles,
+returns the closed candles and a copy of the open ones the block traded in
+*/
+func (s *candleService) Apply(blockInfo *types.BlockInfo) ([]*orm.Candle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.rebuilt {
+		if err := s.rebuild(blockInfo.Height - 1); err != nil {
+			return nil, fmt.Errorf("rebuild candles: %w", err)
+		}
+		s.rebuilt = true
+	}
+
+	blockAt := time.Unix(int64(blockInfo.Timestamp), 0)
+	var closed []*orm.Candle
+	for _, ic := range s.intervals {
+		intervalClosed := ic.closeBefore(blockAt)
+		metrics.ClosedCandleTotal.WithLabelValues(ic.name).Add(float64(len(intervalClosed)))
+		closed = append(closed, intervalClosed...)
+	}
+	if err := s.dbService.AddCandles(closed); err != nil {
+		return nil, err
+	}
+
+	touched := make(map[*orm.Candle]struct{})
+	updates := closed
+	for _, tx := range swapsInChainOrder(blockInfo.Txs) {
+		for _, ic := range s.intervals {
+			candle := ic.add(tx)
+			if candle == nil {
+				continue
+			}
+			if _, ok := touched[candle]; !ok {
+				touched[candle] = struct{}{}
+				updates = append(updates, candle)
+			}
+		}
+	}
+	for i := len(closed); i < len(updates); i++ {
+		candle := *updates[i]
+		updates[i] = &candle
+	}
+
+	for _, ic := range s.intervals {
+		metrics.OpenCandles.WithLabelValues(ic.name).Set(float64(len(ic.byPair)))
+	}
+	return updates, nil
+}
+
+func (s *candleService) rebuild(lastBlock uint64) error {
+	now := time.Now()
+	for i, ic := range s.intervals {
+		s.intervals[i] = newIntervalCandles(ic.name, ic.duration)
+	}
+
+	lastSwapAt, ok, err := s.dbService.GetLastSwapAt(lastBlock)
+	if err != nil || !ok {
+		return err
+	}
+
+	swaps := 0
+	err = s.dbService.FindSwaps(lastSwapAt.Truncate(s.longest), lastBlock, func(txs []*orm.Tx) error {
+		for _, tx := range txs {
+			if !isCandleSwap(tx) {
+				continue
+			}
+			swaps++
+			for _, ic := range s.intervals {
+				ic.add(tx)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	metrics.CandleRebuildDurationMs.Set(float64(time.Since(now).Milliseconds()))
+	log.Logger.Info("candles rebuilt",
+		zap.Uint64("last block", lastBlock),
+		zap.Int("swaps", swaps),
+		zap.Duration("duration", time.Since(now)))
+	return nil
+}
+
+// Rollback drops the candles of the orphaned blocks, the open candles are rebuilt by the next Apply
+func (s *candleService) Rollback(fromHeight uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rebuilt = false
+	return s.dbService.DeleteCandlesFromBlock(fromHeight)
+}
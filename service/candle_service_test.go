@@ -0,0 +1,155 @@
+package service
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/repository/orm"
+	"abchain_scan/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// candleTestDB the swaps of the tx database, the closed candles written
+type candleTestDB struct {
+	DBService
+	swaps  []*orm.Tx
+	closed []*orm.Candle
+}
+
+func (db *candleTestDB) AddCandles(candles []*orm.Candle) error {
+	db.closed = append(db.closed, candles...)
+	return nil
+}
+
+func (db *candleTestDB) DeleteCandlesFromBlock(block uint64) error {
+	return nil
+}
+
+func (db *candleTestDB) GetLastSwapAt(toBlock uint64) (time.Time, bool, error) {
+	var last *orm.Tx
+	for _, tx := range db.swaps {
+		if tx.Block <= toBlock {
+			last = tx
+		}
+	}
+	if last == nil {
+		return time.Time{}, false, nil
+	}
+	return last.BlockAt, true, nil
+}
+
+func (db *candleTestDB) FindSwaps(from time.Time, toBlock uint64, fn func(txs []*orm.Tx) error) error {
+	var txs []*orm.Tx
+	for _, tx := range db.swaps {
+		if !tx.BlockAt.Before(from) && tx.Block <= toBlock {
+			txs = append(txs, tx)
+		}
+	}
+	return fn(txs)
+}
+
+func candleSwap(block uint64, at time.Time, index uint, price, amount int64) *orm.Tx {
+	return &orm.Tx{
+		Event:        types.Buy,
+		PairAddress:  "0x01",
+		Block:        block,
+		BlockAt:      at,
+		TxIndex:      index,
+		PriceUsd:     decimal.NewFromInt(price),
+		Token0Amount: decimal.NewFromInt(amount),
+		AmountUsd:    decimal.NewFromInt(price * amount),
+	}
+}
+
+func findCandle(candles []*orm.Candle, interval string) *orm.Candle {
+	for _, candle := range candles {
+		if candle.Interval == interval {
+			return candle
+		}
+	}
+	return nil
+}
+
+func TestCandleService_Apply(t *testing.T) {
+	db := &candleTestDB{}
+	s := NewCandleService(db, &config.CandleConf{Intervals: []string{"1m", "1h"}})
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	block := func(height uint64, at time.Time, txs ...*orm.Tx) []*orm.Candle {
+		updates, err := s.Apply(&types.BlockInfo{Height: height, Timestamp: uint64(at.Unix()), Txs: txs})
+		require.NoError(t, err)
+		return updates
+	}
+
+	updates := block(1, start, candleSwap(1, start, 2, 12, 1), candleSwap(1, start, 1, 10, 2))
+	require.Len(t, updates, 2)
+	candle := findCandle(updates, "1m")
+	require.False(t, candle.Closed)
+	require.True(t, candle.Open.Equal(decimal.NewFromInt(10)), "log index order")
+	require.True(t, candle.Close.Equal(decimal.NewFromInt(12)))
+	require.Equal(t, 2, candle.Trades)
+
+	at := start.Add(30 * time.Second)
+	block(2, at, candleSwap(2, at, 0, 8, 1), &orm.Tx{Event: types.Add, PairAddress: "0x01", Block: 2, BlockAt: at})
+	require.Empty(t, db.closed)
+
+	at = start.Add(time.Minute)
+	updates = block(3, at, candleSwap(3, at, 0, 20, 1))
+	require.Len(t, db.closed, 1)
+	closed := db.closed[0]
+	require.True(t, closed.Closed)
+	require.Equal(t, "1m", closed.Interval)
+	require.True(t, closed.Open.Equal(decimal.NewFromInt(10)))
+	require.True(t, closed.High.Equal(decimal.NewFromInt(12)))
+	require.True(t, closed.Low.Equal(decimal.NewFromInt(8)))
+	require.True(t, closed.Close.Equal(decimal.NewFromInt(8)))
+	require.True(t, closed.VolumeBase.Equal(decimal.NewFromInt(4)))
+	require.True(t, closed.VolumeUsd.Equal(decimal.NewFromInt(40)))
+	require.Equal(t, uint64(1), closed.FirstBlock)
+	require.Equal(t, uint64(2), closed.LastBlock)
+	// the closed 1m candle, the new 1m candle and the 1h candle
+	require.Len(t, updates, 3)
+	hour := findCandle(updates[1:], "1h")
+	require.Equal(t, 4, hour.Trades)
+	require.True(t, hour.High.Equal(decimal.NewFromInt(20)))
+}
+
+func TestCandleService_Rebuild(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	db := &candleTestDB{swaps: []*orm.Tx{
+		candleSwap(1, start.Add(-2*time.Hour), 0, 5, 1),
+		candleSwap(2, start, 0, 10, 1),
+		candleSwap(3, start.Add(10*time.Second), 0, 7, 3),
+		// not committed before the restart
+		candleSwap(4, start.Add(20*time.Second), 0, 100, 1),
+	}}
+	s := NewCandleService(db, &config.CandleConf{Intervals: []string{"1m", "1h", "1d"}})
+
+	at := start.Add(20 * time.Second)
+	updates, err := s.Apply(&types.BlockInfo{Height: 4, Timestamp: uint64(at.Unix()), Txs: []*orm.Tx{candleSwap(4, at, 0, 9, 1)}})
+	require.NoError(t, err)
+	require.Empty(t, db.closed)
+
+	minute := findCandle(updates, "1m")
+	require.True(t, minute.Open.Equal(decimal.NewFromInt(10)))
+	require.True(t, minute.Low.Equal(decimal.NewFromInt(7)))
+	require.True(t, minute.Close.Equal(decimal.NewFromInt(9)))
+	require.Equal(t, 3, minute.Trades)
+
+	day := findCandle(updates, "1d")
+	require.True(t, day.Open.Equal(decimal.NewFromInt(5)))
+	require.Equal(t, 4, day.Trades)
+	require.Equal(t, uint64(1), day.FirstBlock)
+
+	// a reorg back to block 2 rebuilds from the swaps up to it
+	require.NoError(t, s.Rollback(3))
+	db.swaps = db.swaps[:2]
+	at = start.Add(2 * time.Minute)
+	_, err = s.Apply(&types.BlockInfo{Height: 3, Timestamp: uint64(at.Unix())})
+	require.NoError(t, err)
+	require.Len(t, db.closed, 1)
+	require.Equal(t, "1m", db.closed[0].Interval)
+	require.Equal(t, 1, db.closed[0].Trades)
+	require.True(t, db.closed[0].Close.Equal(decimal.NewFromInt(10)))
+}
@@ -1,15 +1,19 @@
 package service
 
 import (
+	"abchain_scan/abi/aerodrome"
 	uniswapv2 "abchain_scan/abi/uniswap/v2"
 	uniswapv3 "abchain_scan/abi/uniswap/v3"
+	uniswapv4 "abchain_scan/abi/uniswap/v4"
 	"abchain_scan/config"
 	"abchain_scan/metrics"
+	"abchain_scan/rpc_pool"
 	"abchain_scan/types"
 	"context"
 	"errors"
 	"github.com/avast/retry-go/v4"
 	"github.com/ethereum/go-ethereum"
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"math/big"
@@ -23,18 +27,21 @@ var (
 	ErrWrongOutputLength = errors.New("wrong output length")
 	ErrReserve0NotBigInt = errors.New("reverse0 is not *big.Int")
 	ErrReserve1NotBigInt = errors.New("reverse1 is not *big.Int")
+	ErrPoolKeyNotFound   = errors.New("pool key not found")
 )
 
 type ContractCaller struct {
 	ctx         context.Context
-	ethClient   *ethclient.Client
+	rpcPool     rpc_pool.Pool
+	role        rpc_pool.Role
 	retryParams *config.RetryParams
 }
 
-func NewContractCaller(ethClient *ethclient.Client, retryParams *config.RetryParams) *ContractCaller {
+func NewContractCaller(rpcPool rpc_pool.Pool, role rpc_pool.Role, retryParams *config.RetryParams) *ContractCaller {
 	return &ContractCaller{
 		ctx:         context.Background(),
-		ethClient:   ethClient,
+		rpcPool:     rpcPool,
+		role:        role,
 		retryParams: retryParams,
 	}
 }
@@ -51,14 +58,16 @@ func IsRetryableErr(err error) bool {
 
 func (c *ContractCaller) callContract(req *CallContractReq) ([]byte, error) {
 	now := time.Now()
-	bytes, err := c.ethClient.CallContract(
-		c.ctx,
-		ethereum.CallMsg{
-			To:   req.Address,
-			Data: req.Data,
-		},
-		req.BlockNumber,
-	)
+	bytes, err := rpc_pool.Do(c.rpcPool, c.role, func(client *ethclient.Client) ([]byte, error) {
+		return client.CallContract(
+			c.ctx,
+			ethereum.CallMsg{
+				To:   req.Address,
+				Data: req.Data,
+			},
+			req.BlockNumber,
+		)
+	})
 
 	if err != nil {
 		if IsRetryableErr(err) {
@@ -78,7 +87,8 @@ func (c *ContractCaller) callContract(req *CallContractReq) ([]byte, error) {
 }
 
 func (c *ContractCaller) CallContract(req *CallContractReq) ([]byte, error) {
-	ctxWithTimeout, _ := context.WithTimeout(c.ctx, c.retryParams.Timeout)
+	ctxWithTimeout, cancel := context.WithTimeout(c.ctx, c.retryParams.Timeout)
+	defer cancel()
 	return retry.DoWithData(func() ([]byte, error) {
 		return c.callContract(req)
 	}, c.retryParams.Attempts, c.retryParams.Delay, retry.Context(ctxWithTimeout))
@@ -232,12 +242,133 @@ func (c *ContractCaller) CallGetPool(factoryAddress, token0Address, token1Addres
 	return ParseAddress(values[0])
 }
 
+func (c *ContractCaller) queryBool(address *common.Address, name string) (bool, error) {
+	values, err := c.queryValues(address, name, 1)
+	if err != nil {
+		return false, err
+	}
+	return ParseBool(values[0])
+}
+
 /*
-callGetReserves
-for uniswap/pancake v2
+CallStable
+for aerodrome
+*/
+func (c *ContractCaller) CallStable(address *common.Address) (bool, error) {
+	return c.queryBool(address, "stable")
+}
+
+/*
+CallGetPoolAerodrome
+for aerodrome, pools are keyed by the stable flag instead of a fee
+*/
+func (c *ContractCaller) CallGetPoolAerodrome(factoryAddress, token0Address, token1Address *common.Address, stable bool) (common.Address, error) {
+	req := BuildCallContractReqDynamic(nil, factoryAddress, aerodrome.FactoryAbi, "getPool", token0Address, token1Address, stable)
+
+	bytes, err := c.CallContract(req)
+	if err != nil {
+		return types.ZeroAddress, err
+	}
+
+	if len(bytes) == 0 {
+		return types.ZeroAddress, ErrOutputEmpty
+	}
+
+	values, unpackErr := AerodromeFactoryUnpacker.Unpack("getPool", bytes, 1)
+	if unpackErr != nil {
+		return types.ZeroAddress, unpackErr
+	}
+
+	if len(values) != 1 {
+		return types.ZeroAddress, ErrWrongOutputLength
+	}
+
+	return ParseAddress(values[0])
+}
+
+/*
+CallIsPool
+asks the aerodrome factory whether address is one of its pools
+*/
+func (c *ContractCaller) CallIsPool(address *common.Address) (bool, error) {
+	req := BuildCallContractReqDynamic(nil, &aerodrome.FactoryAddress, aerodrome.FactoryAbi, "isPool", address)
+
+	bytes, err := c.CallContract(req)
+	if err != nil {
+		return false, err
+	}
+
+	if len(bytes) == 0 {
+		return false, ErrOutputEmpty
+	}
+
+	values, unpackErr := AerodromeFactoryUnpacker.Unpack("isPool", bytes, 1)
+	if unpackErr != nil {
+		return false, unpackErr
+	}
+
+	if len(values) != 1 {
+		return false, ErrWrongOutputLength
+	}
+
+	return ParseBool(values[0])
+}
+
+/*
+CallPoolKey
+for uniswap v4, reads the pool key the PositionManager stored for poolId,
+pools that never had a position minted through it are not found
+*/
+func (c *ContractCaller) CallPoolKey(poolId common.Hash) (*types.PoolKey, error) {
+	req := BuildCallContractReqDynamic(nil, &uniswapv4.PositionManagerAddress, uniswapv4.PositionManagerAbi, "poolKeys", uniswapv4.PoolKeyId(poolId))
+
+	bytes, err := c.CallContract(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes) == 0 {
+		return nil, ErrOutputEmpty
+	}
+
+	values, unpackErr := UniswapV4PositionManagerUnpacker.Unpack("poolKeys", bytes, 5)
+	if unpackErr != nil {
+		return nil, unpackErr
+	}
+
+	if len(values) != 5 {
+		return nil, ErrWrongOutputLength
+	}
+
+	poolKey := &types.PoolKey{}
+	if poolKey.Currency0, err = ParseAddress(values[0]); err != nil {
+		return nil, err
+	}
+	if poolKey.Currency1, err = ParseAddress(values[1]); err != nil {
+		return nil, err
+	}
+	if poolKey.Fee, err = ParseBigInt(values[2]); err != nil {
+		return nil, err
+	}
+	if poolKey.TickSpacing, err = ParseBigInt(values[3]); err != nil {
+		return nil, err
+	}
+	if poolKey.Hooks, err = ParseAddress(values[4]); err != nil {
+		return nil, err
+	}
+
+	if poolKey.TickSpacing.Sign() == 0 {
+		return nil, ErrPoolKeyNotFound
+	}
+	return poolKey, nil
+}
+
+/*
+callValuesByBlockNumber
+for the state of a pool at a past block, needs the archive role
 */
-func (c *ContractCaller) callGetReserves(blockNumber *big.Int) ([]interface{}, error) {
-	req := BuildCallContractReqDynamic(blockNumber, &types.WETHUSDCPairAddressUniswapV2, uniswapv2.PairAbi, "getReserves")
+func (c *ContractCaller) callValuesByBlockNumber(blockNumber *big.Int, address *common.Address, abi *ethabi.ABI, unpacker Unpacker, name string, outputLength int) ([]interface{}, error) {
+	req := BuildCallContractReqDynamic(blockNumber, address, abi, name)
 
 	bytes, err := c.CallContract(req)
 	if err != nil {
@@ -248,20 +379,24 @@ func (c *ContractCaller) callGetReserves(blockNumber *big.Int) ([]interface{}, e
 		return nil, ErrOutputEmpty
 	}
 
-	values, unpackErr := UniswapV2PairUnpacker.Unpack("getReserves", bytes, 3)
+	values, unpackErr := unpacker.Unpack(name, bytes, outputLength)
 	if unpackErr != nil {
 		return nil, unpackErr
 	}
 
-	if len(values) != 3 {
+	if len(values) != outputLength {
 		return nil, ErrWrongOutputLength
 	}
 
 	return values, nil
 }
 
-func (c *ContractCaller) GetReservesByBlockNumber(blockNumber *big.Int) (*big.Int, *big.Int, error) {
-	values, err := c.callGetReserves(blockNumber)
+/*
+GetReservesByBlockNumber
+for uniswap/pancake v2 and solidly pairs
+*/
+func (c *ContractCaller) GetReservesByBlockNumber(address *common.Address, blockNumber *big.Int) (*big.Int, *big.Int, error) {
+	values, err := c.callValuesByBlockNumber(blockNumber, address, uniswapv2.PairAbi, UniswapV2PairUnpacker, "getReserves", 3)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -278,3 +413,42 @@ func (c *ContractCaller) GetReservesByBlockNumber(blockNumber *big.Int) (*big.In
 
 	return reserve0, reserve1, nil
 }
+
+/*
+GetSqrtPriceAndLiquidityByBlockNumber
+for uniswap/pancake v3 pools, sqrtPriceX96 is the first output of slot0 on both
+*/
+func (c *ContractCaller) GetSqrtPriceAndLiquidityByBlockNumber(address *common.Address, blockNumber *big.Int) (*big.Int, *big.Int, error) {
+	sqrtPriceX96, liquidity, _, err := c.GetPoolStateV3ByBlockNumber(address, blockNumber)
+	return sqrtPriceX96, liquidity, err
+}
+
+/*
+GetPoolStateV3ByBlockNumber
+sqrtPriceX96, active liquidity and tick of a uniswap/pancake v3 pool, the tick is the second output of slot0 on both
+*/
+func (c *ContractCaller) GetPoolStateV3ByBlockNumber(address *common.Address, blockNumber *big.Int) (*big.Int, *big.Int, *big.Int, error) {
+	slot0, err := c.callValuesByBlockNumber(blockNumber, address, uniswapv3.PoolAbi, UniswapV3PoolUnpacker, "slot0", 7)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	sqrtPriceX96, err := ParseBigInt(slot0[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tick, err := ParseBigInt(slot0[1])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	values, err := c.callValuesByBlockNumber(blockNumber, address, uniswapv3.PoolAbi, UniswapV3PoolUnpacker, "liquidity", 1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	liquidity, err := ParseBigInt(values[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return sqrtPriceX96, liquidity, tick, nil
+}
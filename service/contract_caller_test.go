@@ -6,6 +6,7 @@ import (
 	uniswapv2 "abchain_scan/abi/uniswap/v2"
 	uniswapv3 "abchain_scan/abi/uniswap/v3"
 	"abchain_scan/config"
+	"abchain_scan/rpc_pool"
 	"abchain_scan/types"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -354,9 +355,9 @@ func TestContractCaller_GetReservesByBlockNumber(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	cc := NewContractCaller(ethClient, config.G.ContractCaller.Retry.GetRetryParams())
+	cc := NewContractCaller(rpc_pool.NewStaticPool(ethClient), rpc_pool.RoleCalls, config.G.ContractCaller.Retry.GetRetryParams())
 
-	r0, r1, err := cc.GetReservesByBlockNumber(big.NewInt(30423400))
+	r0, r1, err := cc.GetReservesByBlockNumber(&types.WETHUSDCPairAddressUniswapV2, big.NewInt(30423400))
 	if err != nil {
 		t.Fatal(err)
 	}
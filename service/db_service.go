@@ -3,20 +3,44 @@ package service
 import (
 	"abchain_scan/repository"
 	"abchain_scan/repository/orm"
+	"abchain_scan/types"
+	"time"
 )
 
 type DBService interface {
 	AddTokens(tokens []*orm.Token) error
 	AddPairs(pairs []*orm.Pair) error
 	AddTxs(txs []*orm.Tx) error
+	DeleteTokens(addresses []string) error
+	UpdateTokenCreation(token *orm.Token) error
+	DeletePairs(addresses []string) error
+	DeleteTxsFromBlock(block uint64) error
+	AddTokenHolders(holders []*orm.TokenHolder) error
+	SubTokenHolders(holders []*orm.TokenHolder) error
+	UpsertPoolStates(states []*orm.PoolState) error
+	GetPoolStates(addresses []string) (map[string]*orm.PoolState, error)
+	RestorePoolStates(states []*orm.PoolState, removed []string) error
+	GetMainPairs(tokens []string) (map[string]string, error)
+	GetPoolStatesByToken0(tokens []string) ([]*orm.PoolState, error)
+	UpdateMainPairs(changes []*types.MainPairChange) error
+	AddCandles(candles []*orm.Candle) error
+	DeleteCandlesFromBlock(block uint64) error
+	GetLastSwapAt(toBlock uint64) (time.Time, bool, error)
+	FindSwaps(from time.Time, toBlock uint64, fn func(txs []*orm.Tx) error) error
 }
 
 type dbService struct {
-	tokenRepository *repository.TokenRepository
-	pairRepository  *repository.PairRepository
-	txRepository    *repository.TxRepository
-	enableTokenPair bool
-	enableTx        bool
+	tokenRepository       *repository.TokenRepository
+	pairRepository        *repository.PairRepository
+	txRepository          *repository.TxRepository
+	tokenHolderRepository *repository.TokenHolderRepository
+	poolStateRepository   *repository.PoolStateRepository
+	candleRepository      *repository.CandleRepository
+	enableTokenPair       bool
+	enableTx              bool
+	enableHolder          bool
+	enablePoolState       bool
+	enableCandle          bool
 }
 
 func (s *dbService) AddTokens(tokens []*orm.Token) error {
@@ -43,16 +67,193 @@ func (s *dbService) AddTxs(txs []*orm.Tx) error {
 	return s.txRepository.CreateBatch(txs, "token0_address", "block", "block_index", "tx_index")
 }
 
+func (s *dbService) DeleteTokens(addresses []string) error {
+	if !s.enableTokenPair {
+		return nil
+	}
+
+	return s.tokenRepository.DeleteByAddressesAndChainId(addresses)
+}
+
+func (s *dbService) DeletePairs(addresses []string) error {
+	if !s.enableTokenPair {
+		return nil
+	}
+
+	return s.pairRepository.DeleteByAddressesAndChainId(addresses)
+}
+
+func (s *dbService) DeleteTxsFromBlock(block uint64) error {
+	if !s.enableTx {
+		return nil
+	}
+
+	return s.txRepository.DeleteFromBlock(block)
+}
+
+func holderTokens(holders []*orm.TokenHolder) []string {
+	seen := make(map[string]struct{}, len(holders))
+	tokens := make([]string, 0, len(holders))
+	for _, holder := range holders {
+		if _, ok := seen[holder.Token]; ok {
+			continue
+		}
+		seen[holder.Token] = struct{}{}
+		tokens = append(tokens, holder.Token)
+	}
+	return tokens
+}
+
+func (s *dbService) AddTokenHolders(holders []*orm.TokenHolder) error {
+	if !s.enableHolder || len(holders) == 0 {
+		return nil
+	}
+
+	err := s.tokenHolderRepository.AddBalances(holders)
+	if err != nil {
+		return err
+	}
+	return s.tokenRepository.UpdateHolders(holderTokens(holders))
+}
+
+func (s *dbService) SubTokenHolders(holders []*orm.TokenHolder) error {
+	if !s.enableHolder || len(holders) == 0 {
+		return nil
+	}
+
+	err := s.tokenHolderRepository.SubBalances(holders)
+	if err != nil {
+		return err
+	}
+	return s.tokenRepository.UpdateHolders(holderTokens(holders))
+}
+
+func (s *dbService) UpsertPoolStates(states []*orm.PoolState) error {
+	if !s.enablePoolState || len(states) == 0 {
+		return nil
+	}
+
+	return s.poolStateRepository.UpsertBatch(states)
+}
+
+// GetPoolStates the stored state of the pools by address, pools without a state are left out
+func (s *dbService) GetPoolStates(addresses []string) (map[string]*orm.PoolState, error) {
+	address2State := make(map[string]*orm.PoolState, len(addresses))
+	if !s.enablePoolState || len(addresses) == 0 {
+		return address2State, nil
+	}
+
+	states, err := s.poolStateRepository.GetByAddresses(addresses)
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		address2State[state.Address] = state
+	}
+	return address2State, nil
+}
+
+// RestorePoolStates puts back the states before the orphaned blocks, removed pools had no state then
+func (s *dbService) RestorePoolStates(states []*orm.PoolState, removed []string) error {
+	if !s.enablePoolState {
+		return nil
+	}
+
+	err := s.poolStateRepository.RestoreBatch(states)
+	if err != nil {
+		return err
+	}
+	return s.poolStateRepository.DeleteByAddresses(removed)
+}
+
+func (s *dbService) UpdateTokenCreation(token *orm.Token) error {
+	if !s.enableTokenPair {
+		return nil
+	}
+
+	return s.tokenRepository.UpdateCreation(token)
+}
+
+func (s *dbService) GetMainPairs(tokens []string) (map[string]string, error) {
+	if !s.enableTokenPair {
+		return map[string]string{}, nil
+	}
+
+	return s.tokenRepository.GetMainPairs(tokens)
+}
+
+func (s *dbService) GetPoolStatesByToken0(tokens []string) ([]*orm.PoolState, error) {
+	if !s.enablePoolState {
+		return nil, nil
+	}
+
+	return s.poolStateRepository.GetByToken0(tokens)
+}
+
+func (s *dbService) UpdateMainPairs(changes []*types.MainPairChange) error {
+	if !s.enableTokenPair {
+		return nil
+	}
+
+	for _, change := range changes {
+		if err := s.tokenRepository.UpdateMainPair(change.Token, change.MainPair); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *dbService) AddCandles(candles []*orm.Candle) error {
+	if !s.enableCandle || len(candles) == 0 {
+		return nil
+	}
+
+	return s.candleRepository.UpsertBatch(candles)
+}
+
+func (s *dbService) DeleteCandlesFromBlock(block uint64) error {
+	if !s.enableCandle {
+		return nil
+	}
+
+	return s.candleRepository.DeleteFromBlock(block)
+}
+
+func (s *dbService) GetLastSwapAt(toBlock uint64) (time.Time, bool, error) {
+	if !s.enableTx {
+		return time.Time{}, false, nil
+	}
+
+	return s.txRepository.GetLastSwapAt(toBlock)
+}
+
+func (s *dbService) FindSwaps(from time.Time, toBlock uint64, fn func(txs []*orm.Tx) error) error {
+	if !s.enableTx {
+		return nil
+	}
+
+	return s.txRepository.FindSwapsInBatches(from, toBlock, fn)
+}
+
 func NewDBService(
 	tokenRepository *repository.TokenRepository,
 	pairRepository *repository.PairRepository,
 	txRepository *repository.TxRepository,
+	tokenHolderRepository *repository.TokenHolderRepository,
+	poolStateRepository *repository.PoolStateRepository,
+	candleRepository *repository.CandleRepository,
 ) DBService {
 	return &dbService{
-		tokenRepository: tokenRepository,
-		pairRepository:  pairRepository,
-		txRepository:    txRepository,
-		enableTokenPair: tokenRepository != nil && pairRepository != nil,
-		enableTx:        txRepository != nil,
+		tokenRepository:       tokenRepository,
+		pairRepository:        pairRepository,
+		txRepository:          txRepository,
+		tokenHolderRepository: tokenHolderRepository,
+		poolStateRepository:   poolStateRepository,
+		candleRepository:      candleRepository,
+		enableTokenPair:       tokenRepository != nil && pairRepository != nil,
+		enableTx:              txRepository != nil,
+		enableHolder:          tokenRepository != nil && tokenHolderRepository != nil,
+		enablePoolState:       poolStateRepository != nil,
+		enableCandle:          txRepository != nil && candleRepository != nil,
 	}
 }
@@ -14,6 +14,27 @@ import (
 
 type KafkaSender interface {
 	Send(block *types.BlockInfo) error
+	SendRevert(revert *types.RevertInfo) error
+	SendMainPairChange(change *types.MainPairChangeInfo) error
+}
+
+// nopKafkaSender for backfill, the consumers only follow the live indexer
+type nopKafkaSender struct{}
+
+func NewNopKafkaSender() KafkaSender {
+	return nopKafkaSender{}
+}
+
+func (nopKafkaSender) Send(*types.BlockInfo) error {
+	return nil
+}
+
+func (nopKafkaSender) SendRevert(*types.RevertInfo) error {
+	return nil
+}
+
+func (nopKafkaSender) SendMainPairChange(*types.MainPairChangeInfo) error {
+	return nil
 }
 
 type kafkaSender struct {
@@ -84,3 +105,39 @@ func (s *kafkaSender) Send(block *types.BlockInfo) error {
 
 	return nil
 }
+
+func (s *kafkaSender) SendRevert(revert *types.RevertInfo) error {
+	if !s.conf.Enabled {
+		return nil
+	}
+
+	data, err := json.Marshal(revert)
+	if err != nil {
+		return fmt.Errorf("json.Marshal error: %v, %v", err, revert)
+	}
+
+	s.asyncProducer.Input() <- &sarama.ProducerMessage{
+		Topic: s.conf.Topic,
+		Value: sarama.ByteEncoder(data),
+	}
+
+	return nil
+}
+
+func (s *kafkaSender) SendMainPairChange(change *types.MainPairChangeInfo) error {
+	if !s.conf.Enabled {
+		return nil
+	}
+
+	data, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("json.Marshal error: %v, %v", err, change)
+	}
+
+	s.asyncProducer.Input() <- &sarama.ProducerMessage{
+		Topic: s.conf.Topic,
+		Value: sarama.ByteEncoder(data),
+	}
+
+	return nil
+}
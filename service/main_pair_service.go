@@ -0,0 +1,201 @@
+package service
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/metrics"
+	"abchain_scan/repository/orm"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"sort"
+	"sync"
+)
+
+// blocks between two prunes of the stale tokens
+const mainPairPruneInterval = 1000
+
+type MainPairService interface {
+	Update(height uint64, poolStates []*orm.PoolState) ([]*types.MainPairChange, error)
+	Reset()
+}
+
+// pairLiquidity the usd liquidity of a pool state and the weighted liquidity the pairs are compared by
+type pairLiquidity struct {
+	usd      decimal.Decimal
+	weighted decimal.Decimal
+}
+
+type tokenPairs struct {
+	mainPair string
+	pairs    map[string]*pairLiquidity // the pairs against a quote token
+	height   uint64
+}
+
+/*
+mainPairService
+the candidates of a token are its pairs with a quote token as token1, a token seen for the first time since a start
+or a reset is loaded with its main pair and the pool states of its pairs from the database, only the tokens with a pool
+state in the block are chosen again, a liquidity carried from an older block is not repriced until the pool is updated,
+the virtual liquidity of the concentrated liquidity pools is weighted down before comparing it with the v2 reserves
+*/
+type mainPairService struct {
+	mu                          sync.Mutex
+	dbService                   DBService
+	hysteresis                  decimal.Decimal
+	minLiquidityUsd             decimal.Decimal
+	staleBlocks                 uint64
+	concentratedLiquidityWeight decimal.Decimal
+	tokens                      map[string]*tokenPairs
+}
+
+func NewMainPairService(dbService DBService, conf *config.MainPairConf) MainPairService {
+	weight := decimal.NewFromInt(1)
+	if conf.ConcentratedLiquidityWeight > 0 {
+		weight = decimal.NewFromFloat(conf.ConcentratedLiquidityWeight)
+	}
+	return &mainPairService{
+		dbService:                   dbService,
+		hysteresis:                  decimal.NewFromFloat(conf.Hysteresis),
+		minLiquidityUsd:             decimal.NewFromFloat(conf.MinLiquidityUsd),
+		staleBlocks:                 conf.StaleBlocks,
+		concentratedLiquidityWeight: weight,
+		tokens:                      make(map[string]*tokenPairs),
+	}
+}
+
+// liquidity the SqrtPriceX96 of a state is only set for concentrated liquidity pools
+func (s *mainPairService) liquidity(state *orm.PoolState) *pairLiquidity {
+	weighted := state.LiquidityUsd
+	if state.SqrtPriceX96.Sign() > 0 {
+		weighted = weighted.Mul(s.concentratedLiquidityWeight)
+	}
+	return &pairLiquidity{usd: state.LiquidityUsd, weighted: weighted}
+}
+
+// isQuotePair the token0 of a pair with a quote token as token1, the higher priority quote of two quote tokens
+func isQuotePair(state *orm.PoolState) bool {
+	_, ok := types.QuotePriority(common.HexToAddress(state.Token1))
+	return ok
+}
+
+func (s *mainPairService) load(tokens []string) error {
+	mainPairs, err := s.dbService.GetMainPairs(tokens)
+	if err != nil {
+		return err
+	}
+	states, err := s.dbService.GetPoolStatesByToken0(tokens)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		s.tokens[token] = &tokenPairs{mainPair: mainPairs[token], pairs: make(map[string]*pairLiquidity)}
+	}
+	for _, state := range states {
+		if t, ok := s.tokens[state.Token0]; ok && isQuotePair(state) {
+			t.pairs[state.Address] = s.liquidity(state)
+		}
+	}
+	return nil
+}
+
+func (s *mainPairService) Update(height uint64, poolStates []*orm.PoolState) ([]*types.MainPairChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var unknown []string
+	touched := make(map[string]struct{})
+	for _, state := range poolStates {
+		if !isQuotePair(state) {
+			continue
+		}
+		if _, ok := touched[state.Token0]; ok {
+			continue
+		}
+		touched[state.Token0] = struct{}{}
+		if _, ok := s.tokens[state.Token0]; !ok {
+			unknown = append(unknown, state.Token0)
+		}
+	}
+	if err := s.load(unknown); err != nil {
+		return nil, err
+	}
+
+	for _, state := range poolStates {
+		if !isQuotePair(state) {
+			continue
+		}
+		t := s.tokens[state.Token0]
+		t.pairs[state.Address] = s.liquidity(state)
+		t.height = height
+	}
+
+	var changes []*types.MainPairChange
+	for token := range touched {
+		if change := s.choose(token, s.tokens[token]); change != nil {
+			changes = append(changes, change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Token < changes[j].Token
+	})
+
+	if height%mainPairPruneInterval == 0 {
+		s.prune(height)
+	}
+	metrics.MainPairTokens.Set(float64(len(s.tokens)))
+	metrics.MainPairChangeTotal.Add(float64(len(changes)))
+	return changes, nil
+}
+
+/*
+choose
+the deepest pair by weighted liquidity takes over with more than (1 + hysteresis) times the weighted liquidity
+of the main pair, ties go to the lowest address so the choice does not depend on the map order,
+the change carries the unweighted usd liquidity
+*/
+func (s *mainPairService) choose(token string, t *tokenPairs) *types.MainPairChange {
+	best := ""
+	bestLiquidity := decimal.Zero
+	for pair, liquidity := range t.pairs {
+		weighted := liquidity.weighted
+		if weighted.GreaterThan(bestLiquidity) || (weighted.Equal(bestLiquidity) && best != "" && pair < best) {
+			best, bestLiquidity = pair, weighted
+		}
+	}
+	if best == "" || best == t.mainPair || bestLiquidity.LessThan(s.minLiquidityUsd) {
+		return nil
+	}
+
+	current := decimal.Zero
+	if liquidity, ok := t.pairs[t.mainPair]; ok {
+		current = liquidity.weighted
+	}
+	if !bestLiquidity.GreaterThan(current.Mul(decimal.NewFromInt(1).Add(s.hysteresis))) {
+		return nil
+	}
+
+	change := &types.MainPairChange{
+		Token:        token,
+		MainPair:     best,
+		Previous:     t.mainPair,
+		LiquidityUsd: t.pairs[best].usd.String(),
+	}
+	t.mainPair = best
+	return change
+}
+
+func (s *mainPairService) prune(height uint64) {
+	for token, t := range s.tokens {
+		if t.height+s.staleBlocks <= height {
+			delete(s.tokens, token)
+		}
+	}
+}
+
+// Reset after a reorg, the tokens are loaded again from the restored database
+func (s *mainPairService) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]*tokenPairs)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/repository/orm"
+	"abchain_scan/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// mainPairTestDB the main pairs and pool states stored before a restart
+type mainPairTestDB struct {
+	DBService
+	mainPairs map[string]string
+	states    []*orm.PoolState
+}
+
+func (db *mainPairTestDB) GetMainPairs(tokens []string) (map[string]string, error) {
+	return db.mainPairs, nil
+}
+
+func (db *mainPairTestDB) GetPoolStatesByToken0(tokens []string) ([]*orm.PoolState, error) {
+	return db.states, nil
+}
+
+func TestMainPairService_Update(t *testing.T) {
+	token := "0x00000000000000000000000000000000000000A1"
+	usdc := types.USDCAddress.String()
+	weth := types.WETHAddress.String()
+	state := func(pair, token1 string, liquidity int64) *orm.PoolState {
+		return &orm.PoolState{Address: pair, Token0: token, Token1: token1, LiquidityUsd: decimal.NewFromInt(liquidity)}
+	}
+
+	db := &mainPairTestDB{
+		mainPairs: map[string]string{token: "0x01"},
+		states:    []*orm.PoolState{state("0x01", usdc, 1000)},
+	}
+	s := NewMainPairService(db, &config.MainPairConf{Hysteresis: 0.2, MinLiquidityUsd: 100, StaleBlocks: 100})
+
+	// within the hysteresis of the stored main pair
+	changes, err := s.Update(1, []*orm.PoolState{state("0x02", weth, 1100)})
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	changes, err = s.Update(2, []*orm.PoolState{state("0x02", weth, 1300)})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, &types.MainPairChange{Token: token, MainPair: "0x02", Previous: "0x01", LiquidityUsd: "1300"}, changes[0])
+
+	// back within the hysteresis does not flap
+	changes, err = s.Update(3, []*orm.PoolState{state("0x01", usdc, 1500)})
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	// the main pair drained
+	changes, err = s.Update(4, []*orm.PoolState{state("0x02", weth, 10)})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, "0x01", changes[0].MainPair)
+
+	// a pair without a quote token is no candidate
+	changes, err = s.Update(5, []*orm.PoolState{state("0x03", "0x00000000000000000000000000000000000000b1", 100000)})
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
+
+func TestMainPairService_MinLiquidity(t *testing.T) {
+	token := "0x00000000000000000000000000000000000000A1"
+	db := &mainPairTestDB{mainPairs: map[string]string{}}
+	s := NewMainPairService(db, &config.MainPairConf{Hysteresis: 0.2, MinLiquidityUsd: 100, StaleBlocks: 100})
+
+	changes, err := s.Update(1, []*orm.PoolState{{Address: "0x01", Token0: token, Token1: types.USDCAddress.String(), LiquidityUsd: decimal.NewFromInt(50)}})
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	changes, err = s.Update(2, []*orm.PoolState{{Address: "0x01", Token0: token, Token1: types.USDCAddress.String(), LiquidityUsd: decimal.NewFromInt(150)}})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, "", changes[0].Previous)
+}
+
+func TestMainPairService_ConcentratedLiquidityWeight(t *testing.T) {
+	token := "0x00000000000000000000000000000000000000A1"
+	db := &mainPairTestDB{mainPairs: map[string]string{token: "0x01"}}
+	s := NewMainPairService(db, &config.MainPairConf{Hysteresis: 0.2, StaleBlocks: 100, ConcentratedLiquidityWeight: 0.25})
+	v2 := &orm.PoolState{Address: "0x01", Token0: token, Token1: types.USDCAddress.String(), LiquidityUsd: decimal.NewFromInt(1000)}
+	v3 := func(liquidity int64) *orm.PoolState {
+		return &orm.PoolState{Address: "0x02", Token0: token, Token1: types.WETHAddress.String(), LiquidityUsd: decimal.NewFromInt(liquidity), SqrtPriceX96: decimal.NewFromInt(1)}
+	}
+
+	// the virtual liquidity of a narrow range is weighted down to 750
+	changes, err := s.Update(1, []*orm.PoolState{v2, v3(3000)})
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	changes, err = s.Update(2, []*orm.PoolState{v3(5000)})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, &types.MainPairChange{Token: token, MainPair: "0x02", Previous: "0x01", LiquidityUsd: "5000"}, changes[0])
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"abchain_scan/abi"
+	"abchain_scan/config"
+	"abchain_scan/types"
+	"errors"
+	"fmt"
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"sort"
+)
+
+const nativePricePrecision = 36
+
+var (
+	ErrNoPricePool   = errors.New("no native price pool")
+	ErrNoPoolReading = errors.New("no pool with liquidity to price the native token")
+	q96              = decimal.NewFromBigInt(new(big.Int).Lsh(big.NewInt(1), 96), 0)
+	q192             = decimal.NewFromBigInt(new(big.Int).Lsh(big.NewInt(1), 192), 0)
+)
+
+// pricePoolState the raw state of a pool at the end of a block, reserves for v2 and solidly, sqrtPriceX96 and liquidity for v3
+type pricePoolState struct {
+	reserve0     *big.Int
+	reserve1     *big.Int
+	sqrtPriceX96 *big.Int
+	liquidity    *big.Int
+}
+
+/*
+pricePool
+prices its base token in its stable token, the native wrapper in a stable for the native price pools,
+a pool priced quote token in the quote token listed before it for the quote pools
+*/
+type pricePool struct {
+	address        common.Address
+	isV3           bool
+	stable         bool          // solidly stable pool
+	event          *ethabi.Event // Sync for v2 and solidly, Swap for v3, the last one of a block is the pool state at its end
+	baseIsToken0   bool
+	baseDecimals   int32
+	stableDecimals int32
+}
+
+func newPricePool(conf *config.PricePoolConf, baseToken common.Address, baseDecimals int32) (*pricePool, error) {
+	dex, ok := abi.GetDexByName(conf.Dex)
+	if !ok {
+		return nil, fmt.Errorf("price pool %s: unknown dex %s", conf.Address, conf.Dex)
+	}
+	if !common.IsHexAddress(conf.Address) || !common.IsHexAddress(conf.StableToken) {
+		return nil, fmt.Errorf("price pool %s: invalid address", conf.Address)
+	}
+
+	p := &pricePool{
+		address:        common.HexToAddress(conf.Address),
+		baseIsToken0:   baseToken.Cmp(common.HexToAddress(conf.StableToken)) < 0,
+		baseDecimals:   baseDecimals,
+		stableDecimals: conf.StableDecimals,
+		stable:         conf.Stable,
+	}
+	if p.stable && dex.Family != abi.FamilySolidly {
+		return nil, fmt.Errorf("price pool %s: only solidly pools are stable, not dex %s", conf.Address, conf.Dex)
+	}
+	switch dex.Family {
+	case abi.FamilyUniswapV2, abi.FamilySolidly:
+		p.event = dex.Events[abi.EventSync]
+	case abi.FamilyUniswapV3:
+		p.isV3 = true
+		p.event = dex.Events[abi.EventSwap]
+	default:
+		return nil, fmt.Errorf("price pool %s: dex %s of family %s can't price the native token", conf.Address, conf.Dex, dex.Family)
+	}
+	return p, nil
+}
+
+func newPricePools(confs []*config.PricePoolConf) ([]*pricePool, error) {
+	if len(confs) == 0 {
+		return nil, ErrNoPricePool
+	}
+	wrapper, _ := types.GetQuoteToken(types.NativeWrapperAddress())
+	pools := make([]*pricePool, 0, len(confs))
+	for _, conf := range confs {
+		pool, err := newPricePool(conf, wrapper.Address, int32(wrapper.Decimals))
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+/*
+stateFromReceipts
+the state after the last Sync or Swap of the pool in the block, nil if the pool had none
+*/
+func (p *pricePool) stateFromReceipts(receipts []*ethtypes.Receipt) *pricePoolState {
+	var last *ethtypes.Log
+	for _, receipt := range receipts {
+		if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+			continue
+		}
+		for _, ethLog := range receipt.Logs {
+			if ethLog.Address != p.address || len(ethLog.Topics) == 0 || ethLog.Topics[0] != p.event.ID {
+				continue
+			}
+			if last == nil || ethLog.Index > last.Index {
+				last = ethLog
+			}
+		}
+	}
+	if last == nil {
+		return nil
+	}
+
+	values, err := p.event.Inputs.NonIndexed().Unpack(last.Data)
+	if err != nil {
+		return nil
+	}
+	if !p.isV3 {
+		if len(values) < 2 {
+			return nil
+		}
+		reserve0, err0 := ParseBigInt(values[0])
+		reserve1, err1 := ParseBigInt(values[1])
+		if err0 != nil || err1 != nil {
+			return nil
+		}
+		return &pricePoolState{reserve0: reserve0, reserve1: reserve1}
+	}
+
+	// amount0, amount1, sqrtPriceX96, liquidity, tick, then the protocol fees on pancake
+	if len(values) < 4 {
+		return nil
+	}
+	sqrtPriceX96, err0 := ParseBigInt(values[2])
+	liquidity, err1 := ParseBigInt(values[3])
+	if err0 != nil || err1 != nil {
+		return nil
+	}
+	return &pricePoolState{sqrtPriceX96: sqrtPriceX96, liquidity: liquidity}
+}
+
+// newQuotePricePools the pools of the pool priced quote tokens
+func newQuotePricePools() (map[common.Address]*pricePool, error) {
+	pools := make(map[common.Address]*pricePool)
+	for _, quoteToken := range types.QuoteTokens {
+		if quoteToken.PriceSource != types.PriceSourcePool {
+			continue
+		}
+		pool, err := newPricePool(quoteToken.Pool, quoteToken.Address, int32(quoteToken.Decimals))
+		if err != nil {
+			return nil, err
+		}
+		pools[quoteToken.Address] = pool
+	}
+	return pools, nil
+}
+
+/*
+reading
+the base token price in stable and the weight of the pool, the weight is the stable side of the reserves,
+the virtual reserve of the active liquidity for v3, a solidly stable pool is priced on its curve
+*/
+func (p *pricePool) reading(state *pricePoolState) (price, weight decimal.Decimal, ok bool) {
+	if state == nil {
+		return decimal.Zero, decimal.Zero, false
+	}
+	decimalsShift := p.baseDecimals - p.stableDecimals
+	if !p.isV3 {
+		baseReserve, stableReserve := state.reserve0, state.reserve1
+		if !p.baseIsToken0 {
+			baseReserve, stableReserve = state.reserve1, state.reserve0
+		}
+		if baseReserve == nil || stableReserve == nil || baseReserve.Sign() <= 0 || stableReserve.Sign() <= 0 {
+			return decimal.Zero, decimal.Zero, false
+		}
+		stable := decimal.NewFromBigInt(stableReserve, -p.stableDecimals)
+		if p.stable {
+			return types.ReservePrice(decimal.NewFromBigInt(baseReserve, -p.baseDecimals), stable, true), stable, true
+		}
+		price = decimal.NewFromBigInt(stableReserve, decimalsShift).DivRound(decimal.NewFromBigInt(baseReserve, 0), nativePricePrecision)
+		return price, stable, true
+	}
+
+	if state.sqrtPriceX96 == nil || state.liquidity == nil || state.sqrtPriceX96.Sign() <= 0 || state.liquidity.Sign() <= 0 {
+		return decimal.Zero, decimal.Zero, false
+	}
+	sqrtPrice := decimal.NewFromBigInt(state.sqrtPriceX96, 0)
+	priceX192 := decimal.NewFromBigInt(new(big.Int).Mul(state.sqrtPriceX96, state.sqrtPriceX96), 0)
+	liquidity := decimal.NewFromBigInt(state.liquidity, 0)
+	if p.baseIsToken0 {
+		// token1 per token0
+		price = priceX192.Shift(decimalsShift).DivRound(q192, nativePricePrecision)
+		weight = liquidity.Mul(sqrtPrice).DivRound(q96, 0).Shift(-p.stableDecimals)
+	} else {
+		price = q192.Shift(decimalsShift).DivRound(priceX192, nativePricePrecision)
+		weight = liquidity.Mul(q96).DivRound(sqrtPrice, 0).Shift(-p.stableDecimals)
+	}
+	if weight.Sign() <= 0 {
+		return decimal.Zero, decimal.Zero, false
+	}
+	return price, weight, true
+}
+
+type poolReading struct {
+	pool   common.Address
+	price  decimal.Decimal
+	weight decimal.Decimal
+}
+
+/*
+aggregateReadings
+the liquidity weighted mean of the readings within maxDeviation of the weighted median,
+a pool moved far away by a manipulation or a stale state does not move the price, the outliers are returned
+*/
+func aggregateReadings(readings []*poolReading, maxDeviation float64) (decimal.Decimal, []common.Address, error) {
+	if len(readings) == 0 {
+		return decimal.Zero, nil, ErrNoPoolReading
+	}
+
+	sorted := make([]*poolReading, len(readings))
+	copy(sorted, readings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].price.LessThan(sorted[j].price)
+	})
+
+	totalWeight := decimal.Zero
+	for _, r := range sorted {
+		totalWeight = totalWeight.Add(r.weight)
+	}
+	half := totalWeight.Div(decimal.NewFromInt(2))
+	median := sorted[len(sorted)-1].price
+	cumulative := decimal.Zero
+	for _, r := range sorted {
+		cumulative = cumulative.Add(r.weight)
+		if cumulative.GreaterThanOrEqual(half) {
+			median = r.price
+			break
+		}
+	}
+
+	deviation := decimal.NewFromFloat(maxDeviation)
+	weightSum := decimal.Zero
+	weightedSum := decimal.Zero
+	outliers := make([]common.Address, 0)
+	for _, r := range readings {
+		if maxDeviation > 0 && r.price.Sub(median).Abs().GreaterThan(median.Mul(deviation)) {
+			outliers = append(outliers, r.pool)
+			continue
+		}
+		weightSum = weightSum.Add(r.weight)
+		weightedSum = weightedSum.Add(r.price.Mul(r.weight))
+	}
+	if weightSum.Sign() <= 0 {
+		return decimal.Zero, outliers, ErrNoPoolReading
+	}
+	return weightedSum.DivRound(weightSum, nativePricePrecision), outliers, nil
+}
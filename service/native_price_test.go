@@ -0,0 +1,110 @@
+package service
+
+import (
+	uniswapv2 "abchain_scan/abi/uniswap/v2"
+	"abchain_scan/config"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func ether(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+func TestPricePool_ReadingV2(t *testing.T) {
+	pool := &pricePool{baseIsToken0: true, baseDecimals: 18, stableDecimals: 6}
+	price, weight, ok := pool.reading(&pricePoolState{reserve0: ether(10), reserve1: big.NewInt(30000e6)})
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromInt(3000)), price.String())
+	require.True(t, weight.Equal(decimal.NewFromInt(30000)), weight.String())
+
+	pool.baseIsToken0 = false
+	price, _, ok = pool.reading(&pricePoolState{reserve0: big.NewInt(30000e6), reserve1: ether(10)})
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromInt(3000)), price.String())
+
+	_, _, ok = pool.reading(&pricePoolState{reserve0: big.NewInt(0), reserve1: ether(10)})
+	require.False(t, ok)
+}
+
+func TestPricePool_ReadingStable(t *testing.T) {
+	// 1000 base against 1100 stable trades near the peg on the curve, not at the reserve ratio
+	pool := &pricePool{baseIsToken0: true, baseDecimals: 18, stableDecimals: 6, stable: true}
+	price, weight, ok := pool.reading(&pricePoolState{reserve0: ether(1000), reserve1: big.NewInt(1100e6)})
+	require.True(t, ok)
+	require.True(t, price.GreaterThan(decimal.NewFromInt(1)), price.String())
+	require.True(t, price.LessThan(decimal.NewFromFloat(1.001)), price.String())
+	require.True(t, weight.Equal(decimal.NewFromInt(1100)), weight.String())
+
+	_, err := newPricePool(&config.PricePoolConf{
+		Address:     "0x88A43bbDF9D098eEC7bCEda4e2494615dfD9bB9C",
+		Dex:         types.ProtocolNameNewSwap,
+		StableToken: "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+		Stable:      true,
+	}, types.WETHAddress, 18)
+	require.Error(t, err)
+}
+
+func TestPricePool_ReadingV3(t *testing.T) {
+	// 1 native = 4 stable with equal decimals, sqrtPrice = 2 * 2^96
+	sqrtPriceX96 := new(big.Int).Lsh(big.NewInt(2), 96)
+	pool := &pricePool{isV3: true, baseIsToken0: true, baseDecimals: 18, stableDecimals: 18}
+	price, weight, ok := pool.reading(&pricePoolState{sqrtPriceX96: sqrtPriceX96, liquidity: ether(5)})
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromInt(4)), price.String())
+	require.True(t, weight.Equal(decimal.NewFromInt(10)), weight.String())
+
+	// the native token as token1, 1 token0 = 4 token1 is 1 native = 0.25 stable
+	pool.baseIsToken0 = false
+	price, weight, ok = pool.reading(&pricePoolState{sqrtPriceX96: sqrtPriceX96, liquidity: ether(5)})
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromFloat(0.25)), price.String())
+	require.True(t, weight.Equal(decimal.NewFromFloat(2.5)), weight.String())
+}
+
+func TestAggregateReadings(t *testing.T) {
+	a := common.HexToAddress("0x01")
+	b := common.HexToAddress("0x02")
+	c := common.HexToAddress("0x03")
+
+	price, outliers, err := aggregateReadings([]*poolReading{
+		{pool: a, price: decimal.NewFromInt(3000), weight: decimal.NewFromInt(3)},
+		{pool: b, price: decimal.NewFromInt(3010), weight: decimal.NewFromInt(1)},
+		{pool: c, price: decimal.NewFromInt(6000), weight: decimal.NewFromInt(1)},
+	}, 0.02)
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{c}, outliers)
+	require.True(t, price.Equal(decimal.NewFromFloat(3002.5)), price.String())
+
+	_, _, err = aggregateReadings(nil, 0.02)
+	require.ErrorIs(t, err, ErrNoPoolReading)
+}
+
+func TestPricePool_StateFromReceipts(t *testing.T) {
+	address := common.HexToAddress("0x88A43bbDF9D098eEC7bCEda4e2494615dfD9bB9C")
+	pool := &pricePool{address: address, event: uniswapv2.SyncEvent}
+
+	syncLog := func(index uint, reserve0, reserve1 *big.Int) *ethtypes.Log {
+		data, err := uniswapv2.SyncEvent.Inputs.NonIndexed().Pack(reserve0, reserve1)
+		require.NoError(t, err)
+		return &ethtypes.Log{Address: address, Topics: []common.Hash{uniswapv2.SyncTopic0}, Data: data, Index: index}
+	}
+
+	receipts := []*ethtypes.Receipt{
+		{Status: ethtypes.ReceiptStatusSuccessful, Logs: []*ethtypes.Log{syncLog(1, big.NewInt(1), big.NewInt(2))}},
+		{Status: ethtypes.ReceiptStatusSuccessful, Logs: []*ethtypes.Log{syncLog(5, big.NewInt(3), big.NewInt(4))}},
+		{Status: ethtypes.ReceiptStatusFailed, Logs: []*ethtypes.Log{syncLog(9, big.NewInt(5), big.NewInt(6))}},
+	}
+	state := pool.stateFromReceipts(receipts)
+	require.NotNil(t, state)
+	require.Equal(t, big.NewInt(3), state.reserve0)
+	require.Equal(t, big.NewInt(4), state.reserve1)
+
+	pool.address = types.WETHAddress
+	require.Nil(t, pool.stateFromReceipts(receipts))
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"abchain_scan/abi"
 	uniswapv2 "abchain_scan/abi/uniswap/v2"
 	uniswapv3 "abchain_scan/abi/uniswap/v3"
+	uniswapv4 "abchain_scan/abi/uniswap/v4"
 	"abchain_scan/cache"
 	"abchain_scan/log"
 	"abchain_scan/metrics"
@@ -21,23 +23,28 @@ type PairService interface {
 	SetPair(pair *types.Pair)
 	GetPairTokens(pair *types.Pair) *types.PairWrap
 	GetPair(pairAddress common.Address, possibleProtocolIds []int) *types.PairWrap
+	GetPoolV4(poolId common.Hash) *types.PairWrap
 }
 
 type pairService struct {
-	ctx            context.Context
-	cache          cache.Cache
-	contractCaller *ContractCaller
-	group          singleflight.Group
+	ctx                 context.Context
+	cache               cache.Cache
+	contractCaller      *ContractCaller
+	tokenCreatorService TokenCreatorService
+	group               singleflight.Group
 }
 
+// NewPairService tokenCreatorService may be nil, the tokens then keep a zero creator and block
 func NewPairService(
 	cache cache.Cache,
 	contractCaller *ContractCaller,
+	tokenCreatorService TokenCreatorService,
 ) PairService {
 	return &pairService{
-		ctx:            context.Background(),
-		cache:          cache,
-		contractCaller: contractCaller,
+		ctx:                 context.Background(),
+		cache:               cache,
+		contractCaller:      contractCaller,
+		tokenCreatorService: tokenCreatorService,
 	}
 }
 
@@ -100,6 +107,13 @@ func (s *pairService) doGetToken(tokenAddress common.Address) (*types.Token, err
 		token.Symbol = symbolRes.symbol
 	}
 
+	// older tokens are searched by the token creator service once committed
+	if s.tokenCreatorService != nil {
+		if creation, ok := s.tokenCreatorService.GetRecentCreation(tokenAddress); ok {
+			creation.apply(token)
+		}
+	}
+
 	if decimalsRes.err != nil {
 		token.Filtered = true
 		return token, decimalsRes.err
@@ -114,6 +128,10 @@ func (s *pairService) doGetToken(tokenAddress common.Address) (*types.Token, err
 }
 
 func (s *pairService) getToken(tokenAddress common.Address) (*types.Token, error, bool) {
+	if types.IsNativeToken(tokenAddress) {
+		return types.NewNativeToken(), nil, true
+	}
+
 	cacheToken, ok := s.cache.GetToken(tokenAddress)
 	if ok {
 		return cacheToken, nil, true
@@ -243,6 +261,80 @@ func (s *pairService) GetPair(pairAddress common.Address, possibleProtocolIds []
 	return s.getPair(pairAddress, possibleProtocolIds)
 }
 
+/*
+GetPoolV4
+uniswap v4 pools have no contract to ask for tokens, a pool missed at Initialize is
+recovered from the pool key the PositionManager stored, checked against the PoolId
+*/
+func (s *pairService) GetPoolV4(poolId common.Hash) *types.PairWrap {
+	pairAddress := types.V4PairAddress(poolId)
+	cachePair, ok := s.cache.GetPair(pairAddress)
+	if ok {
+		return &types.PairWrap{
+			Pair: cachePair,
+		}
+	}
+
+	doResult, _, _ := s.group.Do(pairAddress.String()+"gp", func() (interface{}, error) {
+		pair := s.doGetPoolV4(poolId)
+		if pair.Filtered {
+			s.SetPair(pair)
+			return &types.PairWrap{
+				Pair:      pair,
+				NewPair:   false,
+				NewToken0: false,
+				NewToken1: false,
+			}, nil
+		}
+
+		return s.GetPairTokens(pair), nil
+	})
+
+	return doResult.(*types.PairWrap)
+}
+
+func (s *pairService) doGetPoolV4(poolId common.Hash) *types.Pair {
+	pair := &types.Pair{
+		Address:    types.V4PairAddress(poolId),
+		PoolId:     poolId,
+		ProtocolId: types.ProtocolIdUniswapV4,
+	}
+
+	now := time.Now()
+	poolKey, err := s.contractCaller.CallPoolKey(poolId)
+	if err != nil {
+		log.Logger.Info("Err: CallPoolKey err, this pool will filtered",
+			zap.Error(err),
+			zap.String("pool id", poolId.String()),
+		)
+		pair.Filtered = true
+		pair.FilterCode = types.FilterCodeVerifyFailed
+		metrics.VerifyPairTotal.WithLabelValues("failed").Inc()
+		return pair
+	}
+	metrics.GetPairDurationMs.Observe(float64(time.Since(now).Milliseconds()))
+
+	poolIdComputed, err := uniswapv4.PoolId(poolKey.Currency0, poolKey.Currency1, poolKey.Fee, poolKey.TickSpacing, poolKey.Hooks)
+	if err != nil || poolIdComputed != poolId {
+		pair.Filtered = true
+		pair.FilterCode = types.FilterCodeVerifyFailed
+		metrics.VerifyPairTotal.WithLabelValues("failed").Inc()
+		return pair
+	}
+	metrics.VerifyPairTotal.WithLabelValues("success").Inc()
+	metrics.VerifyPairOkByProtocol.WithLabelValues("uniswap_v4").Inc()
+
+	pair.Token0Core = &types.TokenCore{
+		Address: poolKey.Currency0,
+	}
+	pair.Token1Core = &types.TokenCore{
+		Address: poolKey.Currency1,
+	}
+	pair.Hooks = poolKey.Hooks
+	pair.FilterByToken0AndToken1()
+	return pair
+}
+
 func (s *pairService) doGetPair(pairAddress common.Address) *types.Pair {
 	pair := &types.Pair{
 		Address: pairAddress,
@@ -304,7 +396,18 @@ func (s *pairService) doGetPair(pairAddress common.Address) *types.Pair {
 	return pair
 }
 
-func (s *pairService) verifyPairV2(pairFactoryAddress common.Address, pair *types.Pair) bool {
+/*
+verifyPairV2
+with the init code hash the pair address is derived offline, otherwise the factory is asked for it
+*/
+func (s *pairService) verifyPairV2(dex *abi.Dex, pairFactoryAddress common.Address, pair *types.Pair) bool {
+	if dex.HasInitCodeHash() {
+		metrics.VerifyPairByMethod.WithLabelValues("create2").Inc()
+		pairAddressComputed := uniswapv2.PairAddress(pairFactoryAddress, dex.InitCodeHash, pair.Token0Core.Address, pair.Token1Core.Address)
+		return types.IsSameAddress(pairAddressComputed, pair.Address)
+	}
+
+	metrics.VerifyPairByMethod.WithLabelValues("contract_call").Inc()
 	pairAddressQueried, err := s.contractCaller.CallGetPair(&pairFactoryAddress, &pair.Token0Core.Address, &pair.Token1Core.Address)
 	if err != nil {
 		return false
@@ -312,7 +415,31 @@ func (s *pairService) verifyPairV2(pairFactoryAddress common.Address, pair *type
 	return types.IsSameAddress(pairAddressQueried, pair.Address)
 }
 
-func (s *pairService) verifyPairV3(pairFactoryAddress common.Address, pair *types.Pair) bool {
+/*
+verifyPairV3
+with the init code hash the pool address is derived offline for the common fee tiers,
+the pool is only asked for its fee when none of them matches
+*/
+func (s *pairService) verifyPairV3(dex *abi.Dex, pairFactoryAddress common.Address, pair *types.Pair) bool {
+	if dex.HasInitCodeHash() {
+		metrics.VerifyPairByMethod.WithLabelValues("create2").Inc()
+		deployer := dex.Deployer(pairFactoryAddress)
+		for _, fee := range uniswapv3.FeeTiers {
+			poolAddressComputed := uniswapv3.PoolAddress(deployer, dex.InitCodeHash, pair.Token0Core.Address, pair.Token1Core.Address, fee)
+			if types.IsSameAddress(poolAddressComputed, pair.Address) {
+				return true
+			}
+		}
+
+		fee, callFeeErr := s.contractCaller.CallFee(&pair.Address)
+		if callFeeErr != nil {
+			return false
+		}
+		poolAddressComputed := uniswapv3.PoolAddress(deployer, dex.InitCodeHash, pair.Token0Core.Address, pair.Token1Core.Address, fee)
+		return types.IsSameAddress(poolAddressComputed, pair.Address)
+	}
+
+	metrics.VerifyPairByMethod.WithLabelValues("contract_call").Inc()
 	fee, callFeeErr := s.contractCaller.CallFee(&pair.Address)
 	if callFeeErr != nil {
 		return false
@@ -326,6 +453,45 @@ func (s *pairService) verifyPairV3(pairFactoryAddress common.Address, pair *type
 	return types.IsSameAddress(pairAddressQueried, pair.Address)
 }
 
+func (s *pairService) verifyPairAerodrome(pairFactoryAddress common.Address, pair *types.Pair) bool {
+	stable, callStableErr := s.contractCaller.CallStable(&pair.Address)
+	if callStableErr != nil {
+		return false
+	}
+
+	pairAddressQueried, err := s.contractCaller.CallGetPoolAerodrome(&pairFactoryAddress, &pair.Token0Core.Address, &pair.Token1Core.Address, stable)
+	if err != nil {
+		return false
+	}
+
+	if !types.IsSameAddress(pairAddressQueried, pair.Address) {
+		return false
+	}
+	pair.Stable = stable
+	return true
+}
+
+// verifyPairByDex the pair must come out of one of the factories of the dex
+func (s *pairService) verifyPairByDex(dex *abi.Dex, pair *types.Pair) bool {
+	for _, factory := range dex.Factories {
+		switch dex.Family {
+		case abi.FamilyUniswapV2:
+			if s.verifyPairV2(dex, factory, pair) {
+				return true
+			}
+		case abi.FamilyUniswapV3:
+			if s.verifyPairV3(dex, factory, pair) {
+				return true
+			}
+		case abi.FamilySolidly:
+			if s.verifyPairAerodrome(factory, pair) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (s *pairService) verifyPair(pair *types.Pair, possibleProtocolIds []int) bool {
 	now := time.Now()
 	defer func() {
@@ -334,22 +500,15 @@ func (s *pairService) verifyPair(pair *types.Pair, possibleProtocolIds []int) bo
 	}()
 
 	for _, protocolId := range possibleProtocolIds {
-		switch protocolId {
-		case types.ProtocolIdNewSwap:
-			if s.verifyPairV2(uniswapv2.FactoryAddress, pair) {
-				pair.ProtocolId = protocolId
-				metrics.VerifyPairTotal.WithLabelValues("success").Inc()
-				metrics.VerifyPairOkByProtocol.WithLabelValues("uniswap_v2").Inc()
-				return true
-			}
-
-		case types.ProtocolIdUniswapV3:
-			if s.verifyPairV3(uniswapv3.FactoryAddress, pair) {
-				pair.ProtocolId = protocolId
-				metrics.VerifyPairTotal.WithLabelValues("success").Inc()
-				metrics.VerifyPairOkByProtocol.WithLabelValues("uniswap_v3").Inc()
-				return true
-			}
+		dex, ok := abi.ProtocolId2Dex[protocolId]
+		if !ok {
+			continue
+		}
+		if s.verifyPairByDex(dex, pair) {
+			pair.ProtocolId = protocolId
+			metrics.VerifyPairTotal.WithLabelValues("success").Inc()
+			metrics.VerifyPairOkByProtocol.WithLabelValues(dex.MetricLabel).Inc()
+			return true
 		}
 	}
 
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"abchain_scan/abi"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -233,3 +236,25 @@ func TestPairService_GetPair_TokensReversed(t *testing.T) {
 	t.Log(expectPair)
 	t.Log(pw.Pair)
 }
+
+func TestPairService_VerifyPairByDex_Create2(t *testing.T) {
+	// no contract caller, the pairs are verified from the init code hash only
+	s := &pairService{}
+	weth := common.HexToAddress("0x4200000000000000000000000000000000000006")
+	pair := func(address, token0, token1 common.Address) *types.Pair {
+		return &types.Pair{
+			Address:    address,
+			Token0Core: &types.TokenCore{Address: token0},
+			Token1Core: &types.TokenCore{Address: token1},
+		}
+	}
+
+	v2 := abi.ProtocolId2Dex[types.ProtocolIdPancakeV2]
+	v2Token := common.HexToAddress("0x357f9404356970F6B1C7208cf966abE3e505BA60")
+	require.True(t, s.verifyPairByDex(v2, pair(common.HexToAddress("0xabd5Ba8F0945d89E48077947037455bD40f475FC"), v2Token, weth)))
+	require.False(t, s.verifyPairByDex(v2, pair(common.HexToAddress("0xeD293D73563595E40c3354860721403f9E015CE4"), v2Token, weth)))
+
+	v3 := abi.ProtocolId2Dex[types.ProtocolIdPancakeV3]
+	v3Token := common.HexToAddress("0x858A6594f86fafb10dC0dEdC588C7CBb8E795129")
+	require.True(t, s.verifyPairByDex(v3, pair(common.HexToAddress("0x5845A51630AFab7C68556CF57a7b6827Bd94d434"), weth, v3Token)))
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/metrics"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"sort"
+	"sync"
+)
+
+// blocks between two prunes of the stale pools
+const priceGraphPruneInterval = 1000
+
+type PriceGraphService interface {
+	types.TokenPriceGraph
+	// Rollback puts back the pools updated from height on, the blocks above the common ancestor of a reorg
+	Rollback(height uint64)
+}
+
+type graphPool struct {
+	token0   common.Address
+	token1   common.Address
+	reserve0 decimal.Decimal
+	reserve1 decimal.Decimal
+	stable   bool // solidly stable pool, priced on its curve instead of the reserve ratio
+	height   uint64
+}
+
+// graphPoolUndo the pool before a block updated it, nil when the graph had none
+type graphPoolUndo struct {
+	key      common.Hash
+	previous *graphPool
+}
+
+type graphPrice struct {
+	price decimal.Decimal
+	pool  common.Hash
+	hops  int
+}
+
+/*
+priceGraphService
+tokens are the nodes and pools the edges, a token takes its price from its most liquid pool to a token already priced,
+quote tokens are priced by the quote token prices of the block, only the tokens of the pools updated in a block are priced again,
+the graph lives in memory: it is empty after a restart, the pools updated by the last undoDepth blocks can be rolled back on a reorg
+*/
+type priceGraphService struct {
+	mu              sync.RWMutex
+	maxHops         int
+	minLiquidityUsd decimal.Decimal
+	staleBlocks     uint64
+	undoDepth       uint64
+
+	quoteTokenPrices types.TokenPrices
+	pools            map[common.Hash]*graphPool
+	tokenPools       map[common.Address]map[common.Hash]struct{}
+	prices           map[common.Address]*graphPrice
+	undos            map[uint64][]*graphPoolUndo
+}
+
+func NewPriceGraphService(conf *config.PriceGraphConf) PriceGraphService {
+	return &priceGraphService{
+		maxHops:         conf.MaxHops,
+		minLiquidityUsd: decimal.NewFromFloat(conf.MinLiquidityUsd),
+		staleBlocks:     conf.StaleBlocks,
+		undoDepth:       config.G.BlockGetter.ReorgDepth,
+		pools:           make(map[common.Hash]*graphPool),
+		tokenPools:      make(map[common.Address]map[common.Hash]struct{}),
+		prices:          make(map[common.Address]*graphPrice),
+		undos:           make(map[uint64][]*graphPoolUndo),
+	}
+}
+
+// graphPoolKey v4 pools share the PoolManager address and are told apart by their pool id
+func graphPoolKey(address common.Address, poolId common.Hash) common.Hash {
+	if poolId != (common.Hash{}) {
+		return poolId
+	}
+	return common.BytesToHash(address.Bytes())
+}
+
+func (s *priceGraphService) setPool(key common.Hash, pool *graphPool) {
+	if s.undoDepth > 0 {
+		s.undos[pool.height] = append(s.undos[pool.height], &graphPoolUndo{key: key, previous: s.pools[key]})
+	}
+	s.linkPool(key, pool)
+}
+
+func (s *priceGraphService) linkPool(key common.Hash, pool *graphPool) {
+	s.pools[key] = pool
+	for _, token := range []common.Address{pool.token0, pool.token1} {
+		if s.tokenPools[token] == nil {
+			s.tokenPools[token] = make(map[common.Hash]struct{})
+		}
+		s.tokenPools[token][key] = struct{}{}
+	}
+}
+
+func (s *priceGraphService) Update(height uint64, quoteTokenPrices types.TokenPrices, poolUpdates []*types.PoolUpdate, poolUpdatesV3 []*types.PoolUpdateV3) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quoteTokenPrices = quoteTokenPrices
+	touched := make(map[common.Address]struct{})
+	for _, pu := range poolUpdates {
+		s.setPool(graphPoolKey(pu.Address, common.Hash{}), &graphPool{
+			token0:   pu.Token0Address,
+			token1:   pu.Token1Address,
+			reserve0: pu.Token0Amount,
+			reserve1: pu.Token1Amount,
+			stable:   pu.Stable,
+			height:   height,
+		})
+		touched[pu.Token0Address] = struct{}{}
+		touched[pu.Token1Address] = struct{}{}
+	}
+	for _, pu := range poolUpdatesV3 {
+		s.setPool(graphPoolKey(pu.Address, pu.PoolId), &graphPool{
+			token0:   pu.Token0Address,
+			token1:   pu.Token1Address,
+			reserve0: pu.Token0Reserve,
+			reserve1: pu.Token1Reserve,
+			height:   height,
+		})
+		touched[pu.Token0Address] = struct{}{}
+		touched[pu.Token1Address] = struct{}{}
+	}
+
+	s.repriceTouched(touched)
+
+	if height >= s.undoDepth {
+		delete(s.undos, height-s.undoDepth)
+	}
+	if height%priceGraphPruneInterval == 0 {
+		s.prune(height)
+	}
+	metrics.PriceGraphPools.Set(float64(len(s.pools)))
+	metrics.PriceGraphTokens.Set(float64(len(s.prices)))
+}
+
+func (s *priceGraphService) repriceTouched(touched map[common.Address]struct{}) {
+	// a price found in one pass can price another touched token in the next
+	for pass := 0; pass < s.maxHops; pass++ {
+		for token := range touched {
+			if types.IsBaseToken(token) {
+				continue
+			}
+			s.reprice(token)
+		}
+	}
+}
+
+func (s *priceGraphService) removePool(key common.Hash) {
+	pool, ok := s.pools[key]
+	if !ok {
+		return
+	}
+	delete(s.pools, key)
+	for _, token := range []common.Address{pool.token0, pool.token1} {
+		delete(s.tokenPools[token], key)
+		if len(s.tokenPools[token]) == 0 {
+			delete(s.tokenPools, token)
+		}
+	}
+}
+
+/*
+Rollback
+undoes the updates of the blocks from height on in reverse order, a pool pruned since
+comes back with its state before the orphaned blocks, the quote token prices stay until the next update
+*/
+func (s *priceGraphService) Rollback(height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	heights := make([]uint64, 0, len(s.undos))
+	for h := range s.undos {
+		if h >= height {
+			heights = append(heights, h)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	touched := make(map[common.Address]struct{})
+	for i := len(heights) - 1; i >= 0; i-- {
+		undos := s.undos[heights[i]]
+		for j := len(undos) - 1; j >= 0; j-- {
+			undo := undos[j]
+			if pool, ok := s.pools[undo.key]; ok {
+				touched[pool.token0] = struct{}{}
+				touched[pool.token1] = struct{}{}
+			}
+			s.removePool(undo.key)
+			if undo.previous != nil {
+				s.linkPool(undo.key, undo.previous)
+				touched[undo.previous.token0] = struct{}{}
+				touched[undo.previous.token1] = struct{}{}
+			}
+		}
+		delete(s.undos, heights[i])
+	}
+	s.repriceTouched(touched)
+
+	metrics.PriceGraphPools.Set(float64(len(s.pools)))
+	metrics.PriceGraphTokens.Set(float64(len(s.prices)))
+}
+
+func (s *priceGraphService) getPrice(token common.Address) (*graphPrice, bool) {
+	if types.IsBaseToken(token) {
+		price, ok := s.quoteTokenPrices[token]
+		return &graphPrice{price: price}, ok && price.Sign() > 0
+	}
+	price, ok := s.prices[token]
+	return price, ok
+}
+
+/*
+reprice
+the pool with the most usd on the side of the priced token wins, a token is never priced
+through the pool its counterpart is priced through, two tokens would only price each other
+*/
+func (s *priceGraphService) reprice(token common.Address) {
+	var best *graphPrice
+	bestLiquidity := decimal.Zero
+	for key := range s.tokenPools[token] {
+		pool := s.pools[key]
+		reserve, otherReserve, other := pool.reserve0, pool.reserve1, pool.token1
+		if pool.token1 == token {
+			reserve, otherReserve, other = pool.reserve1, pool.reserve0, pool.token0
+		}
+		if reserve.Sign() <= 0 || otherReserve.Sign() <= 0 {
+			continue
+		}
+
+		otherPrice, ok := s.getPrice(other)
+		if !ok || otherPrice.hops >= s.maxHops || otherPrice.pool == key {
+			continue
+		}
+
+		liquidity := otherReserve.Mul(otherPrice.price).Mul(decimal.NewFromInt(2))
+		if liquidity.LessThan(s.minLiquidityUsd) || liquidity.LessThanOrEqual(bestLiquidity) {
+			continue
+		}
+		bestLiquidity = liquidity
+		best = &graphPrice{
+			price: types.ReservePrice(reserve, otherReserve, pool.stable).Mul(otherPrice.price),
+			pool:  key,
+			hops:  otherPrice.hops + 1,
+		}
+	}
+
+	if best == nil {
+		delete(s.prices, token)
+		return
+	}
+	s.prices[token] = best
+}
+
+func (s *priceGraphService) prune(height uint64) {
+	for key, pool := range s.pools {
+		if pool.height+s.staleBlocks > height {
+			continue
+		}
+		s.removePool(key)
+	}
+	for token, price := range s.prices {
+		if _, ok := s.pools[price.pool]; !ok {
+			delete(s.prices, token)
+		}
+	}
+}
+
+func (s *priceGraphService) GetTokenPrice(token common.Address) (decimal.Decimal, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	price, ok := s.getPrice(token)
+	if !ok {
+		return decimal.Zero, false
+	}
+	return price.price, true
+}
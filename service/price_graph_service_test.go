@@ -0,0 +1,117 @@
+package service
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPriceGraphService_Update(t *testing.T) {
+	tokenA := common.HexToAddress("0xa1")
+	tokenB := common.HexToAddress("0xb1")
+	tokenC := common.HexToAddress("0xc1")
+	s := NewPriceGraphService(&config.PriceGraphConf{Enabled: true, MaxHops: 2, MinLiquidityUsd: 1000, StaleBlocks: 100})
+	quotePrices := types.TokenPrices{types.WETHAddress: decimal.NewFromInt(2000), types.USDCAddress: decimal.NewFromInt(1)}
+
+	// A/WETH: 1 A = 0.01 WETH = 20 usd, B/A: 1 B = 2 A = 40 usd, C/B two hops away from B
+	s.Update(1, quotePrices, []*types.PoolUpdate{
+		{Address: common.HexToAddress("0x01"), Token0Address: tokenA, Token1Address: types.WETHAddress, Token0Amount: decimal.NewFromInt(1000), Token1Amount: decimal.NewFromInt(10)},
+		{Address: common.HexToAddress("0x02"), Token0Address: tokenB, Token1Address: tokenA, Token0Amount: decimal.NewFromInt(500), Token1Amount: decimal.NewFromInt(1000)},
+		{Address: common.HexToAddress("0x03"), Token0Address: tokenC, Token1Address: tokenB, Token0Amount: decimal.NewFromInt(100), Token1Amount: decimal.NewFromInt(100)},
+	}, nil)
+
+	price, ok := s.GetTokenPrice(tokenA)
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromInt(20)), price.String())
+
+	price, ok = s.GetTokenPrice(tokenB)
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromInt(40)), price.String())
+
+	_, ok = s.GetTokenPrice(tokenC)
+	require.False(t, ok, "beyond max hops")
+
+	price, ok = s.GetTokenPrice(types.WETHAddress)
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromInt(2000)))
+
+	// a more liquid v3 pool takes over the price of A
+	s.Update(2, quotePrices, nil, []*types.PoolUpdateV3{
+		{Address: common.HexToAddress("0x04"), Token0Address: tokenA, Token1Address: types.USDCAddress, Token0Reserve: decimal.NewFromInt(10000), Token1Reserve: decimal.NewFromInt(250000)},
+	})
+	price, ok = s.GetTokenPrice(tokenA)
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromInt(25)), price.String())
+
+	// below the min liquidity
+	s.Update(3, quotePrices, []*types.PoolUpdate{
+		{Address: common.HexToAddress("0x05"), Token0Address: tokenC, Token1Address: types.USDCAddress, Token0Amount: decimal.NewFromInt(100), Token1Amount: decimal.NewFromInt(100)},
+	}, nil)
+	_, ok = s.GetTokenPrice(tokenC)
+	require.False(t, ok)
+}
+
+func TestPriceGraphService_Prune(t *testing.T) {
+	tokenA := common.HexToAddress("0xa1")
+	s := NewPriceGraphService(&config.PriceGraphConf{Enabled: true, MaxHops: 2, MinLiquidityUsd: 0, StaleBlocks: 10}).(*priceGraphService)
+
+	s.Update(1, types.TokenPrices{types.USDCAddress: decimal.NewFromInt(1)}, []*types.PoolUpdate{
+		{Address: common.HexToAddress("0x01"), Token0Address: tokenA, Token1Address: types.USDCAddress, Token0Amount: decimal.NewFromInt(10), Token1Amount: decimal.NewFromInt(10)},
+	}, nil)
+	_, ok := s.GetTokenPrice(tokenA)
+	require.True(t, ok)
+
+	s.Update(priceGraphPruneInterval, types.TokenPrices{types.USDCAddress: decimal.NewFromInt(1)}, nil, nil)
+	_, ok = s.GetTokenPrice(tokenA)
+	require.False(t, ok)
+	require.Empty(t, s.pools)
+	require.Empty(t, s.tokenPools)
+}
+
+func TestPriceGraphService_StablePool(t *testing.T) {
+	tokenA := common.HexToAddress("0xa1")
+	s := NewPriceGraphService(&config.PriceGraphConf{Enabled: true, MaxHops: 2, MinLiquidityUsd: 0, StaleBlocks: 100})
+
+	// a stable pool off balance still prices A near the peg, the reserve ratio would give 1.25
+	s.Update(1, types.TokenPrices{types.USDCAddress: decimal.NewFromInt(1)}, []*types.PoolUpdate{
+		{Address: common.HexToAddress("0x01"), Token0Address: tokenA, Token1Address: types.USDCAddress, Token0Amount: decimal.NewFromInt(1000), Token1Amount: decimal.NewFromInt(1250), Stable: true},
+	}, nil)
+	price, ok := s.GetTokenPrice(tokenA)
+	require.True(t, ok)
+	require.True(t, price.GreaterThan(decimal.NewFromInt(1)), price.String())
+	require.True(t, price.LessThan(decimal.NewFromFloat(1.01)), price.String())
+}
+
+func TestPriceGraphService_Rollback(t *testing.T) {
+	tokenA := common.HexToAddress("0xa1")
+	tokenB := common.HexToAddress("0xb1")
+	s := NewPriceGraphService(&config.PriceGraphConf{Enabled: true, MaxHops: 2, MinLiquidityUsd: 0, StaleBlocks: 100}).(*priceGraphService)
+	quotePrices := types.TokenPrices{types.USDCAddress: decimal.NewFromInt(1)}
+
+	s.Update(1, quotePrices, []*types.PoolUpdate{
+		{Address: common.HexToAddress("0x01"), Token0Address: tokenA, Token1Address: types.USDCAddress, Token0Amount: decimal.NewFromInt(10), Token1Amount: decimal.NewFromInt(20)},
+	}, nil)
+	// the orphaned blocks move A and bring a new pool for B
+	s.Update(2, quotePrices, []*types.PoolUpdate{
+		{Address: common.HexToAddress("0x01"), Token0Address: tokenA, Token1Address: types.USDCAddress, Token0Amount: decimal.NewFromInt(10), Token1Amount: decimal.NewFromInt(50)},
+	}, nil)
+	s.Update(3, quotePrices, []*types.PoolUpdate{
+		{Address: common.HexToAddress("0x02"), Token0Address: tokenB, Token1Address: types.USDCAddress, Token0Amount: decimal.NewFromInt(10), Token1Amount: decimal.NewFromInt(30)},
+	}, nil)
+	price, ok := s.GetTokenPrice(tokenA)
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromInt(5)), price.String())
+
+	s.Rollback(2)
+	price, ok = s.GetTokenPrice(tokenA)
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromInt(2)), price.String())
+	_, ok = s.GetTokenPrice(tokenB)
+	require.False(t, ok)
+	require.Len(t, s.pools, 1)
+	require.NotContains(t, s.tokenPools, tokenB)
+	require.Len(t, s.undos, 1)
+}
@@ -2,51 +2,93 @@ package service
 
 import (
 	"abchain_scan/cache"
+	"abchain_scan/config"
 	"abchain_scan/log"
 	"abchain_scan/metrics"
+	"abchain_scan/types"
 	"context"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/panjf2000/ants/v2"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"math/big"
+	"sync"
 	"time"
 )
 
+// blocks the pool states are kept for, a block whose parent is still known carries the states of the pools it has no event of
+const priceStateBlocks = 128
+
 type PriceService interface {
 	Start(startBlockNumber uint64)
 	GetNativeTokenPrice(blockNumber *big.Int) (decimal.Decimal, error)
+	GetBlockPrices(pbc *types.ParseBlockContext) (decimal.Decimal, types.TokenPrices, error)
+	// GetPoolStateV3 sqrtPriceX96, liquidity and tick of a v3 pool at the end of the block
+	GetPoolStateV3(pool common.Address, blockNumber *big.Int) (*big.Int, *big.Int, *big.Int, error)
+	// Rollback forgets the pool states and the cached prices of the blocks from height on
+	Rollback(height uint64)
+}
+
+type blockPoolStates struct {
+	height uint64
+	states map[common.Address]*pricePoolState
 }
 
+/*
+priceService
+the native token price is the liquidity weighted price of the configured native/stable pools at the end of the block,
+a pool state is taken from the last event of the pool in the block, else from the parent block, else from an archive call,
+the pool priced quote tokens are read the same way from their own pool
+*/
 type priceService struct {
 	cache          cache.Cache
 	contractCaller *ContractCaller
 	workPoolSize   int
 	workPool       *ants.Pool
 	ethClient      *ethclient.Client
+	pools          []*pricePool
+	quotePools     map[common.Address]*pricePool
+	maxDeviation   float64
+
+	mu          sync.Mutex
+	blockStates map[common.Hash]*blockPoolStates
 }
 
 func NewPriceService(
 	cache cache.Cache,
 	contractCaller *ContractCaller,
 	ethClient *ethclient.Client,
-	poolSize int,
+	conf *config.PriceServiceConf,
 ) PriceService {
 	var workPool *ants.Pool
 	var err error
-	if poolSize > 0 {
-		workPool, err = ants.NewPool(poolSize)
+	if conf.PoolSize > 0 {
+		workPool, err = ants.NewPool(conf.PoolSize)
 		if err != nil {
 			log.Logger.Fatal("ants pool(BlockGetter) init err", zap.Error(err))
 		}
 	}
 
+	pools, err := newPricePools(conf.Pools)
+	if err != nil {
+		log.Logger.Fatal("price pools init err", zap.Error(err))
+	}
+	quotePools, err := newQuotePricePools()
+	if err != nil {
+		log.Logger.Fatal("quote price pools init err", zap.Error(err))
+	}
+
 	return &priceService{
 		cache:          cache,
 		contractCaller: contractCaller,
-		workPoolSize:   poolSize,
+		workPoolSize:   conf.PoolSize,
 		workPool:       workPool,
 		ethClient:      ethClient,
+		pools:          pools,
+		quotePools:     quotePools,
+		maxDeviation:   conf.MaxDeviation,
+		blockStates:    make(map[common.Hash]*blockPoolStates),
 	}
 }
 
@@ -56,6 +98,7 @@ func (ps *priceService) Start(startBlockNumber uint64) {
 	}
 
 	go func() {
+		next := startBlockNumber
 		for {
 			headerBlockNumber, err := ps.ethClient.BlockNumber(context.Background())
 			if err != nil {
@@ -64,40 +107,203 @@ func (ps *priceService) Start(startBlockNumber uint64) {
 				continue
 			}
 
-			for startBlockNumber <= headerBlockNumber {
-				ps.workPool.Submit(func() {
-					ps.GetNativeTokenPrice(big.NewInt(int64(startBlockNumber)))
-					startBlockNumber++
+			if next > headerBlockNumber {
+				time.Sleep(time.Second)
+				continue
+			}
+
+			for ; next <= headerBlockNumber; next++ {
+				blockNumber := new(big.Int).SetUint64(next)
+				_ = ps.workPool.Submit(func() {
+					_, _ = ps.GetNativeTokenPrice(blockNumber)
 				})
 			}
 		}
 	}()
 }
 
+// GetNativeTokenPrice from archive calls only, for blocks not seen by the parser
 func (ps *priceService) GetNativeTokenPrice(blockNumber *big.Int) (decimal.Decimal, error) {
-	// mock
-	return decimal.New(1, 0), nil
-
 	cachePrice, ok := ps.cache.GetPrice(blockNumber)
 	if ok {
 		return cachePrice, nil
 	}
 
-	return ps.getNativeTokenPrice(blockNumber)
+	states := make(map[common.Address]*pricePoolState, len(ps.pools))
+	for _, pool := range ps.pools {
+		state, err := ps.callPoolState(pool, blockNumber)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		states[pool.address] = state
+	}
+
+	return ps.priceFromStates(blockNumber, states)
 }
 
-func (ps *priceService) getNativeTokenPrice(blockNumber *big.Int) (decimal.Decimal, error) {
+/*
+GetBlockPrices
+the native token price and the usd prices of all the quote tokens at the end of the block
+*/
+func (ps *priceService) GetBlockPrices(pbc *types.ParseBlockContext) (decimal.Decimal, types.TokenPrices, error) {
+	blockNumber := pbc.HeightTime.HeightBigInt
+
+	ps.mu.Lock()
+	var parentStates map[common.Address]*pricePoolState
+	if parent, ok := ps.blockStates[pbc.ParentHash]; ok {
+		parentStates = parent.states
+	}
+	ps.mu.Unlock()
+
+	pools := make([]*pricePool, 0, len(ps.pools)+len(ps.quotePools))
+	pools = append(pools, ps.pools...)
+	for _, pool := range ps.quotePools {
+		pools = append(pools, pool)
+	}
+
+	states := make(map[common.Address]*pricePoolState, len(pools))
+	for _, pool := range pools {
+		if _, ok := states[pool.address]; ok {
+			continue
+		}
+		if state := pool.stateFromReceipts(pbc.BlockReceipts); state != nil {
+			metrics.NativePriceSourceTotal.WithLabelValues("event").Inc()
+			states[pool.address] = state
+			continue
+		}
+		// v3 liquidity moved by a Mint or Burn alone is carried stale until the next swap, it only weights the price
+		if state, ok := parentStates[pool.address]; ok {
+			metrics.NativePriceSourceTotal.WithLabelValues("parent").Inc()
+			states[pool.address] = state
+			continue
+		}
+		state, err := ps.callPoolState(pool, blockNumber)
+		if err != nil {
+			return decimal.Zero, nil, err
+		}
+		metrics.NativePriceSourceTotal.WithLabelValues("call").Inc()
+		states[pool.address] = state
+	}
+	ps.recordStates(pbc.BlockHash, pbc.HeightTime.Height, states)
+
+	nativeTokenPrice, err := ps.priceFromStates(blockNumber, states)
+	if err != nil {
+		return decimal.Zero, nil, err
+	}
+	return nativeTokenPrice, ps.quotePrices(nativeTokenPrice, states), nil
+}
+
+// quotePrices a pool priced quote token without a reading is left out, its txs stay without usd
+func (ps *priceService) quotePrices(nativeTokenPrice decimal.Decimal, states map[common.Address]*pricePoolState) types.TokenPrices {
+	prices := make(types.TokenPrices, len(types.QuoteTokens))
+	for _, quoteToken := range types.QuoteTokens {
+		if price, ok := types.QuoteTokenUsdPrice(quoteToken.Address, nativeTokenPrice); ok {
+			prices[quoteToken.Address] = price
+			continue
+		}
+
+		pool, ok := ps.quotePools[quoteToken.Address]
+		if !ok {
+			continue
+		}
+		price, _, ok := pool.reading(states[pool.address])
+		if !ok {
+			continue
+		}
+		// listed after its stable token, already priced
+		stablePrice, ok := prices[common.HexToAddress(quoteToken.Pool.StableToken)]
+		if !ok {
+			continue
+		}
+		prices[quoteToken.Address] = price.Mul(stablePrice)
+	}
+	return prices
+}
+
+// recordStates keyed by block hash, a reorged block never carries its states over to a block of the new chain
+func (ps *priceService) recordStates(blockHash common.Hash, height uint64, states map[common.Address]*pricePoolState) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.blockStates[blockHash] = &blockPoolStates{height: height, states: states}
+	for hash, bs := range ps.blockStates {
+		if bs.height+priceStateBlocks <= height {
+			delete(ps.blockStates, hash)
+		}
+	}
+}
+
+/*
+Rollback
+the states of the orphaned blocks are never a parent of the canonical ones, they are dropped so the
+cached native prices of their heights go with them, the canonical blocks cache theirs again
+*/
+func (ps *priceService) Rollback(height uint64) {
+	ps.mu.Lock()
+	orphaned := make(map[uint64]struct{})
+	for hash, bs := range ps.blockStates {
+		if bs.height >= height {
+			orphaned[bs.height] = struct{}{}
+			delete(ps.blockStates, hash)
+		}
+	}
+	ps.mu.Unlock()
+
+	for h := range orphaned {
+		ps.cache.DelPrice(new(big.Int).SetUint64(h))
+	}
+}
+
+func (ps *priceService) callPoolState(pool *pricePool, blockNumber *big.Int) (*pricePoolState, error) {
 	now := time.Now()
-	reserve0, reserve1, err := ps.contractCaller.GetReservesByBlockNumber(blockNumber)
+	defer func() {
+		metrics.CallContractArchiveDurationMs.Observe(float64(time.Since(now).Milliseconds()))
+	}()
+
+	if pool.isV3 {
+		sqrtPriceX96, liquidity, err := ps.contractCaller.GetSqrtPriceAndLiquidityByBlockNumber(&pool.address, blockNumber)
+		if err != nil {
+			log.Logger.Error("GetSqrtPriceAndLiquidityByBlockNumber err", zap.Error(err), zap.String("pool", pool.address.String()), zap.Uint64("blockNumber", blockNumber.Uint64()))
+			return nil, err
+		}
+		return &pricePoolState{sqrtPriceX96: sqrtPriceX96, liquidity: liquidity}, nil
+	}
+
+	reserve0, reserve1, err := ps.contractCaller.GetReservesByBlockNumber(&pool.address, blockNumber)
 	if err != nil {
-		log.Logger.Error("GetReservesByBlockNumber err", zap.Error(err), zap.Uint64("blockNumber", blockNumber.Uint64()))
-		return decimal.Zero, err
+		log.Logger.Error("GetReservesByBlockNumber err", zap.Error(err), zap.String("pool", pool.address.String()), zap.Uint64("blockNumber", blockNumber.Uint64()))
+		return nil, err
 	}
-	metrics.CallContractArchiveDurationMs.Observe(float64(time.Since(now).Milliseconds()))
+	return &pricePoolState{reserve0: reserve0, reserve1: reserve1}, nil
+}
 
-	// TODO get pair info
-	USDCAmountDivWETHAmount := decimal.NewFromBigInt(reserve1, -6).Div(decimal.NewFromBigInt(reserve0, -18))
-	ps.cache.SetPrice(blockNumber, USDCAmountDivWETHAmount)
+func (ps *priceService) GetPoolStateV3(pool common.Address, blockNumber *big.Int) (*big.Int, *big.Int, *big.Int, error) {
+	now := time.Now()
+	defer func() {
+		metrics.CallContractArchiveDurationMs.Observe(float64(time.Since(now).Milliseconds()))
+	}()
+	return ps.contractCaller.GetPoolStateV3ByBlockNumber(&pool, blockNumber)
+}
+
+func (ps *priceService) priceFromStates(blockNumber *big.Int, states map[common.Address]*pricePoolState) (decimal.Decimal, error) {
+	readings := make([]*poolReading, 0, len(ps.pools))
+	for _, pool := range ps.pools {
+		price, weight, ok := pool.reading(states[pool.address])
+		if !ok {
+			continue
+		}
+		readings = append(readings, &poolReading{pool: pool.address, price: price, weight: weight})
+	}
+
+	price, outliers, err := aggregateReadings(readings, ps.maxDeviation)
+	for _, outlier := range outliers {
+		metrics.NativePriceOutlierTotal.WithLabelValues(outlier.String()).Inc()
+	}
+	if err != nil {
+		log.Logger.Error("native token price err", zap.Error(err), zap.Uint64("blockNumber", blockNumber.Uint64()))
+		return decimal.Zero, err
+	}
 
-	return USDCAmountDivWETHAmount, nil
+	metrics.NativeTokenPrice.Set(price.InexactFloat64())
+	ps.cache.SetPrice(blockNumber, price)
+	return price, nil
 }
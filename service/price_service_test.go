@@ -3,6 +3,7 @@ package service
 import (
 	"abchain_scan/cache"
 	"abchain_scan/config"
+	"abchain_scan/rpc_pool"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"math/big"
 	"testing"
@@ -17,9 +18,9 @@ func TestPriceService_GetBNBPrice(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	cc := NewContractCaller(ethClient, config.G.ContractCaller.Retry.GetRetryParams())
+	cc := NewContractCaller(rpc_pool.NewStaticPool(ethClient), rpc_pool.RoleArchive, config.G.ContractCaller.Retry.GetRetryParams())
 
-	ps := NewPriceService(&c, cc, ethClient, 0)
+	ps := NewPriceService(&c, cc, ethClient, &config.PriceServiceConf{Pools: config.G.PriceService.Pools})
 	price, err := ps.GetNativeTokenPrice(big.NewInt(22466005))
 	if err != nil {
 		t.Fatal(err)
@@ -112,8 +112,28 @@ var (
 		token1:        tokenPEPE,
 		fee:           big.NewInt(10000),
 	}
+	pairPancakeV2 = &TestPair{
+		protocolId: types.ProtocolIdPancakeV2,
+		address:    common.HexToAddress("0xc637ab6D3aB0c55a7812B0b23955bA6E40859447"),
+		token0:     tokenCAKE,
+		token1:     tokenWETH,
+	}
+	pairPancakeV3 = &TestPair{
+		protocolId: types.ProtocolIdPancakeV3,
+		address:    common.HexToAddress("0xB775272E537cc670C65DC852908aD47015244EaF"),
+		token0:     tokenWETH,
+		token1:     tokenUSDC,
+		fee:        big.NewInt(500),
+	}
+	pairAerodrome = &TestPair{
+		protocolId:    types.ProtocolIdAerodrome,
+		address:       common.HexToAddress("0x7f670f78B17dEC44d5Ef68a48740b6f8849cc2e6"),
+		tokenReversed: true,
+		token0:        tokenWETH,
+		token1:        tokenAERO,
+	}
 )
 
 var (
-	possibleProtocolIds = []int{types.ProtocolIdNewSwap, types.ProtocolIdUniswapV3}
+	possibleProtocolIds = []int{types.ProtocolIdNewSwap, types.ProtocolIdUniswapV3, types.ProtocolIdAerodrome, types.ProtocolIdPancakeV2, types.ProtocolIdPancakeV3}
 )
@@ -3,6 +3,7 @@ package service
 import (
 	"abchain_scan/cache"
 	"abchain_scan/config"
+	"abchain_scan/rpc_pool"
 	"context"
 	"github.com/ethereum/go-ethereum/common"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
@@ -30,9 +31,9 @@ func GetTestContext() *TestContext {
 		panic(err)
 	}
 
-	contractCaller := NewContractCaller(ethClient, config.G.ContractCaller.Retry.GetRetryParams())
+	contractCaller := NewContractCaller(rpc_pool.NewStaticPool(ethClient), rpc_pool.RoleCalls, config.G.ContractCaller.Retry.GetRetryParams())
 	cache := cache.NewMockCache()
-	pairService_ := NewPairService(cache, contractCaller)
+	pairService_ := NewPairService(cache, contractCaller, nil)
 
 	return &TestContext{
 		ethClient:      ethClient,
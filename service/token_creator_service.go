@@ -0,0 +1,392 @@
+package service
+
+import (
+	"abchain_scan/cache"
+	"abchain_scan/config"
+	"abchain_scan/log"
+	"abchain_scan/metrics"
+	"abchain_scan/repository/orm"
+	"abchain_scan/rpc_pool"
+	"abchain_scan/types"
+	"context"
+	"errors"
+	"github.com/avast/retry-go/v4"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.uber.org/zap"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var ErrNoCode = errors.New("no code at head")
+
+// wait before a token whose search failed after the retries is queued again
+const tokenCreationRequeueDelay = time.Minute
+
+type TokenCreation struct {
+	Creator     common.Address
+	BlockNumber uint64
+	BlockTime   time.Time
+	TxHash      common.Hash // zero when the creation tx could not be told apart in the block
+}
+
+func (c *TokenCreation) apply(token *types.Token) {
+	token.Creator = c.Creator
+	token.BlockNumber = c.BlockNumber
+	token.BlockTime = c.BlockTime
+	token.CreateTx = c.TxHash
+}
+
+func (c *TokenCreation) ormToken(token common.Address) *orm.Token {
+	ormToken := &orm.Token{
+		Address: token.String(),
+		Creator: c.Creator.String(),
+		Block:   c.BlockNumber,
+		BlockAt: c.BlockTime,
+	}
+	if c.TxHash != (common.Hash{}) {
+		ormToken.CreateTx = c.TxHash.String()
+	}
+	return ormToken
+}
+
+type TokenCreatorService interface {
+	Start()
+	ObserveBlock(pbc *types.ParseBlockContext)
+	GetRecentCreation(token common.Address) (*TokenCreation, bool)
+	GetCreation(token common.Address) (*TokenCreation, error)
+	Resolve(tokens []common.Address)
+}
+
+/*
+tokenCreatorService
+contract creations of the recent blocks come from the receipts in the block stream, older tokens are
+found by searching the first block with code over the archive node, the search gallops back from the
+head first since most new tokens were deployed shortly before their first pool,
+searches run on workers off the parse path and write the token row and the cached token when found,
+a token keeps a zero creator until found, a search failed after the retries is queued again later
+*/
+type tokenCreatorService struct {
+	ctx          context.Context
+	rpcPool      rpc_pool.Pool
+	tokenCache   cache.TokenCache
+	dbService    DBService
+	recentBlocks uint64
+	workers      int
+	queue        chan common.Address
+	retryParams  *config.RetryParams
+
+	mu              sync.Mutex
+	recent          map[common.Address]*TokenCreation
+	heightContracts map[uint64][]common.Address
+	pending         map[common.Address]struct{}
+}
+
+func NewTokenCreatorService(
+	rpcPool rpc_pool.Pool,
+	tokenCache cache.TokenCache,
+	dbService DBService,
+	conf *config.TokenCreatorConf,
+) TokenCreatorService {
+	return &tokenCreatorService{
+		ctx:             context.Background(),
+		rpcPool:         rpcPool,
+		tokenCache:      tokenCache,
+		dbService:       dbService,
+		recentBlocks:    conf.RecentBlocks,
+		workers:         max(conf.Workers, 1),
+		queue:           make(chan common.Address, conf.QueueSize),
+		retryParams:     conf.Retry.GetRetryParams(),
+		recent:          make(map[common.Address]*TokenCreation),
+		heightContracts: make(map[uint64][]common.Address),
+		pending:         make(map[common.Address]struct{}),
+	}
+}
+
+func (s *tokenCreatorService) Start() {
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			for token := range s.queue {
+				s.resolve(token)
+			}
+		}()
+	}
+}
+
+/*
+Resolve
+queues the search of the tokens committed without a creation, a token already queued is skipped
+and a token that does not fit in the queue is dropped with a warning
+*/
+func (s *tokenCreatorService) Resolve(tokens []common.Address) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range tokens {
+		if _, ok := s.pending[token]; ok {
+			continue
+		}
+		select {
+		case s.queue <- token:
+			s.pending[token] = struct{}{}
+		default:
+			metrics.TokenCreationTotal.WithLabelValues("dropped").Inc()
+			log.Logger.Warn("token creation queue full", zap.String("token", token.String()))
+		}
+	}
+}
+
+func (s *tokenCreatorService) resolve(token common.Address) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, token)
+		s.mu.Unlock()
+	}()
+
+	ctx := s.ctx
+	if s.retryParams.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(s.ctx, s.retryParams.Timeout)
+		defer cancel()
+	}
+	creation, err := retry.DoWithData(func() (*TokenCreation, error) {
+		return s.GetCreation(token)
+	}, s.retryParams.Attempts, s.retryParams.Delay, retry.Context(ctx), retry.LastErrorOnly(true), retry.RetryIf(func(err error) bool {
+		return !errors.Is(err, ErrNoCode)
+	}))
+	if errors.Is(err, ErrNoCode) {
+		log.Logger.Warn("token without code", zap.String("token", token.String()))
+		return
+	}
+	if err != nil {
+		log.Logger.Warn("resolve token creation err", zap.String("token", token.String()), zap.Error(err))
+		time.AfterFunc(tokenCreationRequeueDelay, func() {
+			s.Resolve([]common.Address{token})
+		})
+		return
+	}
+
+	err = s.dbService.UpdateTokenCreation(creation.ormToken(token))
+	if err != nil {
+		log.Logger.Error("update token creation err", zap.String("token", token.String()), zap.Error(err))
+		return
+	}
+	if cached, ok := s.tokenCache.GetToken(token); ok {
+		updated := *cached
+		creation.apply(&updated)
+		s.tokenCache.SetToken(&updated)
+	}
+}
+
+/*
+ObserveBlock
+remembers the contracts deployed by the txs of the block, only full receipts carry the contract address
+so nothing is seen in log ingestion mode, contracts deployed by other contracts are never seen
+*/
+func (s *tokenCreatorService) ObserveBlock(pbc *types.ParseBlockContext) {
+	height := pbc.HeightTime.Height
+	contracts := make([]common.Address, 0)
+	creations := make([]*TokenCreation, 0)
+	for _, receipt := range pbc.BlockReceipts {
+		if receipt.Status != ethtypes.ReceiptStatusSuccessful || receipt.ContractAddress == types.ZeroAddress {
+			continue
+		}
+		sender, err := pbc.GetTxSender(receipt.TransactionIndex)
+		if err != nil {
+			continue
+		}
+		contracts = append(contracts, receipt.ContractAddress)
+		creations = append(creations, &TokenCreation{
+			Creator:     sender,
+			BlockNumber: height,
+			BlockTime:   pbc.HeightTime.Time,
+			TxHash:      receipt.TxHash,
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, contract := range contracts {
+		s.recent[contract] = creations[i]
+	}
+	if len(contracts) > 0 {
+		s.heightContracts[height] = contracts
+	}
+	for h, hContracts := range s.heightContracts {
+		if h+s.recentBlocks > height {
+			continue
+		}
+		for _, contract := range hContracts {
+			delete(s.recent, contract)
+		}
+		delete(s.heightContracts, h)
+	}
+}
+
+func (s *tokenCreatorService) GetRecentCreation(token common.Address) (*TokenCreation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creation, ok := s.recent[token]
+	return creation, ok
+}
+
+func (s *tokenCreatorService) GetCreation(token common.Address) (*TokenCreation, error) {
+	if creation, ok := s.GetRecentCreation(token); ok {
+		metrics.TokenCreationTotal.WithLabelValues("recent").Inc()
+		return creation, nil
+	}
+
+	now := time.Now()
+	creation, err := s.searchCreation(token)
+	if err != nil {
+		metrics.TokenCreationTotal.WithLabelValues("failed").Inc()
+		log.Logger.Warn("search token creation err", zap.String("token", token.String()), zap.Error(err))
+		return nil, err
+	}
+
+	metrics.TokenCreationTotal.WithLabelValues("search").Inc()
+	metrics.TokenCreationSearchDurationMs.Observe(float64(time.Since(now).Milliseconds()))
+	return creation, nil
+}
+
+func (s *tokenCreatorService) hasCode(token common.Address, height uint64) (bool, error) {
+	code, err := rpc_pool.Do(s.rpcPool, rpc_pool.RoleArchive, func(client *ethclient.Client) ([]byte, error) {
+		return client.CodeAt(s.ctx, token, new(big.Int).SetUint64(height))
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(code) > 0, nil
+}
+
+/*
+searchCreationBlock
+the first block with code, hi always has code and lo never has, a contract destroyed and
+deployed again at the same address is found at its latest deployment only if it was never empty in between
+*/
+func (s *tokenCreatorService) searchCreationBlock(token common.Address, head uint64) (uint64, error) {
+	has, err := s.hasCode(token, head)
+	if err != nil {
+		return 0, err
+	}
+	if !has {
+		return 0, ErrNoCode
+	}
+
+	hi := head
+	lo := uint64(0)
+	found := false
+	for step := uint64(1); step <= hi; step *= 2 {
+		probe := hi - step
+		has, err = s.hasCode(token, probe)
+		if err != nil {
+			return 0, err
+		}
+		if !has {
+			lo = probe
+			found = true
+			break
+		}
+		hi = probe
+	}
+
+	if !found {
+		// deployed in the genesis
+		has, err = s.hasCode(token, 0)
+		if err != nil {
+			return 0, err
+		}
+		if has {
+			return 0, nil
+		}
+	}
+
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		has, err = s.hasCode(token, mid)
+		if err != nil {
+			return 0, err
+		}
+		if has {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi, nil
+}
+
+/*
+findCreationTx
+a direct deployment has the token as contract address, a deployment by a factory is
+taken as the first tx with a log of the token, its constructor usually mints
+*/
+func findCreationTx(token common.Address, receipts []*ethtypes.Receipt) (common.Hash, bool) {
+	for _, receipt := range receipts {
+		if receipt.ContractAddress == token {
+			return receipt.TxHash, true
+		}
+	}
+	for _, receipt := range receipts {
+		for _, ethLog := range receipt.Logs {
+			if ethLog.Address == token {
+				return receipt.TxHash, true
+			}
+		}
+	}
+	return common.Hash{}, false
+}
+
+func (s *tokenCreatorService) searchCreation(token common.Address) (*TokenCreation, error) {
+	head, err := rpc_pool.Do(s.rpcPool, rpc_pool.RoleArchive, func(client *ethclient.Client) (uint64, error) {
+		return client.BlockNumber(s.ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	height, err := s.searchCreationBlock(token, head)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := rpc_pool.Do(s.rpcPool, rpc_pool.RoleArchive, func(client *ethclient.Client) (*ethtypes.Header, error) {
+		return client.HeaderByNumber(s.ctx, new(big.Int).SetUint64(height))
+	})
+	if err != nil {
+		return nil, err
+	}
+	creation := &TokenCreation{
+		BlockNumber: height,
+		BlockTime:   time.Unix(int64(header.Time), 0).UTC(),
+	}
+
+	receipts, err := rpc_pool.Do(s.rpcPool, rpc_pool.RoleArchive, func(client *ethclient.Client) ([]*ethtypes.Receipt, error) {
+		return client.BlockReceipts(s.ctx, rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(height)))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	txHash, ok := findCreationTx(token, receipts)
+	if !ok {
+		return creation, nil
+	}
+	creation.TxHash = txHash
+
+	var tx *struct {
+		From common.Address `json:"from"`
+	}
+	_, err = rpc_pool.Do(s.rpcPool, rpc_pool.RoleArchive, func(client *ethclient.Client) (struct{}, error) {
+		return struct{}{}, client.Client().CallContext(s.ctx, &tx, "eth_getTransactionByHash", txHash)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		creation.Creator = tx.From
+	}
+	return creation, nil
+}
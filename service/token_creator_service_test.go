@@ -0,0 +1,114 @@
+package service
+
+import (
+	"abchain_scan/cache"
+	"abchain_scan/config"
+	"abchain_scan/repository/orm"
+	"abchain_scan/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newCreationBlock(height uint64, contract, sender common.Address) *types.ParseBlockContext {
+	return &types.ParseBlockContext{
+		HeightTime: &types.BlockHeightTime{Height: height, Time: time.Unix(int64(height), 0)},
+		BlockReceipts: []*ethtypes.Receipt{{
+			Status:          ethtypes.ReceiptStatusSuccessful,
+			ContractAddress: contract,
+			TxHash:          common.BigToHash(common.Big1),
+		}},
+		TransactionsLen: 1,
+		TxSenders:       []*common.Address{&sender},
+	}
+}
+
+func TestTokenCreatorService_ObserveBlock(t *testing.T) {
+	s := NewTokenCreatorService(nil, nil, nil, &config.TokenCreatorConf{Enabled: true, RecentBlocks: 10}).(*tokenCreatorService)
+	token := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	s.ObserveBlock(newCreationBlock(100, token, sender))
+	creation, ok := s.GetRecentCreation(token)
+	require.True(t, ok)
+	require.Equal(t, sender, creation.Creator)
+	require.Equal(t, uint64(100), creation.BlockNumber)
+
+	s.ObserveBlock(&types.ParseBlockContext{HeightTime: &types.BlockHeightTime{Height: 109}})
+	_, ok = s.GetRecentCreation(token)
+	require.True(t, ok, "still within the recent blocks")
+
+	s.ObserveBlock(&types.ParseBlockContext{HeightTime: &types.BlockHeightTime{Height: 110}})
+	_, ok = s.GetRecentCreation(token)
+	require.False(t, ok, "evicted past the recent blocks")
+	require.Empty(t, s.heightContracts)
+}
+
+// tokenCreatorTestDB the creations written to the token rows
+type tokenCreatorTestDB struct {
+	DBService
+	tokens []*orm.Token
+}
+
+func (db *tokenCreatorTestDB) UpdateTokenCreation(token *orm.Token) error {
+	db.tokens = append(db.tokens, token)
+	return nil
+}
+
+func TestTokenCreatorService_Resolve(t *testing.T) {
+	db := &tokenCreatorTestDB{}
+	tokenCache := cache.NewMockCache()
+	s := NewTokenCreatorService(nil, tokenCache, db, &config.TokenCreatorConf{
+		Enabled:      true,
+		RecentBlocks: 10,
+		QueueSize:    1,
+		Retry:        config.RetryConf{Attempts: 1},
+	}).(*tokenCreatorService)
+	token := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	other := common.HexToAddress("0x3333333333333333333333333333333333333333")
+
+	tokenCache.SetToken(&types.Token{Address: token, Symbol: "T"})
+	s.ObserveBlock(newCreationBlock(100, token, sender))
+
+	// a queued token is not queued twice, the queue is full for the other one
+	s.Resolve([]common.Address{token, token, other})
+	require.Len(t, s.queue, 1)
+	require.Len(t, s.pending, 1)
+
+	s.resolve(<-s.queue)
+	require.Empty(t, s.pending)
+	require.Len(t, db.tokens, 1)
+	require.Equal(t, sender.String(), db.tokens[0].Creator)
+	require.Equal(t, uint64(100), db.tokens[0].Block)
+
+	cached, ok := tokenCache.GetToken(token)
+	require.True(t, ok)
+	require.Equal(t, sender, cached.Creator)
+	require.Equal(t, "T", cached.Symbol)
+}
+
+func TestFindCreationTx(t *testing.T) {
+	token := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	deployTx := common.HexToHash("0x01")
+	mintTx := common.HexToHash("0x02")
+
+	txHash, ok := findCreationTx(token, []*ethtypes.Receipt{
+		{TxHash: mintTx, Logs: []*ethtypes.Log{{Address: token}}},
+		{TxHash: deployTx, ContractAddress: token},
+	})
+	require.True(t, ok)
+	require.Equal(t, deployTx, txHash, "a direct deployment wins over a log")
+
+	txHash, ok = findCreationTx(token, []*ethtypes.Receipt{
+		{TxHash: deployTx},
+		{TxHash: mintTx, Logs: []*ethtypes.Log{{Address: token}}},
+	})
+	require.True(t, ok)
+	require.Equal(t, mintTx, txHash, "deployed by a factory")
+
+	_, ok = findCreationTx(token, []*ethtypes.Receipt{{TxHash: deployTx}})
+	require.False(t, ok)
+}
@@ -1,10 +1,12 @@
 package service
 
 import (
+	"abchain_scan/abi/aerodrome"
 	"abchain_scan/abi/bep20"
 	"abchain_scan/abi/ds_token"
 	uniswapv2 "abchain_scan/abi/uniswap/v2"
 	uniswapv3 "abchain_scan/abi/uniswap/v3"
+	uniswapv4 "abchain_scan/abi/uniswap/v4"
 	"abchain_scan/types"
 	"bytes"
 	"errors"
@@ -70,6 +72,18 @@ var (
 		uniswapv3.FactoryAbi,
 	})
 
+	UniswapV4PositionManagerUnpacker = NewUnpacker([]*abi.ABI{
+		uniswapv4.PositionManagerAbi,
+	})
+
+	AerodromePoolUnpacker = NewUnpacker([]*abi.ABI{
+		aerodrome.PoolAbi,
+	})
+
+	AerodromeFactoryUnpacker = NewUnpacker([]*abi.ABI{
+		aerodrome.FactoryAbi,
+	})
+
 	Name2Unpacker = map[string]Unpacker{
 		"name":        TokenUnpacker,
 		"symbol":      TokenUnpacker,
@@ -79,6 +93,7 @@ var (
 		"token1":      UniswapV2PairUnpacker,
 		"getReserves": UniswapV2PairUnpacker,
 		"fee":         UniswapV3PoolUnpacker,
+		"stable":      AerodromePoolUnpacker,
 	}
 )
 
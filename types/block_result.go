@@ -12,9 +12,11 @@ type BlockResult struct {
 	Timestamp        uint64
 	BlockTime        time.Time
 	NativeTokenPrice decimal.Decimal
+	QuoteTokenPrices TokenPrices
 	NewPairs         map[common.Address]*Pair
 	NewTokens        map[common.Address]*Token
 	TxResults        []*TxResult
+	PriceGraph       TokenPriceGraph // nil unless the price graph is enabled
 }
 
 func NewBlockResult(height, Timestamp uint64, nativeTokenPrice decimal.Decimal) *BlockResult {
@@ -50,11 +52,21 @@ func (br *BlockResult) getAllEvents() []Event {
 			events = append(events, txPairEvent.PancakeV2...)
 			events = append(events, txPairEvent.PancakeV3...)
 			events = append(events, txPairEvent.Aerodrome...)
+			events = append(events, txPairEvent.UniswapV4...)
+			events = append(events, txPairEvent.Others...)
 		}
 	}
 	return events
 }
 
+func (br *BlockResult) getBalanceDeltas() []*BalanceDelta {
+	transfers := make([]*Transfer, 0, 100)
+	for _, txResult := range br.TxResults {
+		transfers = append(transfers, txResult.Transfers...)
+	}
+	return balanceDeltasFromTransfers(transfers)
+}
+
 func mergePoolUpdates(poolUpdates []*PoolUpdate) []*PoolUpdate {
 	pairAddress2PoolUpdate := make(map[common.Address]*PoolUpdate)
 	for _, poolUpdate := range poolUpdates {
@@ -74,6 +86,28 @@ func mergePoolUpdates(poolUpdates []*PoolUpdate) []*PoolUpdate {
 	return poolUpdatesMerged
 }
 
+type poolKey struct {
+	address common.Address
+	poolId  common.Hash
+}
+
+// mergePoolUpdatesV3 uniswap v4 pools share the PoolManager address and are merged by pool id
+func mergePoolUpdatesV3(poolUpdates []*PoolUpdateV3) []*PoolUpdateV3 {
+	pairAddress2PoolUpdate := make(map[poolKey]*PoolUpdateV3)
+	for _, poolUpdate := range poolUpdates {
+		key := poolKey{address: poolUpdate.Address, poolId: poolUpdate.PoolId}
+		poolUpdate_, ok := pairAddress2PoolUpdate[key]
+		if !ok || poolUpdate.LogIndex > poolUpdate_.LogIndex {
+			pairAddress2PoolUpdate[key] = poolUpdate
+		}
+	}
+	poolUpdatesMerged := make([]*PoolUpdateV3, 0, len(pairAddress2PoolUpdate))
+	for _, pu := range pairAddress2PoolUpdate {
+		poolUpdatesMerged = append(poolUpdatesMerged, pu)
+	}
+	return poolUpdatesMerged
+}
+
 func mergePoolUpdateParameters(poolUpdateParameters []*PoolUpdateParameter) []*PoolUpdateParameter {
 	pairAddress2PoolUpdateParameter := make(map[common.Address]*PoolUpdateParameter)
 	for _, poolUpdateParameter := range poolUpdateParameters {
@@ -92,6 +126,7 @@ func (br *BlockResult) GetKafkaMessage() *BlockInfo {
 	txs := make([]*orm.Tx, 0, len(events))
 	newPairs := make([]*Pair, 0, 10)
 	poolUpdates := make([]*PoolUpdate, 0, 40)
+	poolUpdatesV3 := make([]*PoolUpdateV3, 0, 40)
 	poolUpdateParameters := make([]*PoolUpdateParameter, 0, 40)
 	for _, event := range events {
 		if event.IsCreatePair() {
@@ -107,6 +142,10 @@ func (br *BlockResult) GetKafkaMessage() *BlockInfo {
 			poolUpdates = append(poolUpdates, event.GetPoolUpdate())
 		}
 
+		if event.CanGetPoolUpdateV3() {
+			poolUpdatesV3 = append(poolUpdatesV3, event.GetPoolUpdateV3())
+		}
+
 		if event.CanGetPoolUpdateParameter() {
 			poolUpdateParameters = append(poolUpdateParameters, event.GetPoolUpdateParameter())
 		}
@@ -128,8 +167,32 @@ func (br *BlockResult) GetKafkaMessage() *BlockInfo {
 	}
 
 	poolUpdatesMerged := mergePoolUpdates(poolUpdates)
+	poolUpdatesV3Merged := mergePoolUpdatesV3(poolUpdatesV3)
 	poolUpdateParametersMerged := mergePoolUpdateParameters(poolUpdateParameters)
 
+	// txs quoted in a pool priced quote token, then the txs of pairs without a quote token
+	for _, tx := range txs {
+		priceTxByTokenPrices(tx, br.QuoteTokenPrices)
+	}
+	if br.PriceGraph != nil {
+		br.PriceGraph.Update(br.Height, br.QuoteTokenPrices, poolUpdatesMerged, poolUpdatesV3Merged)
+		for _, tx := range txs {
+			priceTxByTokenPrices(tx, br.PriceGraph)
+		}
+	}
+
+	// the graph also knows the quote token prices
+	var prices TokenPriceLookup = br.QuoteTokenPrices
+	if br.PriceGraph != nil {
+		prices = br.PriceGraph
+	}
+	for _, pu := range poolUpdatesMerged {
+		pu.LiquidityUsd = poolLiquidityUsd(pu.Token0Address, pu.Token1Address, pu.Token0Amount, pu.Token1Amount, pu.Stable, prices)
+	}
+	for _, pu := range poolUpdatesV3Merged {
+		pu.LiquidityUsd = poolLiquidityUsd(pu.Token0Address, pu.Token1Address, pu.Token0Reserve, pu.Token1Reserve, false, prices)
+	}
+
 	block := &BlockInfo{
 		Height:               br.Height,
 		Timestamp:            br.Timestamp,
@@ -138,7 +201,9 @@ func (br *BlockResult) GetKafkaMessage() *BlockInfo {
 		NewTokens:            ormTokens,
 		NewPairs:             ormPairs,
 		PoolUpdates:          poolUpdatesMerged,
+		PoolUpdatesV3:        poolUpdatesV3Merged,
 		PoolUpdateParameters: poolUpdateParametersMerged,
+		BalanceDeltas:        br.getBalanceDeltas(),
 	}
 
 	return block
@@ -13,6 +13,7 @@ type Event interface {
 	CanGetPair() bool
 	GetPair() *Pair
 	GetPairAddress() common.Address
+	GetPoolId() common.Hash
 	SetPair(pair *Pair)
 	SetMaker(maker common.Address)
 	SetBlockTime(blockTime time.Time)
@@ -23,6 +24,9 @@ type Event interface {
 	CanGetPoolUpdate() bool
 	GetPoolUpdate() *PoolUpdate
 
+	CanGetPoolUpdateV3() bool
+	GetPoolUpdateV3() *PoolUpdateV3
+
 	CanGetPoolUpdateParameter() bool
 	GetPoolUpdateParameter() *PoolUpdateParameter
 
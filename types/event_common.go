@@ -46,6 +46,11 @@ func (e *EventCommon) GetPairAddress() common.Address {
 	return e.Pair.Address
 }
 
+// GetPoolId zero unless the event comes from a uniswap v4 pool
+func (e *EventCommon) GetPoolId() common.Hash {
+	return e.Pair.PoolId
+}
+
 func (e *EventCommon) SetPair(pair *Pair) {
 	e.Pair = pair
 }
@@ -66,6 +71,14 @@ func (e *EventCommon) GetPoolUpdate() *PoolUpdate {
 	return nil
 }
 
+func (e *EventCommon) CanGetPoolUpdateV3() bool {
+	return false
+}
+
+func (e *EventCommon) GetPoolUpdateV3() *PoolUpdateV3 {
+	return nil
+}
+
 func (e *EventCommon) CanGetPoolUpdateParameter() bool {
 	return false
 }
@@ -12,7 +12,10 @@ type BlockInfo struct {
 	NewTokens            []*orm.Token
 	NewPairs             []*orm.Pair
 	PoolUpdates          []*PoolUpdate
+	PoolUpdatesV3        []*PoolUpdateV3
 	PoolUpdateParameters []*PoolUpdateParameter
+	BalanceDeltas        []*BalanceDelta
+	Candles              []*orm.Candle
 }
 
 type BlockInfoOld struct {
@@ -23,3 +26,24 @@ type BlockInfoOld struct {
 	PoolUpdatesV2          []*PoolUpdate
 	PoolUpdateParametersV3 []*PoolUpdateParameter
 }
+
+type RevertInfo struct {
+	Revert bool
+	Height uint64
+	Hash   string
+}
+
+// MainPairChange the main pair of a token moved from Previous, empty for its first main pair
+type MainPairChange struct {
+	Token        string
+	MainPair     string
+	Previous     string
+	LiquidityUsd string
+}
+
+// MainPairChangeInfo sent after the block whose pool states changed the main pairs, or after the revert restoring them
+type MainPairChangeInfo struct {
+	MainPairChanged bool
+	Height          uint64
+	Changes         []*MainPairChange
+}
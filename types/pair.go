@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/shopspring/decimal"
+	"math/big"
 	"time"
 )
 
@@ -79,11 +80,34 @@ type Pair struct {
 	Block            uint64
 	BlockAt          time.Time
 	ProtocolId       int
+	Stable           bool
+	PoolId           common.Hash    // uniswap v4, pools live in the PoolManager and have no address
+	Hooks            common.Address // uniswap v4
 	Filtered         bool
 	FilterCode       int
 	Timestamp        time.Time
 }
 
+/*
+V4PairAddress
+the key of a uniswap v4 pool in caches and maps keyed by pair address
+*/
+func V4PairAddress(poolId common.Hash) common.Address {
+	return common.BytesToAddress(poolId.Bytes())
+}
+
+func (p *Pair) IsV4() bool {
+	return p.PoolId != (common.Hash{})
+}
+
+// AddressString the PoolId for uniswap v4 pools, the pair address otherwise
+func (p *Pair) AddressString() string {
+	if p.IsV4() {
+		return p.PoolId.String()
+	}
+	return p.Address.String()
+}
+
 func (p *Pair) String() string {
 	bytes, _ := json.Marshal(p)
 	return string(bytes)
@@ -126,18 +150,11 @@ func (p *Pair) OrderToken0Token1() bool {
 		return false
 	}
 
-	token0IsBaseToken := IsBaseToken(p.Token0Core.Address)
-	token1IsBaseToken := IsBaseToken(p.Token1Core.Address)
-
-	if token0IsBaseToken && token1IsBaseToken {
-		if IsUSDC(p.Token0Core.Address) {
-			p.swapToken0Token1()
-		}
-	} else if !token0IsBaseToken && !token1IsBaseToken {
-	} else {
-		if token0IsBaseToken {
-			p.swapToken0Token1()
-		}
+	// the quote token listed first becomes token1
+	priority0, token0IsBaseToken := QuotePriority(p.Token0Core.Address)
+	priority1, token1IsBaseToken := QuotePriority(p.Token1Core.Address)
+	if token0IsBaseToken && (!token1IsBaseToken || priority0 < priority1) {
+		p.swapToken0Token1()
 	}
 
 	return p.TokensReversed
@@ -171,6 +188,12 @@ func (p *Pair) Equal(pair *Pair) bool {
 	if p.ProtocolId != pair.ProtocolId {
 		return false
 	}
+	if p.Stable != pair.Stable {
+		return false
+	}
+	if p.PoolId != pair.PoolId {
+		return false
+	}
 	if p.Filtered != pair.Filtered {
 		return false
 	}
@@ -184,7 +207,17 @@ func (p *Pair) IsFiltered() bool {
 	return p.Filtered
 }
 
+// keepNonBasePairs pairs without a base token are kept when their usd comes from the token price graph
+var keepNonBasePairs bool
+
+func SetKeepNonBasePairs(keep bool) {
+	keepNonBasePairs = keep
+}
+
 func (p *Pair) FilterByToken0AndToken1() bool {
+	if keepNonBasePairs {
+		return p.Filtered
+	}
 	if !IsBaseToken(p.Token0Core.Address) && !IsBaseToken(p.Token1Core.Address) {
 		p.Filtered = true
 		p.FilterCode = FilterCodeNoBaseToken
@@ -208,9 +241,9 @@ func getPairName(token0Symbol, token1Symbol string) string {
 }
 
 func (p *Pair) GetOrmPair() *orm.Pair {
-	return &orm.Pair{
+	ormPair := &orm.Pair{
 		Name:     getPairName(p.Token0Core.Symbol, p.Token1Core.Symbol),
-		Address:  p.Address.String(),
+		Address:  p.AddressString(),
 		Token0:   p.Token0Core.Address.String(),
 		Token1:   p.Token1Core.Address.String(),
 		Reserve0: p.Token0InitAmount.Mul(decimal.New(1, int32(p.Token0Core.Decimals))), // for db type is numeric(78)
@@ -219,7 +252,12 @@ func (p *Pair) GetOrmPair() *orm.Pair {
 		Block:    p.Block,
 		BlockAt:  p.BlockAt,
 		Program:  GetProtocolName(p.ProtocolId),
+		Stable:   p.Stable,
+	}
+	if p.IsV4() {
+		ormPair.Hooks = p.Hooks.String()
 	}
+	return ormPair
 }
 
 type PairWrap struct {
@@ -228,3 +266,12 @@ type PairWrap struct {
 	NewToken0 bool
 	NewToken1 bool
 }
+
+// PoolKey uniswap v4 pool key, hashed into the PoolId
+type PoolKey struct {
+	Currency0   common.Address
+	Currency1   common.Address
+	Fee         *big.Int
+	TickSpacing *big.Int
+	Hooks       common.Address
+}
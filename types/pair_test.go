@@ -32,6 +32,9 @@ func TestPair_OrderTokens(t *testing.T) {
 	TokenNonBase := &TokenCore{
 		Address: common.HexToAddress("0x1234567890123456789012345678901234567890"),
 	}
+	TokenNative := &TokenCore{
+		Address: NativeTokenAddress,
+	}
 
 	tests := []struct {
 		name           string
@@ -87,6 +90,18 @@ func TestPair_OrderTokens(t *testing.T) {
 			},
 			TokensReversed: false,
 		},
+		{
+			name: "ETH/NonBase",
+			pair: &Pair{
+				Token0Core: TokenNative,
+				Token1Core: TokenNonBase,
+			},
+			expected: &Pair{
+				Token0Core: TokenNonBase,
+				Token1Core: TokenNative,
+			},
+			TokensReversed: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -143,3 +158,23 @@ func TestPair_MarshalBinary(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, pair.Equal(pair2))
 }
+
+func TestPair_GetOrmPairV4(t *testing.T) {
+	poolId := common.HexToHash("0x21c67e77068de97969ba93d4aab21826d33ca12bb9f565d8496e8fda8a82ca27")
+	hooks := common.HexToAddress("0x0000000000000000000000000000000000004444")
+	token := &TokenCore{Address: common.HexToAddress("0x01"), Symbol: "A", Decimals: 18}
+	pair := &Pair{
+		Address:    V4PairAddress(poolId),
+		PoolId:     poolId,
+		Hooks:      hooks,
+		Token0Core: token,
+		Token1Core: token,
+	}
+
+	ormPair := pair.GetOrmPair()
+	require.Equal(t, poolId.String(), ormPair.Address)
+	require.Equal(t, hooks.String(), ormPair.Hooks)
+
+	pair.PoolId = common.Hash{}
+	require.Empty(t, pair.GetOrmPair().Hooks)
+}
@@ -3,6 +3,7 @@ package types
 import (
 	chainparams "abchain_scan/chain"
 	"abchain_scan/log"
+	"encoding/json"
 	"errors"
 	"github.com/ethereum/go-ethereum/common"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
@@ -14,6 +15,7 @@ import (
 
 var (
 	txIndexOutOfRange = errors.New("txIndex out of range")
+	unknownTxType     = errors.New("unknown tx type, no sender from node")
 )
 
 type BlockHeightTime struct {
@@ -34,19 +36,32 @@ func GetBlockHeightTime(header *ethtypes.Header) *BlockHeightTime {
 
 type ParseBlockContext struct {
 	// input
-	Block            *ethtypes.Block
+	Sequence         uint64
+	BlockHash        common.Hash
+	ParentHash       common.Hash
+	Reorg            *Reorg
+	Block            *ethtypes.Block // nil in the logs ingestion mode, which never fetches the block
 	Transactions     []*ethtypes.Transaction
 	TransactionsLen  uint
 	BlockReceipts    []*ethtypes.Receipt
 	HeightTime       *BlockHeightTime
 	NativeTokenPrice decimal.Decimal
+	QuoteTokenPrices TokenPrices
 	TxSenders        []*common.Address
+	// raw rpc responses, only kept while recording the block archive
+	RawBlock    json.RawMessage
+	RawReceipts json.RawMessage
 	// output
 	BlockResult *BlockResult
 }
 
+/*
+GetSequence
+the block getter sequences blocks by height, after that every emitted block gets
+a monotonic sequence, so canonical blocks re-emitted after a reorg keep moving forward
+*/
 func (c *ParseBlockContext) GetSequence() uint64 {
-	return c.HeightTime.Height
+	return c.Sequence
 }
 
 func (c *ParseBlockContext) GetTxSender(txIndex uint) (common.Address, error) {
@@ -63,6 +78,11 @@ func (c *ParseBlockContext) GetTxSender(txIndex uint) (common.Address, error) {
 		return ZeroAddress, txIndexOutOfRange
 	}
 
+	// nil if go-ethereum can't decode the tx type
+	if c.Transactions[txIndex] == nil {
+		return ZeroAddress, unknownTxType
+	}
+
 	signer := ethtypes.MakeSigner(chainparams.ChainConfig, c.HeightTime.HeightBigInt, c.HeightTime.Timestamp)
 	sender, err := ethtypes.Sender(signer, c.Transactions[txIndex])
 	if err != nil {
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"abchain_scan/chain"
+	"abchain_scan/repository/orm"
 	"abchain_scan/util"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/shopspring/decimal"
@@ -14,6 +16,32 @@ type PoolUpdate struct {
 	Token1Address common.Address
 	Token0Amount  decimal.Decimal
 	Token1Amount  decimal.Decimal
+	Stable        bool // solidly stable pool, the reserves do not trade at their ratio
+	LiquidityUsd  decimal.Decimal
+}
+
+/*
+ReservePrice
+token1 per token0 of a pool from its reserves with the decimals applied, zero without reserves,
+x*y=k pools trade at the reserve ratio, solidly stable pools at the slope of x³y+y³x=k: (3x²y+y³)/(x³+3xy²)
+*/
+func ReservePrice(reserve0, reserve1 decimal.Decimal, stable bool) decimal.Decimal {
+	if reserve0.Sign() <= 0 || reserve1.Sign() <= 0 {
+		return decimal.Zero
+	}
+	if !stable {
+		return reserve1.Div(reserve0)
+	}
+	x, y := reserve0, reserve1
+	three := decimal.NewFromInt(3)
+	numerator := three.Mul(x).Mul(x).Mul(y).Add(y.Mul(y).Mul(y))
+	denominator := x.Mul(x).Mul(x).Add(three.Mul(x).Mul(y).Mul(y))
+	return numerator.Div(denominator)
+}
+
+// Price token1 per token0 at the reserves
+func (u *PoolUpdate) Price() decimal.Decimal {
+	return ReservePrice(u.Token0Amount, u.Token1Amount, u.Stable)
 }
 
 func (u *PoolUpdate) Equal(tx *PoolUpdate) bool {
@@ -41,9 +69,96 @@ func (u *PoolUpdate) Equal(tx *PoolUpdate) bool {
 	return true
 }
 
+/*
+PoolUpdateV3
+state of a concentrated liquidity pool after its last swap or initialize in the block,
+Price is token1 per token0 of the pair and takes TokensReversed into account, Tick stays in the pool's own order,
+the reserves are the virtual reserves of the active liquidity in the pair's order with the decimals applied,
+LiquidityUsd is the usd of these virtual reserves, not of the balances of the pool,
+ProtocolFees0 and ProtocolFees1 are the protocol fees accrued by a pancake v3 pool in the pair's order, only set by its swaps
+*/
+type PoolUpdateV3 struct {
+	Program       string
+	LogIndex      uint
+	Address       common.Address
+	PoolId        common.Hash // uniswap v4
+	Token0Address common.Address
+	Token1Address common.Address
+	SqrtPriceX96  decimal.Decimal
+	Liquidity     decimal.Decimal
+	Tick          int64
+	Price         decimal.Decimal
+	Token0Reserve decimal.Decimal
+	Token1Reserve decimal.Decimal
+	LiquidityUsd  decimal.Decimal
+	ProtocolFees0 decimal.Decimal
+	ProtocolFees1 decimal.Decimal
+}
+
+/*
+poolLiquidityUsd
+the usd of both reserves, twice the priced one when only one token has a price, zero when none has,
+the side without a price of a solidly stable pool is valued at the curve price instead
+*/
+func poolLiquidityUsd(token0, token1 common.Address, reserve0, reserve1 decimal.Decimal, stable bool, prices TokenPriceLookup) decimal.Decimal {
+	price0, ok0 := prices.GetTokenPrice(token0)
+	price1, ok1 := prices.GetTokenPrice(token1)
+	switch {
+	case ok0 && ok1:
+		return reserve0.Mul(price0).Add(reserve1.Mul(price1))
+	case ok0 && stable:
+		return reserve0.Mul(price0).Add(reserve1.Mul(ReservePrice(reserve1, reserve0, true)).Mul(price0))
+	case ok0:
+		return reserve0.Mul(price0).Mul(decimal.NewFromInt(2))
+	case ok1 && stable:
+		return reserve1.Mul(price1).Add(reserve0.Mul(ReservePrice(reserve0, reserve1, true)).Mul(price1))
+	case ok1:
+		return reserve1.Mul(price1).Mul(decimal.NewFromInt(2))
+	}
+	return decimal.Zero
+}
+
+func (u *PoolUpdate) GetOrmPoolState(height uint64) *orm.PoolState {
+	return &orm.PoolState{
+		Address:      u.Address.String(),
+		ChainId:      chain.Id,
+		Program:      u.Program,
+		Token0:       u.Token0Address.String(),
+		Token1:       u.Token1Address.String(),
+		Reserve0:     u.Token0Amount,
+		Reserve1:     u.Token1Amount,
+		Price:        u.Price(),
+		LiquidityUsd: u.LiquidityUsd,
+		Block:        height,
+	}
+}
+
+func (u *PoolUpdateV3) GetOrmPoolState(height uint64) *orm.PoolState {
+	address := u.Address.String()
+	if u.PoolId != (common.Hash{}) {
+		address = u.PoolId.String()
+	}
+	return &orm.PoolState{
+		Address:      address,
+		ChainId:      chain.Id,
+		Program:      u.Program,
+		Token0:       u.Token0Address.String(),
+		Token1:       u.Token1Address.String(),
+		Reserve0:     u.Token0Reserve,
+		Reserve1:     u.Token1Reserve,
+		SqrtPriceX96: u.SqrtPriceX96,
+		Liquidity:    u.Liquidity,
+		Tick:         u.Tick,
+		Price:        u.Price,
+		LiquidityUsd: u.LiquidityUsd,
+		Block:        height,
+	}
+}
+
 type PoolUpdateParameter struct {
 	BlockNumber   uint64
 	PairAddress   common.Address
+	PoolId        common.Hash // uniswap v4
 	Token0Address common.Address
 	Token1Address common.Address
 }
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPoolLiquidityUsd(t *testing.T) {
+	tokenA := common.HexToAddress("0xa1")
+	tokenB := common.HexToAddress("0xb1")
+	prices := TokenPrices{tokenA: decimal.NewFromInt(2), tokenB: decimal.NewFromInt(3)}
+
+	liquidity := poolLiquidityUsd(tokenA, tokenB, decimal.NewFromInt(10), decimal.NewFromInt(5), false, prices)
+	require.True(t, liquidity.Equal(decimal.NewFromInt(35)), liquidity.String())
+
+	// token1 only
+	liquidity = poolLiquidityUsd(common.HexToAddress("0xc1"), tokenB, decimal.NewFromInt(10), decimal.NewFromInt(5), false, prices)
+	require.True(t, liquidity.Equal(decimal.NewFromInt(30)), liquidity.String())
+
+	liquidity = poolLiquidityUsd(common.HexToAddress("0xc1"), common.HexToAddress("0xd1"), decimal.NewFromInt(10), decimal.NewFromInt(5), false, prices)
+	require.True(t, liquidity.IsZero())
+}
+
+func TestReservePrice_Stable(t *testing.T) {
+	require.True(t, ReservePrice(decimal.NewFromInt(1000), decimal.NewFromInt(1000), true).Equal(decimal.NewFromInt(1)))
+	require.True(t, ReservePrice(decimal.NewFromInt(0), decimal.NewFromInt(1000), true).IsZero())
+
+	// the curve is flat around the peg, far from the reserve ratio of 1.1
+	price := ReservePrice(decimal.NewFromInt(1000), decimal.NewFromInt(1100), true)
+	require.True(t, price.GreaterThan(decimal.NewFromInt(1)), price.String())
+	require.True(t, price.LessThan(decimal.NewFromFloat(1.001)), price.String())
+	require.True(t, ReservePrice(decimal.NewFromInt(1000), decimal.NewFromInt(1100), false).Equal(decimal.NewFromFloat(1.1)))
+
+	tokenA := common.HexToAddress("0xa1")
+	liquidity := poolLiquidityUsd(tokenA, common.HexToAddress("0xc1"), decimal.NewFromInt(1000), decimal.NewFromInt(1100), true,
+		TokenPrices{tokenA: decimal.NewFromInt(1)})
+	require.True(t, liquidity.GreaterThan(decimal.NewFromInt(2099)), liquidity.String())
+	require.True(t, liquidity.LessThan(decimal.NewFromInt(2100)), liquidity.String())
+
+	pu := &PoolUpdate{Token0Amount: decimal.NewFromInt(1000), Token1Amount: decimal.NewFromInt(1100), Stable: true}
+	require.True(t, pu.GetOrmPoolState(10).Price.Equal(price))
+}
+
+func TestMergePoolUpdatesV3_V4Pools(t *testing.T) {
+	poolManager := common.HexToAddress("0x01")
+	poolA := common.HexToHash("0x0a")
+	poolB := common.HexToHash("0x0b")
+
+	merged := mergePoolUpdatesV3([]*PoolUpdateV3{
+		{LogIndex: 1, Address: poolManager, PoolId: poolA},
+		{LogIndex: 2, Address: poolManager, PoolId: poolB},
+		{LogIndex: 3, Address: poolManager, PoolId: poolA},
+	})
+	require.Len(t, merged, 2)
+	for _, pu := range merged {
+		if pu.PoolId == poolA {
+			require.Equal(t, uint(3), pu.LogIndex)
+		}
+	}
+
+	state := merged[0].GetOrmPoolState(10)
+	require.Equal(t, merged[0].PoolId.String(), state.Address)
+	require.Equal(t, uint64(10), state.Block)
+}
+
+func TestPoolUpdate_GetOrmPoolState(t *testing.T) {
+	pu := &PoolUpdate{Address: common.HexToAddress("0x01"), Token0Amount: decimal.NewFromInt(4), Token1Amount: decimal.NewFromInt(10)}
+	state := pu.GetOrmPoolState(10)
+	require.Equal(t, pu.Address.String(), state.Address)
+	require.True(t, state.Price.Equal(decimal.NewFromFloat(2.5)), state.Price.String())
+	require.True(t, state.Liquidity.IsZero())
+}
@@ -3,20 +3,39 @@ package types
 const (
 	ProtocolIdNewSwap = iota + 1
 	ProtocolIdUniswapV3
+	ProtocolIdAerodrome
+	ProtocolIdPancakeV2
+	ProtocolIdPancakeV3
+	ProtocolIdUniswapV4
 )
 
 const (
 	ProtocolNameNewSwap   = "NewSwap"
 	ProtocolNameUniswapV3 = "UniswapV3"
+	ProtocolNameAerodrome = "Aerodrome"
+	ProtocolNamePancakeV2 = "PancakeV2"
+	ProtocolNamePancakeV3 = "PancakeV3"
+	ProtocolNameUniswapV4 = "UniswapV4"
 )
 
+// protocolId2Name only written at startup, by the dex registry
+var protocolId2Name = map[int]string{
+	ProtocolIdNewSwap:   ProtocolNameNewSwap,
+	ProtocolIdUniswapV3: ProtocolNameUniswapV3,
+	ProtocolIdAerodrome: ProtocolNameAerodrome,
+	ProtocolIdPancakeV2: ProtocolNamePancakeV2,
+	ProtocolIdPancakeV3: ProtocolNamePancakeV3,
+	ProtocolIdUniswapV4: ProtocolNameUniswapV4,
+}
+
+func RegisterProtocol(protocolId int, name string) {
+	protocolId2Name[protocolId] = name
+}
+
 func GetProtocolName(protocolId int) string {
-	switch protocolId {
-	case ProtocolIdNewSwap:
-		return ProtocolNameNewSwap
-	case ProtocolIdUniswapV3:
-		return ProtocolNameUniswapV3
-	default:
+	name, ok := protocolId2Name[protocolId]
+	if !ok {
 		return "Unknown"
 	}
+	return name
 }
@@ -0,0 +1,147 @@
+package types
+
+import (
+	"abchain_scan/config"
+	"abchain_scan/log"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const (
+	PriceSourceNative = "native"
+	PriceSourceStable = "stable"
+	PriceSourcePool   = "pool"
+)
+
+type QuoteToken struct {
+	Address     common.Address
+	Symbol      string
+	Decimals    int8
+	PriceSource string
+	UsdPeg      decimal.Decimal
+	Pool        *config.PricePoolConf // only for the pool price source
+}
+
+var (
+	QuoteTokens          []*QuoteToken
+	address2QuoteIndex   = map[common.Address]int{}
+	nativeWrapperAddress common.Address
+)
+
+func init() {
+	if err := LoadQuoteTokenConf(config.G.QuoteTokens); err != nil {
+		log.Logger.Fatal("Failed to load quote tokens", zap.Error(err))
+	}
+}
+
+/*
+LoadQuoteTokenConf
+replaces the quote tokens, the order of the config is the priority, a pool priced token
+must be quoted in a token listed before it so the prices of a block resolve in one pass
+*/
+func LoadQuoteTokenConf(confs []*config.QuoteTokenConf) error {
+	if len(confs) == 0 {
+		return fmt.Errorf("no quote token")
+	}
+
+	quoteTokens := make([]*QuoteToken, 0, len(confs))
+	index := make(map[common.Address]int, len(confs))
+	var wrapper common.Address
+	hasWrapper := false
+	for i, conf := range confs {
+		if !common.IsHexAddress(conf.Address) {
+			return fmt.Errorf("quote token %s: invalid address", conf.Address)
+		}
+		address := common.HexToAddress(conf.Address)
+		if _, ok := index[address]; ok {
+			return fmt.Errorf("quote token %s: listed twice", conf.Address)
+		}
+
+		quoteToken := &QuoteToken{
+			Address:     address,
+			Symbol:      conf.Symbol,
+			Decimals:    conf.Decimals,
+			PriceSource: conf.PriceSource,
+		}
+		switch conf.PriceSource {
+		case PriceSourceNative:
+			if !hasWrapper && address != NativeTokenAddress {
+				wrapper = address
+				hasWrapper = true
+			}
+		case PriceSourceStable:
+			quoteToken.UsdPeg = decimal.NewFromInt(1)
+			if conf.UsdPeg != 0 {
+				quoteToken.UsdPeg = decimal.NewFromFloat(conf.UsdPeg)
+			}
+		case PriceSourcePool:
+			if conf.Pool == nil || !common.IsHexAddress(conf.Pool.StableToken) {
+				return fmt.Errorf("quote token %s: pool price source without a pool", conf.Address)
+			}
+			if _, ok := index[common.HexToAddress(conf.Pool.StableToken)]; !ok {
+				return fmt.Errorf("quote token %s: pool quoted in %s, not a quote token listed before", conf.Address, conf.Pool.StableToken)
+			}
+			quoteToken.Pool = conf.Pool
+		default:
+			return fmt.Errorf("quote token %s: unknown price source %s", conf.Address, conf.PriceSource)
+		}
+
+		index[address] = i
+		quoteTokens = append(quoteTokens, quoteToken)
+	}
+	if !hasWrapper {
+		return fmt.Errorf("no native wrapper among the quote tokens")
+	}
+
+	QuoteTokens = quoteTokens
+	address2QuoteIndex = index
+	nativeWrapperAddress = wrapper
+	return nil
+}
+
+// NativeWrapperAddress the first native priced quote token with a contract, WETH
+func NativeWrapperAddress() common.Address {
+	return nativeWrapperAddress
+}
+
+func GetQuoteToken(address common.Address) (*QuoteToken, bool) {
+	i, ok := address2QuoteIndex[address]
+	if !ok {
+		return nil, false
+	}
+	return QuoteTokens[i], true
+}
+
+// QuotePriority the lower the earlier in the config, false for a token that is not a quote token
+func QuotePriority(address common.Address) (int, bool) {
+	i, ok := address2QuoteIndex[address]
+	return i, ok
+}
+
+/*
+QuoteTokenUsdPrice
+the usd price of a native or stable priced quote token, pool priced ones are only known per block
+*/
+func QuoteTokenUsdPrice(address common.Address, nativeTokenPrice decimal.Decimal) (decimal.Decimal, bool) {
+	quoteToken, ok := GetQuoteToken(address)
+	if !ok {
+		return decimal.Zero, false
+	}
+	switch quoteToken.PriceSource {
+	case PriceSourceNative:
+		return nativeTokenPrice, true
+	case PriceSourceStable:
+		return quoteToken.UsdPeg, true
+	}
+	return decimal.Zero, false
+}
+
+// TokenPrices usd prices of tokens at a block
+type TokenPrices map[common.Address]decimal.Decimal
+
+func (p TokenPrices) GetTokenPrice(token common.Address) (decimal.Decimal, bool) {
+	price, ok := p[token]
+	return price, ok
+}
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"abchain_scan/config"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLoadQuoteTokenConf(t *testing.T) {
+	defer func() {
+		require.NoError(t, LoadQuoteTokenConf(config.G.QuoteTokens))
+	}()
+
+	dai := "0x50c5725949A6F0c72E6C4a641F24049A917DB0Cb"
+	gov := "0x00000000000000000000000000000000000000aa"
+	require.NoError(t, LoadQuoteTokenConf([]*config.QuoteTokenConf{
+		{Address: WETH, Symbol: "WETH", Decimals: 18, PriceSource: PriceSourceNative},
+		{Address: dai, Symbol: "DAI", Decimals: 18, PriceSource: PriceSourceStable, UsdPeg: 0.999},
+		{Address: gov, Symbol: "GOV", Decimals: 18, PriceSource: PriceSourcePool, Pool: &config.PricePoolConf{StableToken: WETH}},
+	}))
+
+	require.Equal(t, WETHAddress, NativeWrapperAddress())
+	require.True(t, IsBaseToken(common.HexToAddress(gov)))
+	require.False(t, IsBaseToken(USDCAddress))
+
+	price, ok := QuoteTokenUsdPrice(common.HexToAddress(dai), decimal.NewFromInt(2000))
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromFloat(0.999)))
+	price, ok = QuoteTokenUsdPrice(WETHAddress, decimal.NewFromInt(2000))
+	require.True(t, ok)
+	require.True(t, price.Equal(decimal.NewFromInt(2000)))
+	_, ok = QuoteTokenUsdPrice(common.HexToAddress(gov), decimal.NewFromInt(2000))
+	require.False(t, ok, "pool priced per block")
+
+	// WETH listed first is the quote of a WETH/DAI pair
+	pair := &Pair{
+		Token0Core: &TokenCore{Address: WETHAddress},
+		Token1Core: &TokenCore{Address: common.HexToAddress(dai)},
+	}
+	pair.OrderToken0Token1()
+	require.Equal(t, WETHAddress, pair.Token1Core.Address)
+
+	require.Error(t, LoadQuoteTokenConf(nil))
+	require.Error(t, LoadQuoteTokenConf([]*config.QuoteTokenConf{
+		{Address: USDC, PriceSource: PriceSourceStable},
+	}), "no native wrapper")
+	require.Error(t, LoadQuoteTokenConf([]*config.QuoteTokenConf{
+		{Address: gov, PriceSource: PriceSourcePool, Pool: &config.PricePoolConf{StableToken: WETH}},
+		{Address: WETH, PriceSource: PriceSourceNative},
+	}), "pool quoted in a token listed after it")
+	require.Error(t, LoadQuoteTokenConf([]*config.QuoteTokenConf{
+		{Address: WETH, PriceSource: PriceSourceNative},
+		{Address: WETH, PriceSource: PriceSourceNative},
+	}), "listed twice")
+	require.Error(t, LoadQuoteTokenConf([]*config.QuoteTokenConf{
+		{Address: WETH, PriceSource: "oracle"},
+	}))
+}
@@ -0,0 +1,25 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type OrphanedBlock struct {
+	Height uint64
+	Hash   common.Hash
+}
+
+/*
+Reorg is attached to the first canonical block emitted after a fork is found.
+Every block in OrphanedBlocks was already handed to the parser and must be
+rolled back before this block is parsed.
+*/
+type Reorg struct {
+	AncestorHeight uint64
+	AncestorHash   common.Hash
+	OrphanedBlocks []*OrphanedBlock
+}
+
+func (r *Reorg) Depth() int {
+	return len(r.OrphanedBlocks)
+}
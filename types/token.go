@@ -9,6 +9,7 @@ import (
 	"time"
 )
 
+// the quote tokens of the default config, the quote tokens in use are in QuoteTokens
 const (
 	WETH_USDC_PAIR = "0x88A43bbDF9D098eEC7bCEda4e2494615dfD9bB9C" // Uniswap v2 WETH/USDC pair
 	WETH           = "0x51dA03503FBBA94B9d0D88C15690D840F02F15F4"
@@ -19,25 +20,21 @@ var (
 	WETHUSDCPairAddressUniswapV2 = common.HexToAddress(WETH_USDC_PAIR)
 	WETHAddress                  = common.HexToAddress(WETH)
 	USDCAddress                  = common.HexToAddress(USDC)
+	NativeTokenAddress           = ZeroAddress // uniswap v4 currency of the native token
 )
 
 func IsSameAddress(address1, address2 common.Address) bool {
 	return address1.Cmp(address2) == 0
 }
 
-func IsWETH(address common.Address) bool {
-	return IsSameAddress(address, WETHAddress)
-}
-
-func IsUSDC(address common.Address) bool {
-	return IsSameAddress(address, USDCAddress)
+func IsNativeToken(address common.Address) bool {
+	return IsSameAddress(address, NativeTokenAddress)
 }
 
+// IsBaseToken a quote token of the config
 func IsBaseToken(address common.Address) bool {
-	if IsWETH(address) || IsUSDC(address) {
-		return true
-	}
-	return false
+	_, ok := QuotePriority(address)
+	return ok
 }
 
 type Token struct {
@@ -50,10 +47,24 @@ type Token struct {
 	BlockNumber uint64
 	BlockTime   time.Time
 	Program     string
+	CreateTx    common.Hash
 	Filtered    bool
 	Timestamp   time.Time
 }
 
+/*
+NewNativeToken
+the native token has no contract to query, it is priced like WETH
+*/
+func NewNativeToken() *Token {
+	return &Token{
+		Address:  NativeTokenAddress,
+		Name:     "Ether",
+		Symbol:   "ETH",
+		Decimals: 18,
+	}
+}
+
 func (t *Token) MarshalBinary() ([]byte, error) {
 	type Alias Token
 	return json.Marshal(&struct {
@@ -123,6 +134,9 @@ func (t *Token) GetOrmToken() *orm.Token {
 		BlockAt:     t.BlockTime,
 		Program:     t.Program,
 	}
+	if t.CreateTx != (common.Hash{}) {
+		ormToken.CreateTx = t.CreateTx.String()
+	}
 
 	return ormToken.Normalize()
 }
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"abchain_scan/repository/orm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+)
+
+/*
+TokenPriceGraph
+usd prices of the tokens without a base token pair, updated with the pool states of every committed block in order
+*/
+type TokenPriceGraph interface {
+	TokenPriceLookup
+	Update(height uint64, quoteTokenPrices TokenPrices, poolUpdates []*PoolUpdate, poolUpdatesV3 []*PoolUpdateV3)
+}
+
+type TokenPriceLookup interface {
+	GetTokenPrice(token common.Address) (decimal.Decimal, bool)
+}
+
+/*
+priceTxByTokenPrices
+a tx not priced yet gets its usd from token1, or from token0 when token1 has no price,
+PriceUsd stays the usd price of token0 like CalcAmountAndPrice
+*/
+func priceTxByTokenPrices(tx *orm.Tx, prices TokenPriceLookup) {
+	if !tx.AmountUsd.IsZero() {
+		return
+	}
+
+	if price, ok := prices.GetTokenPrice(common.HexToAddress(tx.Token1Address)); ok {
+		tx.AmountUsd = tx.Token1Amount.Mul(price)
+	} else if price, ok = prices.GetTokenPrice(common.HexToAddress(tx.Token0Address)); ok {
+		tx.AmountUsd = tx.Token0Amount.Mul(price)
+	} else {
+		return
+	}
+
+	if !tx.Token0Amount.IsZero() {
+		tx.PriceUsd = tx.AmountUsd.Div(tx.Token0Amount)
+	}
+}
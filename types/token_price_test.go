@@ -0,0 +1,42 @@
+package types
+
+import (
+	"abchain_scan/repository/orm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPriceTxByTokenPrices(t *testing.T) {
+	tokenA := common.HexToAddress("0xa1")
+	tokenB := common.HexToAddress("0xb1")
+	graph := TokenPrices{tokenB: decimal.NewFromInt(2)}
+
+	tx := &orm.Tx{Token0Address: tokenA.String(), Token1Address: tokenB.String(), Token0Amount: decimal.NewFromInt(10), Token1Amount: decimal.NewFromInt(5)}
+	priceTxByTokenPrices(tx, graph)
+	require.True(t, tx.AmountUsd.Equal(decimal.NewFromInt(10)), tx.AmountUsd.String())
+	require.True(t, tx.PriceUsd.Equal(decimal.NewFromInt(1)), tx.PriceUsd.String())
+
+	// token0 only
+	tx = &orm.Tx{Token0Address: tokenB.String(), Token1Address: tokenA.String(), Token0Amount: decimal.NewFromInt(3), Token1Amount: decimal.NewFromInt(6)}
+	priceTxByTokenPrices(tx, graph)
+	require.True(t, tx.AmountUsd.Equal(decimal.NewFromInt(6)), tx.AmountUsd.String())
+	require.True(t, tx.PriceUsd.Equal(decimal.NewFromInt(2)), tx.PriceUsd.String())
+
+	// priced by the base tokens already
+	tx = &orm.Tx{Token0Address: tokenA.String(), Token1Address: tokenB.String(), Token0Amount: decimal.NewFromInt(1), Token1Amount: decimal.NewFromInt(1), AmountUsd: decimal.NewFromInt(7)}
+	priceTxByTokenPrices(tx, graph)
+	require.True(t, tx.AmountUsd.Equal(decimal.NewFromInt(7)))
+}
+
+func TestFilterByToken0AndToken1_KeepNonBasePairs(t *testing.T) {
+	SetKeepNonBasePairs(true)
+	defer SetKeepNonBasePairs(false)
+
+	pair := &Pair{
+		Token0Core: &TokenCore{Address: common.HexToAddress("0xa1")},
+		Token1Core: &TokenCore{Address: common.HexToAddress("0xb1")},
+	}
+	require.False(t, pair.FilterByToken0AndToken1())
+}
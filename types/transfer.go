@@ -0,0 +1,104 @@
+package types
+
+import (
+	"abchain_scan/chain"
+	"abchain_scan/repository/orm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"sort"
+)
+
+// Transfer erc20 Transfer of a token in the token cache
+type Transfer struct {
+	Token    common.Address
+	From     common.Address
+	To       common.Address
+	ValueWei *big.Int
+	Decimals int8
+	LogIndex uint
+}
+
+/*
+BalanceDelta
+net balance change of a holder in a block, the zero address is left out so mints and burns only move the other side
+*/
+type BalanceDelta struct {
+	Token  string
+	Holder string
+	Delta  decimal.Decimal
+}
+
+func (d *BalanceDelta) GetOrmTokenHolder(block uint64) *orm.TokenHolder {
+	return &orm.TokenHolder{
+		Token:   d.Token,
+		Holder:  d.Holder,
+		ChainId: chain.Id,
+		Balance: d.Delta,
+		Block:   block,
+	}
+}
+
+type balanceKey struct {
+	token  common.Address
+	holder common.Address
+}
+
+func balanceDeltasFromTransfers(transfers []*Transfer) []*BalanceDelta {
+	key2Delta := make(map[balanceKey]decimal.Decimal)
+	for _, transfer := range transfers {
+		amount := decimal.NewFromBigInt(transfer.ValueWei, -int32(transfer.Decimals))
+		if transfer.From != ZeroAddress {
+			key := balanceKey{token: transfer.Token, holder: transfer.From}
+			key2Delta[key] = key2Delta[key].Sub(amount)
+		}
+		if transfer.To != ZeroAddress {
+			key := balanceKey{token: transfer.Token, holder: transfer.To}
+			key2Delta[key] = key2Delta[key].Add(amount)
+		}
+	}
+
+	deltas := make([]*BalanceDelta, 0, len(key2Delta))
+	for key, delta := range key2Delta {
+		if delta.IsZero() {
+			continue
+		}
+		deltas = append(deltas, &BalanceDelta{
+			Token:  key.token.String(),
+			Holder: key.holder.String(),
+			Delta:  delta,
+		})
+	}
+	sortBalanceDeltas(deltas)
+	return deltas
+}
+
+/*
+MergeBalanceDeltas
+sums the deltas of several blocks per token and holder, the upsert of a batch must not hit a row twice
+*/
+func MergeBalanceDeltas(deltas []*BalanceDelta) []*BalanceDelta {
+	key2Delta := make(map[[2]string]*BalanceDelta, len(deltas))
+	merged := make([]*BalanceDelta, 0, len(deltas))
+	for _, delta := range deltas {
+		key := [2]string{delta.Token, delta.Holder}
+		if existing, ok := key2Delta[key]; ok {
+			existing.Delta = existing.Delta.Add(delta.Delta)
+			continue
+		}
+		d := *delta
+		key2Delta[key] = &d
+		merged = append(merged, &d)
+	}
+	sortBalanceDeltas(merged)
+	return merged
+}
+
+func sortBalanceDeltas(deltas []*BalanceDelta) {
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].Token != deltas[j].Token {
+			return deltas[i].Token < deltas[j].Token
+		}
+		return deltas[i].Holder < deltas[j].Holder
+	})
+}
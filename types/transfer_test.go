@@ -0,0 +1,45 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestBalanceDeltasFromTransfers(t *testing.T) {
+	token := common.HexToAddress("0x0a")
+	alice := common.HexToAddress("0x01")
+	bob := common.HexToAddress("0x02")
+	transfers := []*Transfer{
+		// mint 10 to alice
+		{Token: token, From: ZeroAddress, To: alice, ValueWei: big.NewInt(10e6), Decimals: 6},
+		// alice sends 4 to bob and bob sends them back, bob nets out
+		{Token: token, From: alice, To: bob, ValueWei: big.NewInt(4e6), Decimals: 6},
+		{Token: token, From: bob, To: alice, ValueWei: big.NewInt(4e6), Decimals: 6},
+		// alice burns 1
+		{Token: token, From: alice, To: ZeroAddress, ValueWei: big.NewInt(1e6), Decimals: 6},
+	}
+
+	deltas := balanceDeltasFromTransfers(transfers)
+	require.Len(t, deltas, 1)
+	require.Equal(t, token.String(), deltas[0].Token)
+	require.Equal(t, alice.String(), deltas[0].Holder)
+	require.True(t, decimal.NewFromInt(9).Equal(deltas[0].Delta))
+}
+
+func TestMergeBalanceDeltas(t *testing.T) {
+	deltas := []*BalanceDelta{
+		{Token: "0x0b", Holder: "0x01", Delta: decimal.NewFromInt(1)},
+		{Token: "0x0a", Holder: "0x01", Delta: decimal.NewFromInt(2)},
+		{Token: "0x0b", Holder: "0x01", Delta: decimal.NewFromInt(-3)},
+	}
+
+	merged := MergeBalanceDeltas(deltas)
+	require.Len(t, merged, 2)
+	require.Equal(t, "0x0a", merged[0].Token)
+	require.True(t, decimal.NewFromInt(-2).Equal(merged[1].Delta))
+	// the input is left untouched
+	require.True(t, decimal.NewFromInt(1).Equal(deltas[0].Delta))
+}
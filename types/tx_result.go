@@ -12,6 +12,8 @@ type TxPairEvent struct {
 	PancakeV2 []Event
 	PancakeV3 []Event
 	Aerodrome []Event
+	UniswapV4 []Event
+	Others    []Event // dexes from the config, a TxPairEvent holds a single pair so they never mix
 }
 
 func (tpe *TxPairEvent) AddEvent(event Event) {
@@ -26,6 +28,31 @@ func (tpe *TxPairEvent) AddEvent(event Event) {
 			tpe.UniswapV3 = make([]Event, 0, 10)
 		}
 		tpe.UniswapV3 = append(tpe.UniswapV3, event)
+	case ProtocolIdAerodrome:
+		if tpe.Aerodrome == nil {
+			tpe.Aerodrome = make([]Event, 0, 10)
+		}
+		tpe.Aerodrome = append(tpe.Aerodrome, event)
+	case ProtocolIdPancakeV2:
+		if tpe.PancakeV2 == nil {
+			tpe.PancakeV2 = make([]Event, 0, 10)
+		}
+		tpe.PancakeV2 = append(tpe.PancakeV2, event)
+	case ProtocolIdPancakeV3:
+		if tpe.PancakeV3 == nil {
+			tpe.PancakeV3 = make([]Event, 0, 10)
+		}
+		tpe.PancakeV3 = append(tpe.PancakeV3, event)
+	case ProtocolIdUniswapV4:
+		if tpe.UniswapV4 == nil {
+			tpe.UniswapV4 = make([]Event, 0, 10)
+		}
+		tpe.UniswapV4 = append(tpe.UniswapV4, event)
+	default:
+		if tpe.Others == nil {
+			tpe.Others = make([]Event, 0, 10)
+		}
+		tpe.Others = append(tpe.Others, event)
 	}
 }
 
@@ -35,6 +62,8 @@ func (tpe *TxPairEvent) LinkEvents() {
 	tpe.linkEventByProtocol(tpe.PancakeV2)
 	tpe.linkEventByProtocol(tpe.PancakeV3)
 	tpe.linkEventByProtocol(tpe.Aerodrome)
+	tpe.linkEventByProtocol(tpe.UniswapV4)
+	tpe.linkEventByProtocol(tpe.Others)
 }
 
 func LinkPairCreatedEventAndMintEvent(pairCreatedEvents, mintEvents []Event) {
@@ -65,6 +94,7 @@ type TxResult struct {
 	Maker                   common.Address
 	PairCreatedEvents       []Event
 	PairAddress2TxPairEvent map[common.Address]*TxPairEvent
+	Transfers               []*Transfer
 }
 
 func NewTxResult(maker common.Address) *TxResult {
@@ -93,6 +123,10 @@ func (tr *TxResult) AddEvent(event Event) {
 	tr.PairAddress2TxPairEvent[pairAddress] = txPairEvent
 }
 
+func (tr *TxResult) AddTransfer(transfer *Transfer) {
+	tr.Transfers = append(tr.Transfers, transfer)
+}
+
 func (tr *TxResult) LinkEvents() {
 	for _, pairEvent := range tr.PairAddress2TxPairEvent {
 		pairEvent.LinkEvents()